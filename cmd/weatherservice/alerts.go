@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/notify"
+)
+
+// healthChecker описывает хранилище, доступность которого можно проверить
+type healthChecker interface {
+	Healthy(ctx context.Context) error
+}
+
+// newNotifier создает отправителя оповещений по конфигурации. Если оповещения не настроены,
+// возвращает notify.NopNotifier
+func newNotifier(cfg *config.Config) notify.Notifier {
+	var notifiers notify.MultiNotifier
+	if cfg.AlertSlackWebhook != "" {
+		notifiers = append(notifiers, notify.NewSlackNotifier(cfg.AlertSlackWebhook))
+	}
+	if cfg.AlertSmtpAddr != "" {
+		notifiers = append(notifiers, &notify.EmailNotifier{
+			Addr:     cfg.AlertSmtpAddr,
+			Username: cfg.AlertSmtpUsername,
+			Password: cfg.AlertSmtpPassword,
+			From:     cfg.AlertEmailFrom,
+			To:       cfg.AlertEmailTo,
+		})
+	}
+
+	switch len(notifiers) {
+	case 0:
+		return notify.NopNotifier{}
+	case 1:
+		return notifiers[0]
+	default:
+		return notifiers
+	}
+}
+
+// checkDatabase проверяет доступность хранилища, если оно это поддерживает, и отмечает сбой или его устранение.
+// Сбор данных продолжается: ошибки записи обрабатываются на каждом шаге
+func (c *collector) checkDatabase(ctx context.Context) {
+	checker, ok := c.dbManager.(healthChecker)
+	if !ok {
+		return
+	}
+
+	if err := checker.Healthy(ctx); err != nil {
+		log.Printf("База данных недоступна: %v", err)
+		c.alerts.Failure(ctx, notify.EventDBUnavailable, "", err.Error())
+		return
+	}
+	c.alerts.Success(ctx, notify.EventDBUnavailable, "")
+}
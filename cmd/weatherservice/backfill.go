@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log"
+)
+
+// backfillAgeMs - возраст последних данных датчика (30 дней в миллисекундах), начиная с которого
+// их запрос считается загрузкой истории: такие периоды запрашиваются частями по 30 дней
+const backfillAgeMs = int64(30 * 24 * 60 * 60 * 1000)
+
+// deferBackfill исключает из запроса датчики, для которых нужна загрузка истории: новые датчики
+// и датчики, последние данные которых старше backfillAgeMs. Их история будет запрошена в часы BACKFILL_HOURS
+// с места, на котором загрузка остановилась (по сохраненным данным и SyncState).
+// Возвращает датчики, которые запрашиваются сейчас
+func (c *collector) deferBackfill(deviceID string, newSensors, existingSensors []string, sensorLastTs map[string]int64, now int64) ([]string, []string) {
+	deferred := append([]string{}, newSensors...)
+
+	var current []string
+	for _, sensorKey := range existingSensors {
+		if sensorLastTs[sensorKey] < now-backfillAgeMs {
+			deferred = append(deferred, sensorKey)
+		} else {
+			current = append(current, sensorKey)
+		}
+	}
+
+	if len(deferred) > 0 {
+		log.Printf("Для устройства %s загрузка истории датчиков %v отложена до часов BACKFILL_HOURS (%s)",
+			deviceID, deferred, c.backfillWindow)
+	}
+	return nil, current
+}
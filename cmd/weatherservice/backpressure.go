@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Параметры снижения нагрузки на хранилище
+const (
+	// maxBackpressureLevel - максимальная степень снижения нагрузки
+	maxBackpressureLevel = 3
+	// backpressureSmoothing - вес нового измерения в скользящем среднем времени сохранения
+	backpressureSmoothing = 0.3
+	// maxBackpressurePause - максимальная пауза перед сохранением
+	maxBackpressurePause = 30 * time.Second
+)
+
+// writeBackpressure снижает нагрузку на хранилище, если оно отвечает медленно. По скользящему среднему
+// времени сохранения телеметрии определяется степень снижения нагрузки: с каждой степенью вдвое
+// уменьшается количество параллельно запрашиваемых периодов и увеличивается пауза перед сохранением.
+// Степень сбрасывается в начале каждого цикла сбора
+type writeBackpressure struct {
+	mu        sync.Mutex
+	threshold time.Duration // порог среднего времени сохранения (0 - отключено)
+	average   time.Duration
+	level     int
+}
+
+// setThreshold задает порог среднего времени сохранения
+func (b *writeBackpressure) setThreshold(threshold time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.threshold = threshold
+}
+
+// reset сбрасывает среднее время сохранения и степень снижения нагрузки
+func (b *writeBackpressure) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.average = 0
+	b.level = 0
+}
+
+// observe учитывает время очередного сохранения. Степень снижения нагрузки повышается, пока среднее
+// время выше порога, и понижается, когда оно опускается ниже половины порога
+func (b *writeBackpressure) observe(elapsed time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 {
+		return
+	}
+
+	if b.average == 0 {
+		b.average = elapsed
+	} else {
+		b.average = time.Duration(backpressureSmoothing*float64(elapsed) + (1-backpressureSmoothing)*float64(b.average))
+	}
+
+	previous := b.level
+	switch {
+	case b.average > b.threshold && b.level < maxBackpressureLevel:
+		b.level++
+	case b.average < b.threshold/2 && b.level > 0:
+		b.level--
+	}
+
+	if b.level != previous {
+		log.Printf("Среднее время сохранения телеметрии %s (порог %s), степень снижения нагрузки на хранилище: %d из %d",
+			b.average.Round(time.Millisecond), b.threshold, b.level, maxBackpressureLevel)
+	}
+}
+
+// currentLevel возвращает текущую степень снижения нагрузки
+func (b *writeBackpressure) currentLevel() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.level
+}
+
+// concurrency возвращает количество параллельно запрашиваемых периодов с учетом снижения нагрузки
+func (b *writeBackpressure) concurrency(configured int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := configured >> b.level
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// wait выдерживает паузу перед сохранением: среднее время сохранения, умноженное на степень снижения нагрузки
+func (b *writeBackpressure) wait(ctx context.Context) {
+	b.mu.Lock()
+	pause := b.average * time.Duration(b.level)
+	b.mu.Unlock()
+
+	if pause <= 0 {
+		return
+	}
+	if pause > maxBackpressurePause {
+		pause = maxBackpressurePause
+	}
+
+	select {
+	case <-time.After(pause):
+	case <-ctx.Done():
+	}
+}
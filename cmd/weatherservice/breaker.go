@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Состояния автоматического выключателя
+const (
+	breakerClosed   = "closed"    // сбор данных выполняется
+	breakerOpen     = "open"      // сбор данных пропускается до окончания паузы
+	breakerHalfOpen = "half-open" // пробный цикл после паузы
+)
+
+// circuitBreaker приостанавливает сбор данных при длительной недоступности API. После threshold
+// неудачных циклов подряд выключатель размыкается, и циклы пропускаются в течение cooldown.
+// Затем выполняется пробный цикл: при успехе сбор возобновляется, при неудаче пауза начинается заново
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int // количество неудачных циклов подряд для размыкания (0 - выключатель отключен)
+	cooldown  time.Duration
+
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// breakerState - состояние выключателя для отладочного эндпоинта
+type breakerState struct {
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	RetryAt  *time.Time `json:"retry_at,omitempty"`
+}
+
+// configure задает порог неудачных циклов и длительность паузы. При отключении выключатель замыкается
+func (b *circuitBreaker) configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.threshold = threshold
+	b.cooldown = cooldown
+	if threshold <= 0 {
+		b.state = breakerClosed
+		b.failures = 0
+	}
+}
+
+// allow сообщает, можно ли выполнять цикл сбора. По окончании паузы выключатель переходит
+// в пробное состояние и разрешает один цикл
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != breakerOpen {
+		return true
+	}
+	if now.Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+
+	b.state = breakerHalfOpen
+	log.Printf("Пауза сбора данных истекла, выполняем пробный цикл")
+	return true
+}
+
+// success отмечает успешный цикл и замыкает выключатель
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		log.Printf("Пробный цикл успешен, сбор данных возобновлен")
+	}
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+// failure отмечает неудачный цикл. Выключатель размыкается после threshold неудач подряд
+// или после неудачного пробного цикла
+func (b *circuitBreaker) failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = now
+		log.Printf("Неудачных циклов сбора подряд: %d, сбор данных приостановлен на %s", b.failures, b.cooldown)
+	}
+}
+
+// snapshot возвращает состояние выключателя
+func (b *circuitBreaker) snapshot() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := breakerState{State: b.state, Failures: b.failures}
+	if state.State == "" {
+		state.State = breakerClosed
+	}
+	if b.state == breakerOpen {
+		openedAt := b.openedAt
+		retryAt := b.openedAt.Add(b.cooldown)
+		state.OpenedAt = &openedAt
+		state.RetryAt = &retryAt
+	}
+	return state
+}
@@ -0,0 +1,1350 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/clock"
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/database"
+	"weatherInTheField/pkg/logging"
+	"weatherInTheField/pkg/notify"
+	"weatherInTheField/pkg/publisher"
+	"weatherInTheField/pkg/server"
+	"weatherInTheField/pkg/units"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer создает спаны трассировки цикла сбора данных
+var tracer = otel.Tracer("weatherInTheField/cmd/weatherservice")
+
+// Ключи датчиков, которые запрашиваются по умолчанию (переопределяются SENSOR_KEYS)
+var defaultSensorKeys = []string{
+	"airtemp",        // Температура воздуха
+	"soiltemp",       // Температура почвы
+	"airmoist",       // Влажность воздуха
+	"rainfall",       // Количество осадков
+	"rainfall_daily", // Количество осадков за предыдущие сутки
+	"windspeed",      // Скорость ветра
+	"windspeedmax",   // Порывы ветра
+	"winddir",        // Направление ветра
+	"winddirang",     // Направление ветра в градусах
+}
+
+// sensorKeysFor возвращает ключи запрашиваемых датчиков: из SENSOR_KEYS или набор по умолчанию
+func sensorKeysFor(cfg *config.Config) []string {
+	if len(cfg.SensorKeys) > 0 {
+		return cfg.SensorKeys
+	}
+	return defaultSensorKeys
+}
+
+// collector хранит зависимости, необходимые для сбора данных
+type collector struct {
+	weatherAPI api.WeatherClient
+	dbManager  database.TelemetryStore
+
+	// Источник текущего времени для периодов запросов, расписания и часов загрузки истории
+	clock clock.Clock
+
+	// Ключи запрашиваемых датчиков
+	sensorKeys []string
+
+	// Датчики, сбор которых отключен (DISABLED_SENSORS)
+	disabledSensors map[string]bool
+
+	// Фильтр станций по имени (nil - опрашиваются все станции)
+	labelFilter *regexp.Regexp
+
+	// Публикация свежих данных во внешние системы (может отсутствовать)
+	publisher publisher.Publisher
+
+	// Уведомления о новых метеостанциях (может отсутствовать)
+	stationWebhook *notify.StationWebhook
+
+	// Преобразования единиц измерения по ключам датчиков
+	conversions map[string]units.Conversion
+
+	// Количество знаков после запятой для округления значений (-1 - без округления)
+	// и переопределения по ключам датчиков
+	valueDecimals  int
+	sensorDecimals map[string]int
+
+	// Поле значения-объекта, сохраняемое как число (ключ датчика -> поле)
+	objectFields map[string]string
+
+	// Расписание опроса станций с индивидуальными интервалами (если не задано, опрашиваются все станции)
+	schedule *stationSchedule
+
+	// Время, в течение которого используется ранее полученный список устройств (0 - запрашивать каждый цикл)
+	deviceCacheTTL time.Duration
+
+	// Максимальная длительность одного запроса телеметрии в днях (0 - без ограничения)
+	maxPeriodDays int
+
+	// Перекрытие периода запроса с последней сохраненной записью в миллисекундах (0 - без перекрытия)
+	fetchOverlapMs int64
+
+	// Вычислять направление ветра в градусах (winddirang) по обозначению румба (winddir)
+	windDirToDegrees bool
+
+	// Количество периодов одного устройства, запрашиваемых параллельно
+	periodConcurrency int
+
+	// Время без новых данных, после которого датчик считается переставшим передавать данные (0 - не проверяется),
+	// и датчики, о которых уже предупредили
+	staleAfter   time.Duration
+	staleSensors staleTracker
+
+	// Границы допустимого времени измерений: точки раньше minValidTime или позже текущего времени
+	// более чем на maxFutureSkew отбрасываются
+	minValidTime  time.Time
+	maxFutureSkew time.Duration
+
+	// Часы загрузки истории (nil - история загружается в любое время)
+	backfillWindow *config.HourWindow
+
+	// Снижение нагрузки на хранилище при медленном сохранении
+	backpressure writeBackpressure
+
+	// Время сохранения, начиная с которого в лог выводится время и скорость сохранения (0 - выводится всегда)
+	slowWriteThreshold time.Duration
+
+	// Периодичность обновления данных станций
+	stationRefresh stationRefresh
+
+	// Приостановка сбора при длительной недоступности API
+	breaker circuitBreaker
+
+	// Редкий опрос станций с повторяющимися ошибками
+	demotion stationDemotion
+
+	// Рассылка сохраненной телеметрии для потока API чтения данных (nil - поток не используется)
+	stream *server.Broker
+
+	// Буфер телеметрии, которую не удалось сохранить (nil - буфер отключен)
+	spool *telemetrySpool
+
+	// Очередь записи телеметрии (nil - телеметрия сохраняется сразу после получения)
+	writes *writeQueue
+
+	// Оповещения о повторяющихся сбоях (без настройки оповещения не отправляются)
+	alerts *notify.Alerter
+
+	// Защита от одновременного выполнения нескольких циклов сбора
+	running sync.Mutex
+
+	// Состояние последнего цикла сбора для отладочного эндпоинта
+	cycles cycleTracker
+}
+
+// runCycle выполняет один цикл сбора данных, если не выполняется предыдущий. Если timeout больше нуля, цикл прерывается
+// по истечении этого времени: выполняющиеся запросы отменяются, а следующий цикл начинается заново
+func (c *collector) runCycle(parent context.Context, timeout time.Duration) {
+	// Если предыдущий цикл еще выполняется, пропускаем запуск, чтобы не запрашивать данные дважды
+	if !c.running.TryLock() {
+		log.Println("Предыдущий цикл сбора данных еще не завершен, запуск пропущен")
+		return
+	}
+	defer c.running.Unlock()
+
+	ctx := parent
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, timeout)
+		defer cancel()
+	}
+
+	c.collectData(ctx)
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logging.Warnf("Внимание: цикл сбора данных не уложился в %s и был прерван", timeout)
+	}
+}
+
+// collectData выполняет сбор данных со всех метеостанций и их сохранение в БД
+func (c *collector) collectData(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "collectData")
+	defer span.End()
+
+	log.Println("Начинаем сбор данных...")
+	c.cycles.start(c.clock.Now())
+	defer func() { c.cycles.finish(c.clock.Now()) }()
+
+	// Сводка времени ответа API выводится и при прерванном цикле
+	defer c.logAPILatencies()
+
+	// Снижение нагрузки на хранилище действует до конца цикла
+	c.backpressure.reset()
+
+	// Регистрируем запуск в журнале, если хранилище его поддерживает
+	var stats database.RunStats
+	recorder, _ := c.dbManager.(database.RunRecorder)
+	var runID int64
+	if recorder != nil {
+		id, err := recorder.StartRun()
+		if err != nil {
+			logging.Errorf("Ошибка при регистрации запуска сбора данных: %v", err)
+			recorder = nil
+		} else {
+			runID = id
+		}
+	}
+	if recorder != nil {
+		defer func() {
+			if err := recorder.FinishRun(runID, stats); err != nil {
+				logging.Errorf("Ошибка при сохранении итогов запуска сбора данных: %v", err)
+			}
+		}()
+	}
+
+	// Проверяем доступность базы данных и сохраняем данные, накопленные за время ее недоступности
+	c.checkDatabase(ctx)
+	c.flushSpool(ctx)
+
+	// При длительной недоступности API циклы пропускаются до окончания паузы
+	if !c.breaker.allow(c.clock.Now()) {
+		breaker := c.breaker.snapshot()
+		log.Printf("Сбор данных пропущен: выключатель разомкнут (circuit open) после %d неудачных циклов, следующая попытка в %s",
+			breaker.Failures, breaker.RetryAt.In(displayLocation).Format("2006-01-02 15:04:05"))
+		stats.Errors = append(stats.Errors, "сбор пропущен: выключатель разомкнут")
+		span.SetAttributes(attribute.String("circuit_breaker.state", breaker.State))
+		return
+	}
+
+	// Получаем список всех устройств
+	devices, err := c.weatherAPI.GetDevicesCached(ctx, c.deviceCacheTTL)
+	if err != nil {
+		logging.Errorf("Ошибка при получении списка устройств: %v", err)
+		stats.Errors = append(stats.Errors, fmt.Sprintf("получение списка устройств: %v", err))
+		c.alerts.Failure(ctx, notify.EventCycleFailed, "", fmt.Sprintf("ошибка при получении списка устройств: %v", err))
+		c.breaker.failure(c.clock.Now())
+		if c.schedule != nil {
+			c.schedule.postponeOverdue(c.clock.Now())
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	log.Printf("Найдено устройств: %d", len(devices))
+	c.alerts.Success(ctx, notify.EventCycleFailed, "")
+
+	// Оставляем только станции, имя которых соответствует фильтру
+	if c.labelFilter != nil {
+		matched := filterDevicesByLabel(devices, c.labelFilter)
+		log.Printf("Станций, не соответствующих фильтру DEVICE_LABEL_REGEX %q: %d, к обработке: %d",
+			c.labelFilter, len(devices)-len(matched), len(matched))
+		devices = matched
+	}
+
+	c.cycles.setDevices(devices)
+	span.SetAttributes(attribute.Int("device.count", len(devices)))
+
+	// Запоминаем уже известные станции, чтобы определить новые
+	var knownStations map[string]bool
+	if c.stationWebhook != nil {
+		knownStations, err = c.loadKnownStations()
+		if err != nil {
+			logging.Errorf("Ошибка при получении списка известных станций: %v", err)
+		}
+	}
+
+	// Сохраняем в базу данных станции, которые еще не сохранялись (данные всех станций обновляются
+	// по таймеру STATION_REFRESH_MINUTES). При ошибке станции сохраняются по одной
+	// перед записью их телеметрии, иначе запись нарушит внешний ключ на таблицу Stations
+	stationsStored := true
+	if toStore := c.stationRefresh.newDevices(devices); len(toStore) > 0 {
+		log.Printf("Новых станций к сохранению: %d", len(toStore))
+		if err := c.dbManager.StoreStations(toStore); err != nil {
+			logging.Errorf("Ошибка при сохранении информации о станциях: %v", err)
+			stats.Errors = append(stats.Errors, fmt.Sprintf("сохранение станций: %v", err))
+			stationsStored = false
+		} else {
+			c.stationRefresh.markStored(toStore)
+			if knownStations != nil {
+				c.notifyNewStations(ctx, toStore, knownStations)
+			}
+		}
+	}
+
+	// Сохраняем последние показания из списка устройств. Если станции не сохранены, записи
+	// нарушат внешний ключ на таблицу Stations, поэтому показания сохраняются в следующем цикле
+	if stationsStored {
+		c.storeLatestReadings(ctx, devices)
+	}
+
+	// Оставляем только станции, время опроса которых наступило
+	if c.schedule != nil {
+		devices = c.dueDevices(devices, c.clock.Now())
+		log.Printf("Станций к опросу по расписанию: %d", len(devices))
+	}
+
+	// Станции на редком опросе пропускаются до времени следующей попытки. Для расписания они считаются
+	// опрошенными, иначе следующий цикл запускался бы сразу
+	due, demoted := c.demotion.dueDevices(devices, c.clock.Now())
+	if len(demoted) > 0 {
+		log.Printf("Станций на редком опросе после повторяющихся ошибок пропущено: %d %v", len(demoted), demoted)
+		if c.schedule != nil {
+			for _, stationID := range demoted {
+				c.schedule.markRun(stationID, c.clock.Now())
+			}
+		}
+		devices = due
+	}
+	span.SetAttributes(attribute.Int("station.demoted.count", len(c.demotion.snapshot())))
+
+	// Количество устройств, данные которых не удалось получить из API (ни одной записи при ошибках запросов)
+	apiFailedDevices := 0
+
+	// recordResult учитывает итоги обработки устройства в статистике цикла и оповещениях
+	recordResult := func(deviceID string, result deviceResult) {
+		stats.DevicesProcessed++
+		stats.TotalRecords += result.records
+		if len(result.failedPeriods) > 0 {
+			stats.Errors = append(stats.Errors, fmt.Sprintf("устройство %s: не получены данные за %d периодов: %s",
+				deviceID, len(result.failedPeriods), formatPeriods(result.failedPeriods)))
+			if result.records == 0 {
+				apiFailedDevices++
+			}
+		}
+		if result.storeErrors > 0 {
+			stats.Errors = append(stats.Errors, fmt.Sprintf("устройство %s: %d ошибок сохранения телеметрии",
+				deviceID, result.storeErrors))
+		}
+
+		// Оповещаем о станциях, данные которых не удается получить или сохранить несколько циклов подряд,
+		// и переводим такие станции на редкий опрос, чтобы они не замедляли каждый цикл
+		if len(result.failedPeriods) > 0 || result.storeErrors > 0 {
+			c.alerts.Failure(ctx, notify.EventDeviceFailed, deviceID,
+				fmt.Sprintf("не получены данные за %d периодов, ошибок сохранения: %d", len(result.failedPeriods), result.storeErrors))
+			if failures, demoted := c.demotion.failure(deviceID, c.clock.Now()); demoted {
+				log.Printf("Станция %s переведена на редкий опрос после %d циклов с ошибками подряд", deviceID, failures)
+				c.alerts.Raise(ctx, notify.EventStationDemoted, deviceID,
+					fmt.Sprintf("станция переведена на редкий опрос после %d циклов с ошибками подряд", failures), failures)
+			}
+		} else {
+			c.alerts.Success(ctx, notify.EventDeviceFailed, deviceID)
+			if c.demotion.success(deviceID) {
+				log.Printf("Станция %s опрошена успешно и возвращена к обычному опросу", deviceID)
+				c.alerts.Success(ctx, notify.EventStationDemoted, deviceID)
+			}
+		}
+	}
+
+	// Устройства, телеметрия которых еще сохраняется в очереди записи
+	var inProgress []*deviceProgress
+
+	// Обрабатываем каждое устройство
+	for i, device := range devices {
+		// Цикл прерван по истечении времени - оставшиеся устройства будут обработаны в следующем цикле
+		if ctx.Err() != nil {
+			log.Printf("Сбор данных прерван: %v. Не обработано устройств: %d", ctx.Err(), len(devices)-i)
+			stats.Errors = append(stats.Errors, fmt.Sprintf("сбор прерван: %v, не обработано устройств: %d", ctx.Err(), len(devices)-i))
+			break
+		}
+
+		if c.schedule != nil {
+			c.schedule.markRun(device.ID, c.clock.Now())
+		}
+
+		// Станция не сохранена общим запросом - сохраняем ее отдельно, без этого телеметрия не запишется
+		if !stationsStored {
+			if err := c.dbManager.StoreStations([]api.Device{device}); err != nil {
+				logging.Errorf("Ошибка при сохранении станции %s, обработка устройства пропущена: %v", device.ID, err)
+				stats.Errors = append(stats.Errors, fmt.Sprintf("устройство %s: сохранение станции: %v", device.ID, err))
+				c.alerts.Failure(ctx, notify.EventDeviceFailed, device.ID, fmt.Sprintf("ошибка при сохранении станции: %v", err))
+				continue
+			}
+			c.stationRefresh.markStored([]api.Device{device})
+		}
+
+		// При включенной очереди записи следующее устройство запрашивается, пока сохраняются данные предыдущего
+		progress := c.processDevice(ctx, device)
+		if c.writes == nil {
+			recordResult(device.ID, c.finishDevice(progress))
+		} else {
+			inProgress = append(inProgress, progress)
+		}
+	}
+
+	// Дожидаемся сохранения телеметрии, переданной в очередь записи
+	for _, progress := range inProgress {
+		recordResult(progress.deviceID, c.finishDevice(progress))
+	}
+
+	// Цикл считается неудачным, если не удалось получить данные ни одного обработанного устройства
+	if stats.DevicesProcessed > 0 && apiFailedDevices == stats.DevicesProcessed {
+		c.breaker.failure(c.clock.Now())
+	} else {
+		c.breaker.success()
+	}
+
+	if c.spool != nil {
+		if spool := c.spool.state(); spool.Entries > 0 {
+			log.Printf("В буфере несохраненной телеметрии записей: %d, точек: %d из %d", spool.Entries, spool.Points, spool.MaxPoints)
+			span.SetAttributes(attribute.Int("spool.points", spool.Points))
+		}
+	}
+
+	if c.writes != nil {
+		maxDepth := c.writes.takeMaxDepth()
+		log.Printf("Наибольшая длина очереди записи за цикл: %d из %d", maxDepth, c.writes.capacity)
+		span.SetAttributes(attribute.Int("write_queue.max_depth", maxDepth))
+	}
+
+	if level := c.backpressure.currentLevel(); level > 0 {
+		log.Printf("Цикл завершен со снижением нагрузки на хранилище, степень: %d из %d", level, maxBackpressureLevel)
+	}
+	span.SetAttributes(
+		attribute.Int("record.count", stats.TotalRecords),
+		attribute.Int("backpressure.level", c.backpressure.currentLevel()),
+		attribute.String("circuit_breaker.state", c.breaker.snapshot().State),
+	)
+	log.Println("Сбор данных завершен")
+}
+
+// filterDevicesByLabel возвращает устройства, пользовательское имя которых соответствует регулярному выражению
+func filterDevicesByLabel(devices []api.Device, pattern *regexp.Regexp) []api.Device {
+	var matched []api.Device
+	for _, device := range devices {
+		if pattern.MatchString(device.Label) {
+			matched = append(matched, device)
+		}
+	}
+	return matched
+}
+
+// logAPILatencies выводит в лог сводку времени ответа API за цикл, если клиент ее измеряет
+func (c *collector) logAPILatencies() {
+	reporter, ok := c.weatherAPI.(api.LatencyReporter)
+	if !ok {
+		return
+	}
+
+	latencies := reporter.TakeLatencies()
+	endpoints := make([]string, 0, len(latencies))
+	for endpoint := range latencies {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	for _, endpoint := range endpoints {
+		summary := latencies[endpoint]
+		log.Printf("Время ответа API %s: запросов %d, p50 %s, p95 %s, max %s",
+			endpoint, summary.Count,
+			summary.P50.Round(time.Millisecond), summary.P95.Round(time.Millisecond), summary.Max.Round(time.Millisecond))
+	}
+}
+
+// dueDevices возвращает устройства, время опроса которых наступило по расписанию
+func (c *collector) dueDevices(devices []api.Device, now time.Time) []api.Device {
+	var due []api.Device
+	for _, device := range devices {
+		if c.schedule.due(device.ID, now) {
+			due = append(due, device)
+		}
+	}
+	return due
+}
+
+// loadKnownStations возвращает множество ID станций, уже сохраненных в базе данных
+func (c *collector) loadKnownStations() (map[string]bool, error) {
+	stations, err := c.dbManager.GetStations()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(stations))
+	for _, id := range stations {
+		known[id] = true
+	}
+	return known, nil
+}
+
+// notifyNewStations отправляет уведомления о станциях, которых не было в базе данных.
+// Ошибки отправки только логируются и не прерывают сбор данных
+func (c *collector) notifyNewStations(ctx context.Context, devices []api.Device, knownStations map[string]bool) {
+	// При первом запуске с пустой базой все станции новые - не рассылаем уведомления о каждой
+	if len(knownStations) == 0 {
+		log.Printf("База данных не содержит станций, уведомления о %d новых станциях не отправляются", len(devices))
+		return
+	}
+
+	for _, device := range devices {
+		if knownStations[device.ID] {
+			continue
+		}
+
+		log.Printf("Обнаружена новая станция: %s (%s)", device.Label, device.ID)
+		if err := c.stationWebhook.NotifyNewStation(ctx, device); err != nil {
+			logging.Errorf("Ошибка при отправке уведомления о новой станции %s: %v", device.ID, err)
+		}
+	}
+}
+
+// deviceResult содержит итоги обработки одного устройства
+type deviceResult struct {
+	records       int          // количество сохраненных записей
+	failedPeriods []timePeriod // периоды, за которые не удалось получить телеметрию
+	storeErrors   int          // количество неудачных попыток сохранения
+}
+
+// deviceProgress накапливает итоги обработки устройства. При включенной очереди записи телеметрия
+// сохраняется в горутинах записи, поэтому итоги защищены мьютексом, а незавершенные записи учитываются в pending
+type deviceProgress struct {
+	ctx      context.Context
+	span     trace.Span
+	deviceID string
+
+	// Датчики устройства и запрошенные датчики (новые и существующие)
+	deviceKeys       []string
+	requestedSensors []string
+
+	pending      sync.WaitGroup
+	mu           sync.Mutex
+	result       deviceResult
+	sensorCounts map[string]int
+}
+
+// failed отмечает период, за который не удалось получить телеметрию
+func (p *deviceProgress) failed(period timePeriod) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.result.failedPeriods = append(p.result.failedPeriods, period)
+}
+
+// saved учитывает результат сохранения телеметрии одного периода
+func (p *deviceProgress) saved(sensorCounts map[string]int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil {
+		p.result.storeErrors++
+	}
+	for sensorKey, count := range sensorCounts {
+		p.sensorCounts[sensorKey] += count
+		p.result.records += count
+	}
+}
+
+// processDevice обрабатывает отдельное устройство (метеостанцию): запрашивает телеметрию и передает ее на сохранение.
+// При включенной очереди записи сохранение может продолжаться после возврата, итоги возвращает finishDevice
+func (c *collector) processDevice(ctx context.Context, device api.Device) *deviceProgress {
+	ctx, span := tracer.Start(ctx, "processDevice", trace.WithAttributes(attribute.String("device.id", device.ID)))
+	progress := &deviceProgress{
+		ctx:          ctx,
+		span:         span,
+		deviceID:     device.ID,
+		sensorCounts: make(map[string]int),
+	}
+
+	log.Printf("Обрабатываем устройство: %s (%s)", device.Label, device.ID)
+
+	// Текущее время в миллисекундах
+	now := c.clock.Now().UnixMilli()
+
+	// Стандартный интервал для получения данных (если нет данных в БД)
+	intervalMs := int64(15 * 60 * 1000) // 15 минут в миллисекундах
+
+	// Начало годового периода, за который запрашиваются данные новых датчиков
+	oneYearAgo := now - 365*24*60*60*1000 // 365 дней в миллисекундах
+
+	// Создаем карту для хранения данных о последнем timestamp для каждого датчика
+	sensorLastTs := make(map[string]int64)
+
+	// Создаем два списка датчиков - новые (без данных) и существующие
+	var newSensors []string
+	var existingSensors []string
+
+	// Запрашиваем только датчики, которые есть на устройстве и не отключены
+	deviceKeys := c.enabledSensors(deviceSensorKeys(device, c.sensorKeys))
+	if len(deviceKeys) == 0 {
+		log.Printf("Устройство %s не сообщает ни об одном из активных датчиков %v, пропускаем", device.ID, c.sensorKeys)
+		return progress
+	}
+	progress.deviceKeys = deviceKeys
+	span.SetAttributes(attribute.Int("sensor.count", len(deviceKeys)))
+
+	// Получаем время последних данных по всем датчикам устройства одним запросом
+	latestTimestamps, err := c.dbManager.GetLatestTimestamps(device.ID)
+	if err != nil {
+		logging.Errorf("Ошибка при получении последних timestamp для %s: %v", device.ID, err)
+		// Если ошибка, считаем что данных нет
+		latestTimestamps = nil
+	}
+
+	// Получаем интервалы, которые уже полностью синхронизированы (если хранилище их ведет)
+	syncState := c.loadSyncState(ctx, device.ID)
+
+	for _, sensorKey := range deviceKeys {
+		lastTs := latestTimestamps[sensorKey]
+
+		// Если синхронизированный интервал продолжает сохраненные данные, запрашиваем данные после его конца:
+		// периоды без записей не запрашиваются повторно после перезапуска
+		if synced, ok := syncState[sensorKey]; ok && synced.To > lastTs {
+			start := oneYearAgo
+			if lastTs > 0 {
+				start = lastTs + 1
+			}
+			if synced.From <= start {
+				lastTs = synced.To
+			}
+		}
+		sensorLastTs[sensorKey] = lastTs
+
+		// Проверяем, есть ли для этого датчика данные в базе
+		if lastTs > 0 {
+			existingSensors = append(existingSensors, sensorKey)
+		} else {
+			// Датчик есть в списке, но данных по нему нет
+			newSensors = append(newSensors, sensorKey)
+		}
+	}
+
+	// Вне часов BACKFILL_HOURS загрузка истории откладывается, обновляются только актуальные датчики
+	if c.backfillWindow != nil && !c.backfillWindow.Contains(c.clock.Now().In(displayLocation)) {
+		newSensors, existingSensors = c.deferBackfill(device.ID, newSensors, existingSensors, sensorLastTs, now)
+	}
+
+	// Определяем минимальный timestamp для всех существующих датчиков
+	minTsFrom := now
+	for _, sensorKey := range existingSensors {
+		if lastTs := sensorLastTs[sensorKey]; lastTs < minTsFrom {
+			minTsFrom = lastTs
+		}
+	}
+
+	// Рассчитываем tsFrom для существующих датчиков
+	tsFrom := now - intervalMs
+	if minTsFrom < now && minTsFrom > 0 {
+		// Добавляем 1 миллисекунду, чтобы не получать повторно ту же запись. Перекрытие FETCH_OVERLAP_MS
+		// запрашивает повторно последние записи на случай неточных временных меток API, повторы
+		// отбрасываются при сохранении
+		tsFrom = minTsFrom + 1 - c.fetchOverlapMs
+	}
+
+	// Обрабатываем новые датчики, если они есть
+	if len(newSensors) > 0 {
+		log.Printf("Для устройства %s запрашиваем годовые данные для %d новых датчиков: %v",
+			device.ID, len(newSensors), newSensors)
+
+		// Разбиваем год на месячные интервалы
+		periods := splitTimePeriodByMonth(oneYearAgo, now, c.maxPeriodDays)
+
+		// Получаем и сохраняем телеметрию за каждый период только для новых датчиков
+		c.fetchAndStorePeriods(ctx, device.ID, "новых", newSensors, periods, syncState, progress)
+	}
+
+	// Обрабатываем существующие датчики, если они есть
+	if len(existingSensors) > 0 {
+		log.Printf("Для устройства %s запрашиваем обновленные данные для %d существующих датчиков с %s",
+			device.ID,
+			len(existingSensors),
+			formatTs(tsFrom))
+
+		// Определяем период запроса данных для существующих датчиков
+		var periods []timePeriod
+
+		// Если последняя запись старше месяца, разбиваем запросы на промежутки
+		if tsFrom < now-backfillAgeMs {
+			log.Printf("Для устройства %s данные старше месяца. Разбиваем запрос на меньшие интервалы.", device.ID)
+			// Разбиваем период по 30 дней
+			periods = splitTimePeriodByDays(tsFrom, now, 30, c.maxPeriodDays)
+		} else {
+			// Если период небольшой, делаем один запрос
+			minutesAgo := (now - tsFrom) / 1000 / 60
+			log.Printf("Для устройства %s запрашиваем данные за последние %d минут", device.ID, minutesAgo)
+			periods = capPeriods([]timePeriod{{tsFrom, now}}, c.maxPeriodDays)
+		}
+
+		// Получаем и сохраняем телеметрию за каждый период только для существующих датчиков
+		c.fetchAndStorePeriods(ctx, device.ID, "существующих", existingSensors, periods, syncState, progress)
+	}
+
+	progress.requestedSensors = append(append([]string{}, newSensors...), existingSensors...)
+	return progress
+}
+
+// finishDevice ожидает сохранения телеметрии устройства, выводит итоги его обработки
+// и проверяет датчики, переставшие передавать данные
+func (c *collector) finishDevice(progress *deviceProgress) (result deviceResult) {
+	progress.pending.Wait()
+	result = progress.result
+	defer func() {
+		progress.span.SetAttributes(
+			attribute.Int("record.count", result.records),
+			attribute.Int("failed_period.count", len(result.failedPeriods)),
+		)
+		progress.span.End()
+	}()
+
+	if len(progress.deviceKeys) == 0 {
+		return result
+	}
+
+	if result.records > 0 {
+		log.Printf("Данные для устройства %s успешно обработаны. Всего получено %d записей.", progress.deviceID, result.records)
+
+		// Выводим количество записей по всем запрошенным датчикам, включая датчики без данных
+		for _, sensorKey := range progress.requestedSensors {
+			if _, ok := progress.sensorCounts[sensorKey]; !ok {
+				progress.sensorCounts[sensorKey] = 0
+			}
+		}
+		log.Printf("Записи по датчикам устройства %s: %s", progress.deviceID, formatSensorCounts(progress.sensorCounts))
+	} else {
+		log.Printf("Для устройства %s не получено никаких новых данных.", progress.deviceID)
+	}
+
+	// Сообщаем о пропущенных периодах, чтобы их можно было запросить повторно
+	if len(result.failedPeriods) > 0 {
+		log.Printf("Для устройства %s не удалось получить данные за %d периодов: %s",
+			progress.deviceID, len(result.failedPeriods), formatPeriods(result.failedPeriods))
+	}
+
+	// Проверяем датчики, переставшие передавать данные
+	if c.staleAfter > 0 {
+		c.checkStaleSensors(progress.ctx, progress.deviceID, progress.deviceKeys)
+	}
+
+	return result
+}
+
+// periodFetch содержит результат запроса телеметрии за один период
+type periodFetch struct {
+	period    timePeriod
+	sensors   []string
+	telemetry map[string][]api.TelemetryPoint
+	err       error
+}
+
+// fetchAndStorePeriods запрашивает телеметрию датчиков за периоды и сохраняет ее.
+// Периоды запрашиваются параллельно группами по PERIOD_FETCH_CONCURRENCY, а сохраняются по одному
+// в исходном порядке, чтобы записи разных периодов не перемежались и интервалы синхронизации
+// расширялись последовательно. Итоги учитываются в progress
+func (c *collector) fetchAndStorePeriods(ctx context.Context, deviceID, kind string, sensorKeys []string, periods []timePeriod,
+	syncState map[string]database.SyncRange, progress *deviceProgress) {
+	// Пропускаем периоды, уже синхронизированные для всех датчиков
+	var fetches []periodFetch
+	for _, period := range periods {
+		sensors := unsyncedSensors(sensorKeys, syncState, period)
+		if len(sensors) > 0 {
+			fetches = append(fetches, periodFetch{period: period, sensors: sensors})
+		}
+	}
+	fetches = c.skipCompletedWindows(ctx, deviceID, fetches)
+
+	for start := 0; start < len(fetches); {
+		// При медленном сохранении периоды запрашиваются с меньшим параллелизмом
+		end := start + c.backpressure.concurrency(c.periodConcurrency)
+		if end > len(fetches) {
+			end = len(fetches)
+		}
+		group := fetches[start:end]
+
+		// Запрашиваем периоды группы параллельно
+		var wg sync.WaitGroup
+		for i := range group {
+			wg.Add(1)
+			go func(f *periodFetch) {
+				defer wg.Done()
+				f.telemetry, f.err = c.weatherAPI.GetTelemetryContext(ctx, deviceID, f.sensors, f.period.from, f.period.to)
+			}(&group[i])
+		}
+		wg.Wait()
+
+		// Сохраняем результаты в порядке периодов
+		for i := range group {
+			f := &group[i]
+			if f.err != nil {
+				logging.Errorf("Ошибка при получении телеметрии для %s датчиков устройства %s за период %s: %v",
+					kind, deviceID, f.period, f.err)
+				progress.failed(f.period)
+				continue
+			}
+			complete := checkPeriodCoverage(deviceID, f.period, f.telemetry)
+
+			// Период отмечается синхронизированным только после сохранения его данных
+			sensors, period := f.sensors, f.period
+			progress.pending.Add(1)
+			c.processAndSaveTelemetry(ctx, deviceID, f.telemetry, func(sensorCounts map[string]int, err error) {
+				defer progress.pending.Done()
+				if err == nil && complete {
+					c.markSynced(ctx, deviceID, sensors, period)
+				}
+				progress.saved(sensorCounts, err)
+			})
+
+			// Освобождаем данные переданного на сохранение периода
+			f.telemetry = nil
+		}
+		start = end
+	}
+}
+
+// enabledSensors возвращает ключи из keys без датчиков, сбор которых отключен.
+// Отключенные датчики выводятся в лог при применении конфигурации, а не для каждого устройства
+func (c *collector) enabledSensors(keys []string) []string {
+	if len(c.disabledSensors) == 0 {
+		return keys
+	}
+
+	enabled := make([]string, 0, len(keys))
+	for _, sensorKey := range keys {
+		if !c.disabledSensors[sensorKey] {
+			enabled = append(enabled, sensorKey)
+		}
+	}
+	return enabled
+}
+
+// deviceSensorKeys возвращает ключи из keys, для которых на устройстве есть активный датчик.
+// Если устройство не сообщает список датчиков, возвращаются все ключи
+func deviceSensorKeys(device api.Device, keys []string) []string {
+	if len(device.Sensors) == 0 {
+		return keys
+	}
+
+	var active, skipped []string
+	for _, sensorKey := range keys {
+		if sensor, ok := device.Sensors[sensorKey]; ok && sensor.Active {
+			active = append(active, sensorKey)
+		} else {
+			skipped = append(skipped, sensorKey)
+		}
+	}
+
+	if len(skipped) > 0 {
+		log.Printf("Устройство %s не имеет активных датчиков %v, они не запрашиваются", device.ID, skipped)
+	}
+
+	return active
+}
+
+// processAndSaveTelemetry обрабатывает полученную телеметрию и сохраняет ее сразу или через очередь записи.
+// После сохранения вызывает saved с количеством сохраненных записей по каждому датчику
+func (c *collector) processAndSaveTelemetry(ctx context.Context, deviceID string, telemetry map[string][]api.TelemetryPoint,
+	saved func(sensorCounts map[string]int, err error)) {
+	// Дополняем направление ветра в градусах, если станция сообщает только румб
+	if c.windDirToDegrees {
+		deriveWindDirDegrees(deviceID, telemetry)
+	}
+
+	// Отбрасываем точки с заведомо ошибочным временем (например, 0 или далекое будущее)
+	c.dropInvalidTimestamps(deviceID, telemetry)
+
+	// Заменяем значения-объекты числом из настроенного поля или строкой JSON
+	c.flattenObjectValues(deviceID, telemetry)
+
+	// Считаем количество полученных записей по каждому датчику и в целом
+	sensorCounts := make(map[string]int, len(telemetry))
+	recordsCount := 0
+	for sensorKey, points := range telemetry {
+		sensorCounts[sensorKey] = len(points)
+		recordsCount += len(points)
+	}
+
+	if recordsCount == 0 {
+		log.Printf("Для устройства %s новых данных не получено", deviceID)
+		saved(nil, nil)
+		return
+	}
+
+	log.Printf("Для устройства %s получено %d новых записей (%s). Сохраняем в базу данных...",
+		deviceID, recordsCount, formatSensorCounts(sensorCounts))
+
+	// Приводим значения к настроенным единицам измерения
+	c.convertUnits(telemetry)
+	c.roundValues(telemetry)
+
+	if c.writes == nil {
+		saved(c.saveTelemetry(ctx, deviceID, telemetry, recordsCount, sensorCounts))
+		return
+	}
+
+	// Сохранение выполняется в горутине записи; при заполненной очереди ожидаем освобождения места
+	err := c.writes.enqueue(ctx, writeJob{
+		deviceID: deviceID,
+		run: func() {
+			saved(c.saveTelemetry(ctx, deviceID, telemetry, recordsCount, sensorCounts))
+		},
+	})
+	if err != nil {
+		log.Printf("Телеметрия устройства %s не передана в очередь записи: %v", deviceID, err)
+		saved(nil, err)
+	}
+}
+
+// saveTelemetry сохраняет подготовленную телеметрию устройства. При ошибке телеметрия помещается в буфер.
+// Возвращает количество сохраненных записей по каждому датчику
+func (c *collector) saveTelemetry(ctx context.Context, deviceID string, telemetry map[string][]api.TelemetryPoint,
+	recordsCount int, sensorCounts map[string]int) (map[string]int, error) {
+	// При медленном сохранении даем хранилищу время на обработку
+	c.backpressure.wait(ctx)
+
+	// Сохраняем телеметрию в базу данных
+	startTime := time.Now()
+	err := c.storeTelemetry(ctx, deviceID, telemetry)
+	c.backpressure.observe(time.Since(startTime))
+	if err != nil {
+		logging.Errorf("Ошибка при сохранении телеметрии для устройства %s: %v", deviceID, err)
+		// Полученные данные сохраняются в буфер и будут записаны в следующих циклах
+		if c.spool != nil && ctx.Err() == nil {
+			c.spool.add(deviceID, telemetry)
+			log.Printf("Телеметрия устройства %s (%d записей) помещена в буфер до восстановления хранилища", deviceID, recordsCount)
+		}
+		return nil, err
+	}
+
+	// Вычисляем, сколько времени заняло сохранение данных. При заданном SLOW_WRITE_THRESHOLD_MS
+	// время выводится только для медленных сохранений
+	elapsed := time.Since(startTime)
+	// Быстрые записи выводятся только при LOG_LEVEL=debug
+	logWrite := logging.Debugf
+	if elapsed >= c.slowWriteThreshold {
+		logWrite = log.Printf
+	}
+	logWrite("Данные для устройства %s успешно сохранены в базу (время: %.2f сек., скорость: %.1f записей/сек.)",
+		deviceID,
+		elapsed.Seconds(),
+		float64(recordsCount)/elapsed.Seconds())
+
+	// Публикуем сохраненные данные (не блокирует сбор при недоступности брокера)
+	if c.publisher != nil {
+		c.publisher.Publish(deviceID, telemetry)
+	}
+
+	return sensorCounts, nil
+}
+
+// dropInvalidTimestamps удаляет из телеметрии точки со временем раньше minValidTime
+// или позже текущего времени более чем на maxFutureSkew и выводит в лог количество удаленных точек по датчикам
+func (c *collector) dropInvalidTimestamps(deviceID string, telemetry map[string][]api.TelemetryPoint) {
+	minTs := c.minValidTime.UnixMilli()
+	maxTs := c.clock.Now().Add(c.maxFutureSkew).UnixMilli()
+
+	tooOld := make(map[string]int)
+	tooNew := make(map[string]int)
+	for sensorKey, points := range telemetry {
+		valid := points[:0]
+		for _, point := range points {
+			switch {
+			case point.Ts < minTs:
+				tooOld[sensorKey]++
+			case point.Ts > maxTs:
+				tooNew[sensorKey]++
+			default:
+				valid = append(valid, point)
+			}
+		}
+		if len(valid) == 0 {
+			delete(telemetry, sensorKey)
+			continue
+		}
+		telemetry[sensorKey] = valid
+	}
+
+	if len(tooOld) > 0 {
+		log.Printf("Для устройства %s отброшены точки со временем раньше %s: %s",
+			deviceID, c.minValidTime.Format("2006-01-02"), formatSensorCounts(tooOld))
+	}
+	if len(tooNew) > 0 {
+		log.Printf("Для устройства %s отброшены точки со временем в будущем (более %s): %s",
+			deviceID, c.maxFutureSkew, formatSensorCounts(tooNew))
+	}
+}
+
+// deriveWindDirDegrees вычисляет значения winddirang по обозначениям румбов winddir,
+// если в полученных данных нет направления ветра в градусах. Неизвестные обозначения пропускаются
+func deriveWindDirDegrees(deviceID string, telemetry map[string][]api.TelemetryPoint) {
+	labels := telemetry["winddir"]
+	if len(labels) == 0 || len(telemetry["winddirang"]) > 0 {
+		return
+	}
+
+	var degrees []api.TelemetryPoint
+	unknown := make(map[string]int)
+	for _, point := range labels {
+		label, ok := point.Value.(string)
+		if !ok {
+			continue
+		}
+		value, ok := units.CompassToDegrees(label)
+		if !ok {
+			unknown[label]++
+			continue
+		}
+		degrees = append(degrees, api.TelemetryPoint{Ts: point.Ts, Value: value})
+	}
+
+	if len(unknown) > 0 {
+		log.Printf("Для устройства %s пропущены неизвестные обозначения направления ветра: %s",
+			deviceID, formatSensorCounts(unknown))
+	}
+
+	if len(degrees) > 0 {
+		telemetry["winddirang"] = degrees
+	}
+}
+
+// loadSyncState возвращает синхронизированные интервалы датчиков станции.
+// Если хранилище не ведет состояние синхронизации или его не удалось получить, возвращается nil
+func (c *collector) loadSyncState(ctx context.Context, deviceID string) map[string]database.SyncRange {
+	store, ok := c.dbManager.(database.SyncStateStore)
+	if !ok {
+		return nil
+	}
+
+	state, err := store.GetSyncState(ctx, deviceID)
+	if err != nil {
+		logging.Errorf("Ошибка при получении состояния синхронизации для %s: %v", deviceID, err)
+		return nil
+	}
+	return state
+}
+
+// markSynced отмечает период как синхронизированный для датчиков станции.
+// Ошибка только выводится в лог: данные уже сохранены, период будет запрошен повторно
+func (c *collector) markSynced(ctx context.Context, deviceID string, sensorKeys []string, period timePeriod) {
+	store, ok := c.dbManager.(database.SyncStateStore)
+	if !ok {
+		return
+	}
+
+	if err := store.UpdateSyncState(ctx, deviceID, sensorKeys, period.from, period.to); err != nil {
+		logging.Errorf("Ошибка при обновлении состояния синхронизации для %s за период %s: %v", deviceID, period, err)
+	}
+
+	windowStore, ok := c.dbManager.(database.SyncWindowStore)
+	if !ok || !windowRepeatable(period, c.clock.Now()) {
+		return
+	}
+	if err := windowStore.MarkWindowsCompleted(ctx, deviceID, periodWindows(sensorKeys, period)); err != nil {
+		logging.Errorf("Ошибка при сохранении завершенного периода %s для %s: %v", period, deviceID, err)
+	}
+}
+
+// windowHashMinAge - минимальный возраст конца периода, завершение которого запоминается
+const windowHashMinAge = 24 * time.Hour
+
+// windowRepeatable сообщает, может ли период быть запрошен повторно в точности с теми же границами.
+// Периоды, заканчивающиеся в последние windowHashMinAge, запрашиваются со сдвигающейся границей
+// и не повторяются, поэтому их завершение не запоминается
+func windowRepeatable(period timePeriod, now time.Time) bool {
+	return period.to < now.Add(-windowHashMinAge).UnixMilli()
+}
+
+// periodWindows возвращает периоды запросов датчиков для хранилища завершенных периодов
+func periodWindows(sensorKeys []string, period timePeriod) []database.SyncWindow {
+	windows := make([]database.SyncWindow, 0, len(sensorKeys))
+	for _, sensorKey := range sensorKeys {
+		windows = append(windows, database.SyncWindow{SensorKey: sensorKey, From: period.from, To: period.to})
+	}
+	return windows
+}
+
+// skipCompletedWindows убирает из запросов датчики, для которых точно такой же период уже был
+// полностью получен и сохранен, и запросы, в которых не осталось датчиков.
+// Если проверить завершенные периоды не удалось, запросы возвращаются без изменений
+func (c *collector) skipCompletedWindows(ctx context.Context, deviceID string, fetches []periodFetch) []periodFetch {
+	store, ok := c.dbManager.(database.SyncWindowStore)
+	if !ok {
+		return fetches
+	}
+
+	now := c.clock.Now()
+	var windows []database.SyncWindow
+	for _, f := range fetches {
+		if windowRepeatable(f.period, now) {
+			windows = append(windows, periodWindows(f.sensors, f.period)...)
+		}
+	}
+	if len(windows) == 0 {
+		return fetches
+	}
+
+	completed, err := store.CompletedWindows(ctx, deviceID, windows)
+	if err != nil {
+		logging.Errorf("Ошибка при проверке завершенных периодов для %s: %v", deviceID, err)
+		return fetches
+	}
+	if len(completed) == 0 {
+		return fetches
+	}
+
+	result := fetches[:0]
+	for _, f := range fetches {
+		var sensors []string
+		for _, sensorKey := range f.sensors {
+			if !completed[database.SyncWindow{SensorKey: sensorKey, From: f.period.from, To: f.period.to}] {
+				sensors = append(sensors, sensorKey)
+			}
+		}
+		if len(sensors) > 0 {
+			f.sensors = sensors
+			result = append(result, f)
+		}
+	}
+	log.Printf("Для устройства %s пропущено %d уже завершенных периодов датчиков", deviceID, len(completed))
+	return result
+}
+
+// unsyncedSensors возвращает датчики, для которых период еще не синхронизирован
+func unsyncedSensors(sensorKeys []string, syncState map[string]database.SyncRange, period timePeriod) []string {
+	var result []string
+	for _, sensorKey := range sensorKeys {
+		if synced, ok := syncState[sensorKey]; ok && synced.Covers(period.from, period.to) {
+			continue
+		}
+		result = append(result, sensorKey)
+	}
+	return result
+}
+
+// storeTelemetry сохраняет телеметрию, передавая контекст хранилищу, если оно это поддерживает
+func (c *collector) storeTelemetry(ctx context.Context, deviceID string, telemetry map[string][]api.TelemetryPoint) error {
+	var err error
+	if sink, ok := c.dbManager.(database.ContextTelemetrySink); ok {
+		err = sink.StoreTelemetryContext(ctx, deviceID, telemetry)
+	} else {
+		err = c.dbManager.StoreTelemetry(deviceID, telemetry)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Передаем сохраненные записи подписчикам потока телеметрии
+	c.stream.Publish(deviceID, telemetry)
+
+	// Дополнительно сохраняем в широкую таблицу, если хранилище ее поддерживает. Данные уже сохранены
+	// в основную таблицу, поэтому ошибка только учитывается: иначе устройство не отмечается
+	// синхронизированным, и те же данные повторно сохраняются и публикуются
+	if wide, ok := c.dbManager.(database.WideTelemetryStore); ok {
+		if err := wide.StoreTelemetryWide(ctx, deviceID, telemetry); err != nil {
+			logging.Errorf("Ошибка при сохранении телеметрии устройства %s в таблицу TelemetryWide: %v", deviceID, err)
+			c.cycles.addWideError()
+		}
+	}
+	return nil
+}
+
+// convertUnits применяет настроенные преобразования единиц измерения к числовым значениям.
+// Нечисловые значения остаются без изменений
+func (c *collector) convertUnits(telemetry map[string][]api.TelemetryPoint) {
+	for sensorKey, points := range telemetry {
+		conv, ok := c.conversions[sensorKey]
+		if !ok {
+			continue
+		}
+
+		for i := range points {
+			value, ok := points[i].Float()
+			if !ok {
+				continue
+			}
+			points[i].Value = conv.Convert(value)
+			points[i].Unit = conv.Unit
+		}
+	}
+}
+
+// roundValues округляет числовые значения до настроенного количества знаков после запятой.
+// Нечисловые значения остаются без изменений
+func (c *collector) roundValues(telemetry map[string][]api.TelemetryPoint) {
+	for sensorKey, points := range telemetry {
+		decimals, ok := c.sensorDecimals[sensorKey]
+		if !ok {
+			decimals = c.valueDecimals
+		}
+		if decimals < 0 {
+			continue
+		}
+
+		for i := range points {
+			value, ok := points[i].Float()
+			if !ok {
+				continue
+			}
+			points[i].Value = units.Round(value, decimals)
+		}
+	}
+}
+
+// formatSensorCounts форматирует количество записей по датчикам в виде "ключ=количество",
+// отсортированных по ключу датчика
+func formatSensorCounts(sensorCounts map[string]int) string {
+	keys := make([]string, 0, len(sensorCounts))
+	for sensorKey := range sensorCounts {
+		keys = append(keys, sensorKey)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, sensorKey := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", sensorKey, sensorCounts[sensorKey]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// timePeriod представляет временной период с началом и концом
+type timePeriod struct {
+	from int64 // начало периода в миллисекундах
+	to   int64 // конец периода в миллисекундах
+}
+
+// String возвращает период в читаемом виде
+func (p timePeriod) String() string {
+	return fmt.Sprintf("%s - %s", formatTs(p.from), formatTs(p.to))
+}
+
+// displayLocation - часовой пояс для вывода времени в логах (задается DISPLAY_TZ)
+var displayLocation = time.Local
+
+// formatTs форматирует timestamp в миллисекундах для вывода в лог в часовом поясе displayLocation
+func formatTs(ts int64) string {
+	return time.UnixMilli(ts).In(displayLocation).Format("2006-01-02 15:04:05")
+}
+
+// formatPeriods форматирует список периодов для вывода в лог
+func formatPeriods(periods []timePeriod) string {
+	parts := make([]string, 0, len(periods))
+	for _, period := range periods {
+		parts = append(parts, "["+period.String()+"]")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// splitTimePeriodByMonth разбивает большой временной период на месячные интервалы
+func splitTimePeriodByMonth(tsFrom, tsTo int64, maxDays int) []timePeriod {
+	var periods []timePeriod
+
+	// Преобразуем timestamp в time.Time для удобства работы с месяцами
+	fromTime := time.Unix(tsFrom/1000, 0)
+	toTime := time.Unix(tsTo/1000, 0)
+
+	// Устанавливаем начало на первый день текущего месяца
+	currentMonth := time.Date(fromTime.Year(), fromTime.Month(), 1, 0, 0, 0, 0, fromTime.Location())
+
+	// Добавляем первый период от начальной даты до конца месяца
+	if fromTime.Day() > 1 {
+		// Начинаем с текущей даты до конца месяца
+		nextMonth := currentMonth.AddDate(0, 1, 0)
+		periodEnd := min(nextMonth.UnixNano()/int64(time.Millisecond), tsTo)
+		periods = append(periods, timePeriod{tsFrom, periodEnd})
+		currentMonth = nextMonth
+	}
+
+	// Добавляем полные месячные периоды
+	for currentMonth.Before(toTime) {
+		nextMonth := currentMonth.AddDate(0, 1, 0)
+		periodStart := currentMonth.UnixNano() / int64(time.Millisecond)
+		periodEnd := nextMonth.UnixNano() / int64(time.Millisecond)
+
+		// Если конец периода выходит за пределы запрашиваемого диапазона, ограничиваем его
+		if periodEnd > tsTo {
+			periodEnd = tsTo
+		}
+
+		periods = append(periods, timePeriod{periodStart, periodEnd})
+		currentMonth = nextMonth
+
+		// Если достигли конечной даты, выходим из цикла
+		if currentMonth.UnixNano()/int64(time.Millisecond) >= tsTo {
+			break
+		}
+	}
+
+	return capPeriods(periods, maxDays)
+}
+
+// capPeriods разбивает периоды длиннее maxDays дней на части не длиннее maxDays.
+// При maxDays <= 0 периоды возвращаются без изменений
+func capPeriods(periods []timePeriod, maxDays int) []timePeriod {
+	if maxDays <= 0 {
+		return periods
+	}
+
+	maxMs := int64(maxDays) * 24 * 60 * 60 * 1000
+	var result []timePeriod
+	for _, period := range periods {
+		for from := period.from; from < period.to; from += maxMs {
+			result = append(result, timePeriod{from, min(from+maxMs, period.to)})
+		}
+	}
+	return result
+}
+
+// coverageTolerance - допустимое расхождение между запрошенным периодом и периодом полученных данных
+const coverageTolerance = 24 * 60 * 60 * 1000 // сутки в миллисекундах
+
+// checkPeriodCoverage сравнивает период полученных данных с запрошенным и предупреждает,
+// если данные охватывают заметно меньший период: API может молча обрезать большие ответы.
+// Возвращает false, если ответ, возможно, обрезан
+func checkPeriodCoverage(deviceID string, period timePeriod, telemetry map[string][]api.TelemetryPoint) bool {
+	var first, last int64
+	for _, points := range telemetry {
+		for _, point := range points {
+			if first == 0 || point.Ts < first {
+				first = point.Ts
+			}
+			if point.Ts > last {
+				last = point.Ts
+			}
+		}
+	}
+
+	// Отсутствие данных не считается обрезанным ответом
+	if first == 0 {
+		return true
+	}
+
+	requested := period.to - period.from
+	received := last - first
+	if requested-received > coverageTolerance {
+		logging.Warnf("Внимание: для устройства %s за период %s получены данные только за %s (%.1f из %.1f дней). Возможно, ответ API обрезан",
+			deviceID, period, timePeriod{first, last}, float64(received)/coverageTolerance, float64(requested)/coverageTolerance)
+		return false
+	}
+	return true
+}
+
+// splitTimePeriodByDays разбивает большой временной период на интервалы по указанному количеству дней
+func splitTimePeriodByDays(tsFrom, tsTo int64, days int, maxDays int) []timePeriod {
+	if maxDays > 0 && days > maxDays {
+		days = maxDays
+	}
+
+	var periods []timePeriod
+
+	// Вычисляем длину одного интервала в миллисекундах
+	intervalMs := int64(days * 24 * 60 * 60 * 1000)
+
+	// Начальная точка
+	current := tsFrom
+
+	// Разбиваем период на интервалы указанной длины
+	for current < tsTo {
+		periodEnd := current + intervalMs
+		if periodEnd > tsTo {
+			periodEnd = tsTo
+		}
+
+		periods = append(periods, timePeriod{current, periodEnd})
+		current = periodEnd
+	}
+
+	return periods
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/clock"
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/database"
+	"weatherInTheField/pkg/notify"
+)
+
+// blockingClient - клиент API, запрос списка устройств которого ожидает разрешения теста
+type blockingClient struct {
+	api.WeatherClient
+
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingClient) GetDevicesCached(ctx context.Context, ttl time.Duration) ([]api.Device, error) {
+	b.calls.Add(1)
+	b.started <- struct{}{}
+	<-b.release
+	return nil, errors.New("API недоступно")
+}
+
+// newTestCollector создает сборщик с указанным клиентом API и хранилищем без дополнительных возможностей
+func newTestCollector(client api.WeatherClient) *collector {
+	return &collector{
+		weatherAPI: client,
+		dbManager:  struct{ database.TelemetryStore }{},
+		clock:      clock.Real,
+		alerts:     notify.NewAlerter(notify.NopNotifier{}, 1),
+	}
+}
+
+func TestRunCycleSkipsOverlappingTick(t *testing.T) {
+	client := &blockingClient{started: make(chan struct{}, 2), release: make(chan struct{})}
+	c := newTestCollector(client)
+
+	firstDone := make(chan struct{})
+	go func() {
+		c.runCycle(context.Background(), 0)
+		close(firstDone)
+	}()
+	<-client.started
+
+	// Второй запуск, пока первый цикл выполняется, должен сразу завершиться без сбора данных
+	secondDone := make(chan struct{})
+	go func() {
+		c.runCycle(context.Background(), 0)
+		close(secondDone)
+	}()
+	select {
+	case <-secondDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("второй запуск ожидает завершения первого цикла вместо пропуска")
+	}
+
+	close(client.release)
+	<-firstDone
+
+	if calls := client.calls.Load(); calls != 1 {
+		t.Fatalf("циклов сбора выполнено %d, ожидался 1", calls)
+	}
+
+	// После завершения цикла следующий запуск выполняется
+	c.runCycle(context.Background(), 0)
+	if calls := client.calls.Load(); calls != 2 {
+		t.Errorf("циклов сбора после завершения первого %d, ожидалось 2", calls)
+	}
+}
+
+// failingWideStore - хранилище, которое сохраняет телеметрию в основную таблицу, но не в TelemetryWide
+type failingWideStore struct {
+	database.TelemetryStore
+	stored int
+}
+
+func (s *failingWideStore) StoreTelemetry(deviceID string, data map[string][]api.TelemetryPoint) error {
+	s.stored++
+	return nil
+}
+
+func (s *failingWideStore) StoreTelemetryWide(ctx context.Context, deviceID string, data map[string][]api.TelemetryPoint) error {
+	return errors.New("таблица TelemetryWide недоступна")
+}
+
+func TestStoreTelemetryIgnoresWideError(t *testing.T) {
+	store := &failingWideStore{}
+	c := newTestCollector(nil)
+	c.dbManager = store
+	c.cycles.start(time.Now())
+
+	err := c.storeTelemetry(context.Background(), "st-1", map[string][]api.TelemetryPoint{
+		"airtemp": {{Ts: 1000, Value: 1.5}},
+	})
+	if err != nil {
+		t.Fatalf("ошибка TelemetryWide прервала сохранение: %v", err)
+	}
+	if store.stored != 1 {
+		t.Errorf("сохранений в основную таблицу %d, ожидалось 1", store.stored)
+	}
+	if wideErrors := c.cycles.snapshot().WideErrors; wideErrors != 1 {
+		t.Errorf("учтено ошибок TelemetryWide %d, ожидалась 1", wideErrors)
+	}
+}
+
+// stationClient - клиент API с фиксированным списком устройств и одной точкой телеметрии на датчик
+type stationClient struct {
+	api.WeatherClient
+	devices []api.Device
+}
+
+func (s *stationClient) GetDevicesCached(ctx context.Context, ttl time.Duration) ([]api.Device, error) {
+	return s.devices, nil
+}
+
+func (s *stationClient) GetTelemetryContext(ctx context.Context, deviceID string, keys []string, tsFrom, tsTo int64) (map[string][]api.TelemetryPoint, error) {
+	telemetry := make(map[string][]api.TelemetryPoint, len(keys))
+	for _, key := range keys {
+		telemetry[key] = []api.TelemetryPoint{{Ts: tsTo - 1000, Value: 1.5}}
+	}
+	return telemetry, nil
+}
+
+// orderedStore - хранилище, записывающее порядок сохранения станций и телеметрии. Общее сохранение
+// нескольких станций и сохранение станций из failStations завершаются ошибкой
+type orderedStore struct {
+	database.TelemetryStore
+	failStations map[string]bool
+
+	mu     sync.Mutex
+	events []string
+}
+
+func (s *orderedStore) record(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func (s *orderedStore) StoreStations(devices []api.Device) error {
+	if len(devices) > 1 {
+		return errors.New("ошибка общего сохранения станций")
+	}
+	for _, device := range devices {
+		if s.failStations[device.ID] {
+			return errors.New("ошибка сохранения станции")
+		}
+		s.record("station " + device.ID)
+	}
+	return nil
+}
+
+func (s *orderedStore) StoreTelemetry(deviceID string, data map[string][]api.TelemetryPoint) error {
+	s.record("telemetry " + deviceID)
+	return nil
+}
+
+func (s *orderedStore) GetLatestTimestamps(stationID string) (map[string]int64, error) {
+	// Данные сохранены недавно - история не загружается
+	return map[string]int64{"airtemp": time.Now().Add(-time.Hour).UnixMilli()}, nil
+}
+
+func (s *orderedStore) GetStations() ([]string, error) {
+	return nil, nil
+}
+
+func TestCollectDataStoresMissingStationFirst(t *testing.T) {
+	client := &stationClient{devices: []api.Device{{ID: "st-1"}, {ID: "st-2"}}}
+	store := &orderedStore{failStations: map[string]bool{"st-2": true}}
+	c := newTestCollector(client)
+	c.dbManager = store
+	c.sensorKeys = []string{"airtemp"}
+
+	c.collectData(context.Background())
+
+	// st-1 сохраняется отдельно перед телеметрией, st-2 без сохраненной станции пропускается
+	want := []string{"station st-1", "telemetry st-1"}
+	if strings.Join(store.events, ", ") != strings.Join(want, ", ") {
+		t.Errorf("порядок сохранения %v, ожидался %v", store.events, want)
+	}
+}
+
+// refreshStore - хранилище, считающее сохранения станций и телеметрии
+type refreshStore struct {
+	database.TelemetryStore
+
+	stationSaves atomic.Int32
+	telemetry    atomic.Int32
+}
+
+func (s *refreshStore) StoreStations(devices []api.Device) error {
+	if len(devices) == 2 {
+		s.stationSaves.Add(1)
+	}
+	return nil
+}
+
+func (s *refreshStore) StoreTelemetry(deviceID string, data map[string][]api.TelemetryPoint) error {
+	s.telemetry.Add(1)
+	return nil
+}
+
+func TestServeRefreshesStationsBetweenCycles(t *testing.T) {
+	client := &stationClient{devices: []api.Device{{ID: "st-1"}, {ID: "st-2"}}}
+	store := &refreshStore{}
+	c := newTestCollector(client)
+	c.dbManager = store
+	c.sensorKeys = []string{"airtemp"}
+
+	// Сбор раз в час, обновление станций - намного чаще
+	c.schedule = newStationSchedule(time.Hour, nil, 0)
+	c.stationRefresh.setInterval(20 * time.Millisecond)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		c.serve(&config.Config{}, nil, done)
+		close(stopped)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for store.stationSaves.Load() < 3 {
+		select {
+		case <-deadline:
+			t.Fatalf("обновлений станций %d, ожидалось не меньше 3", store.stationSaves.Load())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	close(done)
+	<-stopped
+
+	// Станции обновлялись без циклов сбора
+	if n := store.telemetry.Load(); n != 0 {
+		t.Errorf("сохранений телеметрии %d, ожидалось 0: цикл сбора не должен был запускаться", n)
+	}
+	if got := c.stationRefresh.newDevices(client.devices); len(got) != 0 {
+		t.Errorf("станции %v не отмечены сохраненными после обновления", got)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/database"
+)
+
+// cycleState описывает последний цикл сбора данных
+type cycleState struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Devices    int       `json:"devices"`
+
+	// Количество ошибок сохранения в таблицу TelemetryWide (основное сохранение при этом выполнено)
+	WideErrors int `json:"wide_errors"`
+
+	stations []string
+}
+
+// cycleTracker хранит состояние последнего цикла сбора для отладочного эндпоинта
+type cycleTracker struct {
+	mu   sync.Mutex
+	last cycleState
+}
+
+// start отмечает начало цикла сбора
+func (t *cycleTracker) start(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last = cycleState{StartedAt: now}
+}
+
+// setDevices запоминает станции, полученные в текущем цикле
+func (t *cycleTracker) setDevices(devices []api.Device) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.last.Devices = len(devices)
+	t.last.stations = make([]string, 0, len(devices))
+	for _, device := range devices {
+		t.last.stations = append(t.last.stations, device.ID)
+	}
+}
+
+// addWideError учитывает ошибку сохранения в таблицу TelemetryWide
+func (t *cycleTracker) addWideError() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last.WideErrors++
+}
+
+// finish отмечает завершение цикла сбора
+func (t *cycleTracker) finish(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last.FinishedAt = now
+}
+
+// snapshot возвращает копию состояния последнего цикла
+func (t *cycleTracker) snapshot() cycleState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := t.last
+	state.stations = append([]string(nil), t.last.stations...)
+	return state
+}
+
+// debugState - ответ отладочного эндпоинта
+type debugState struct {
+	LastCycle *cycleState                 `json:"last_cycle"`
+	Stations  map[string]map[string]int64 `json:"stations"`
+	Session   api.SessionInfo             `json:"session"`
+	Storage   *database.DBStats           `json:"storage,omitempty"`
+	Spool     *spoolState                 `json:"spool,omitempty"`
+
+	// Заполненность очереди записи (отсутствует, если очередь отключена)
+	WriteQueue *writeQueueState `json:"write_queue,omitempty"`
+
+	// Текущая степень снижения нагрузки на хранилище (0 - нагрузка не снижается)
+	BackpressureLevel int `json:"backpressure_level"`
+
+	// Состояние выключателя, приостанавливающего сбор при недоступности API
+	CircuitBreaker breakerState `json:"circuit_breaker"`
+
+	// Станции на редком опросе после повторяющихся ошибок
+	DemotedStations map[string]demotedStation `json:"demoted_stations"`
+}
+
+// unwrapDryRun возвращает исходное хранилище, если store - хранилище пробного режима.
+// Используется для возможностей только для чтения, которые пробный режим не скрывает
+func unwrapDryRun(store database.TelemetryStore) database.TelemetryStore {
+	if dryRun, ok := store.(*database.DryRunStore); ok {
+		return dryRun.TelemetryStore
+	}
+	return store
+}
+
+// debugState собирает состояние сервиса: последний цикл сбора, время последних сохраненных
+// данных по станциям и состояние сессии API
+func (c *collector) debugState(weatherAPI *api.WeatherAPI) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		state := debugState{
+			Stations: make(map[string]map[string]int64),
+			Session:  weatherAPI.SessionInfo(),
+		}
+
+		state.BackpressureLevel = c.backpressure.currentLevel()
+		state.CircuitBreaker = c.breaker.snapshot()
+		state.DemotedStations = c.demotion.snapshot()
+
+		if c.spool != nil {
+			spool := c.spool.state()
+			state.Spool = &spool
+		}
+
+		if c.writes != nil {
+			writes := c.writes.state()
+			state.WriteQueue = &writes
+		}
+
+		cycle := c.cycles.snapshot()
+		if !cycle.StartedAt.IsZero() {
+			state.LastCycle = &cycle
+		}
+
+		// Если циклов еще не было, берем станции из хранилища
+		stations := cycle.stations
+		if len(stations) == 0 {
+			var err error
+			if stations, err = c.dbManager.GetStations(); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, stationID := range stations {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			latest, err := c.dbManager.GetLatestTimestamps(stationID)
+			if err != nil {
+				return nil, err
+			}
+			state.Stations[stationID] = latest
+		}
+
+		// Статистика хранилища, если хранилище ее поддерживает (в пробном режиме - исходного хранилища)
+		if reporter, ok := unwrapDryRun(c.dbManager).(database.StatsReporter); ok {
+			stats, err := reporter.Stats()
+			if err != nil {
+				return nil, err
+			}
+			state.Storage = &stats
+		}
+
+		return state, nil
+	}
+}
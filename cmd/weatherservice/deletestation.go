@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/database"
+)
+
+// deleteStation удаляет станцию и ее телеметрию из базы данных после подтверждения в in.
+// Запросы к API не выполняются
+func deleteStation(cfg *config.Config, stationID string, in io.Reader, out io.Writer) error {
+	if cfg.Sink != config.SinkMSSQL {
+		return fmt.Errorf("удаление станций поддерживается только для хранилища %s", config.SinkMSSQL)
+	}
+	if stationID == "" {
+		return fmt.Errorf("не указан ID станции")
+	}
+
+	fmt.Fprintf(out, "Станция %s и вся ее телеметрия будут удалены без возможности восстановления. Продолжить? [y/N]: ", stationID)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("ошибка при чтении подтверждения: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes", "д", "да":
+	default:
+		fmt.Fprintln(out, "Удаление отменено")
+		return nil
+	}
+
+	dbManager, err := database.NewDBManager(cfg, databaseOptions(cfg)...)
+	if err != nil {
+		return fmt.Errorf("ошибка при подключении к БД: %w", err)
+	}
+	defer dbManager.Close()
+
+	deleted, err := dbManager.DeleteStation(stationID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Станция %s удалена, удалено записей телеметрии: %d\n", stationID, deleted)
+	return nil
+}
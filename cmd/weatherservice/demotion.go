@@ -0,0 +1,129 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"weatherInTheField/pkg/api"
+)
+
+// stationErrors - ошибки станции в циклах сбора подряд
+type stationErrors struct {
+	failures  int
+	demotedAt time.Time // время перевода на редкий опрос (нулевое - станция опрашивается как обычно)
+	retryAt   time.Time // время следующей попытки опроса переведенной станции
+}
+
+// demotedStation - станция на редком опросе для отладочного эндпоинта
+type demotedStation struct {
+	Failures  int       `json:"failures"`
+	DemotedAt time.Time `json:"demoted_at"`
+	RetryAt   time.Time `json:"retry_at"`
+}
+
+// stationDemotion отслеживает ошибки станций в циклах сбора подряд. После threshold циклов с ошибками
+// станция переводится на редкий опрос: она запрашивается не чаще одного раза в retryInterval,
+// а остальные станции опрашиваются как обычно. Первый успешный цикл возвращает обычный опрос
+type stationDemotion struct {
+	mu            sync.Mutex
+	threshold     int // количество циклов с ошибками подряд (0 - станции не переводятся)
+	retryInterval time.Duration
+	stations      map[string]*stationErrors
+}
+
+// configure задает порог и интервал опроса переведенных станций. При отключении все станции
+// возвращаются к обычному опросу
+func (d *stationDemotion) configure(threshold int, retryInterval time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.threshold = threshold
+	d.retryInterval = retryInterval
+	if threshold <= 0 {
+		d.stations = nil
+	}
+}
+
+// due сообщает, нужно ли опрашивать станцию: переведенная на редкий опрос станция опрашивается
+// по наступлении времени следующей попытки
+func (d *stationDemotion) due(stationID string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.stations[stationID]
+	if !ok || state.demotedAt.IsZero() {
+		return true
+	}
+	return !now.Before(state.retryAt)
+}
+
+// failure отмечает цикл с ошибками станции. Возвращает количество ошибок подряд и true,
+// если станция только что переведена на редкий опрос
+func (d *stationDemotion) failure(stationID string, now time.Time) (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.threshold <= 0 {
+		return 0, false
+	}
+	if d.stations == nil {
+		d.stations = make(map[string]*stationErrors)
+	}
+	state, ok := d.stations[stationID]
+	if !ok {
+		state = &stationErrors{}
+		d.stations[stationID] = state
+	}
+
+	state.failures++
+	if state.failures < d.threshold {
+		return state.failures, false
+	}
+
+	demoted := state.demotedAt.IsZero()
+	if demoted {
+		state.demotedAt = now
+	}
+	state.retryAt = now.Add(d.retryInterval)
+	return state.failures, demoted
+}
+
+// success отмечает успешный цикл станции. Возвращает true, если станция была на редком опросе
+func (d *stationDemotion) success(stationID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.stations[stationID]
+	if !ok {
+		return false
+	}
+	delete(d.stations, stationID)
+	return !state.demotedAt.IsZero()
+}
+
+// dueDevices возвращает устройства, которые нужно опрашивать, и ID пропущенных станций на редком опросе
+func (d *stationDemotion) dueDevices(devices []api.Device, now time.Time) (due []api.Device, skipped []string) {
+	for _, device := range devices {
+		if d.due(device.ID, now) {
+			due = append(due, device)
+		} else {
+			skipped = append(skipped, device.ID)
+		}
+	}
+	return due, skipped
+}
+
+// snapshot возвращает станции на редком опросе
+func (d *stationDemotion) snapshot() map[string]demotedStation {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := make(map[string]demotedStation)
+	for stationID, state := range d.stations {
+		if state.demotedAt.IsZero() {
+			continue
+		}
+		result[stationID] = demotedStation{Failures: state.failures, DemotedAt: state.demotedAt, RetryAt: state.retryAt}
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/config"
+)
+
+// sensorUsage - сведения о датчике по всем станциям
+type sensorUsage struct {
+	key      string
+	name     string
+	stations int // количество станций, сообщающих датчик
+	active   int // количество станций, на которых датчик активен
+}
+
+// discoverSensors выводит отчет о ключах датчиков, которые сообщают станции (поле sensors списка устройств):
+// на скольких станциях датчик есть и активен и собирается ли он (SENSOR_KEYS). Учитываются только станции,
+// соответствующие DEVICE_LABEL_REGEX. База данных не используется
+func discoverSensors(cfg *config.Config, client api.WeatherClient, out io.Writer) error {
+	devices, err := client.GetDevices()
+	if err != nil {
+		return fmt.Errorf("ошибка при получении списка устройств: %w", err)
+	}
+	if cfg.DeviceLabelPattern != nil {
+		devices = filterDevicesByLabel(devices, cfg.DeviceLabelPattern)
+	}
+
+	usage := make(map[string]*sensorUsage)
+	withoutSensors := 0
+	for _, device := range devices {
+		if len(device.Sensors) == 0 {
+			withoutSensors++
+			continue
+		}
+		for key, sensor := range device.Sensors {
+			u, ok := usage[key]
+			if !ok {
+				u = &sensorUsage{key: key}
+				usage[key] = u
+			}
+			if u.name == "" {
+				u.name = sensor.Name
+			}
+			u.stations++
+			if sensor.Active {
+				u.active++
+			}
+		}
+	}
+
+	collected := make(map[string]bool)
+	for _, key := range sensorKeysFor(cfg) {
+		collected[key] = true
+	}
+
+	// Первыми выводятся датчики, активные на большем количестве станций
+	sensors := make([]*sensorUsage, 0, len(usage))
+	for _, u := range usage {
+		sensors = append(sensors, u)
+	}
+	sort.Slice(sensors, func(i, j int) bool {
+		if sensors[i].active != sensors[j].active {
+			return sensors[i].active > sensors[j].active
+		}
+		return sensors[i].key < sensors[j].key
+	})
+
+	fmt.Fprintf(out, "Станций: %d, из них без списка датчиков: %d\n\n", len(devices), withoutSensors)
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "КЛЮЧ\tНАЗВАНИЕ\tСТАНЦИЙ\tАКТИВЕН\tСОБИРАЕТСЯ")
+	var notCollected []string
+	for _, u := range sensors {
+		mark := "нет"
+		if collected[u.key] {
+			mark = "да"
+		} else if u.active > 0 {
+			notCollected = append(notCollected, u.key)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", u.key, u.name, u.stations, u.active, mark)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, key := range sensorKeysFor(cfg) {
+		if u, ok := usage[key]; !ok || u.active == 0 {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(notCollected) > 0 {
+		fmt.Fprintf(out, "\nАктивные датчики, которые не собираются: %v\n", notCollected)
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(out, "\nСобираемые датчики, не активные ни на одной станции: %v\n", missing)
+	}
+
+	return nil
+}
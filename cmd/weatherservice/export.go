@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/database"
+)
+
+// exportParquet выгружает телеметрию станции за период в файл Parquet.
+// Используется только база данных, запросы к API не выполняются
+func exportParquet(cfg *config.Config, path, stationID, fromRaw, toRaw string) error {
+	if stationID == "" {
+		return fmt.Errorf("не указана станция (--station)")
+	}
+
+	to := time.Now()
+	if toRaw != "" {
+		t, err := parseExportTime(toRaw)
+		if err != nil {
+			return fmt.Errorf("некорректный параметр --to: %w", err)
+		}
+		to = t
+	}
+
+	if fromRaw == "" {
+		return fmt.Errorf("не указано начало периода (--from)")
+	}
+	from, err := parseExportTime(fromRaw)
+	if err != nil {
+		return fmt.Errorf("некорректный параметр --from: %w", err)
+	}
+
+	if !from.Before(to) {
+		return fmt.Errorf("начало периода должно быть раньше конца")
+	}
+
+	dbManager, err := database.NewDBManager(cfg)
+	if err != nil {
+		return fmt.Errorf("ошибка при подключении к БД: %w", err)
+	}
+	defer dbManager.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании файла: %w", err)
+	}
+
+	if err := dbManager.ExportParquet(file, stationID, from, to); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// parseExportTime разбирает время в формате RFC3339 или дату в формате ГГГГ-ММ-ДД
+// (в часовом поясе DISPLAY_TZ)
+func parseExportTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", raw, displayLocation)
+}
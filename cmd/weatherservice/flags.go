@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// envFlag - флаг командной строки, переопределяющий переменную окружения
+type envFlag struct {
+	name  string
+	env   string
+	usage string
+}
+
+// envFlags - параметры конфигурации, которые можно задать флагами для разовых запусков.
+// Приоритет: флаг, затем переменная окружения (в том числе из .env), затем значение по умолчанию
+var envFlags = []envFlag{
+	{"interval", "COLLECTION_INTERVAL", "интервал сбора данных в минутах (переопределяет COLLECTION_INTERVAL)"},
+	{"api-url", "API_BASE_URL", "базовый URL API (переопределяет API_BASE_URL)"},
+	{"db-name", "DB_NAME", "имя базы данных (переопределяет DB_NAME)"},
+	{"log-level", "LOG_LEVEL", "уровень журнала: debug, info, warn или error (переопределяет LOG_LEVEL)"},
+}
+
+// registerEnvFlags регистрирует флаги, переопределяющие переменные окружения
+func registerEnvFlags(fs *flag.FlagSet) {
+	for _, f := range envFlags {
+		fs.String(f.name, "", f.usage)
+	}
+}
+
+// applyEnvFlags записывает значения явно указанных флагов в переменные окружения до загрузки конфигурации.
+// Конфигурация загружается и проверяется как обычно, а значения из .env не заменяют уже заданные переменные.
+// Переопределения сохраняются и при перечитывании конфигурации по SIGHUP
+func applyEnvFlags(fs *flag.FlagSet) error {
+	envByFlag := make(map[string]string, len(envFlags))
+	for _, f := range envFlags {
+		envByFlag[f.name] = f.env
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		env, ok := envByFlag[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		if setErr := os.Setenv(env, f.Value.String()); setErr != nil {
+			err = fmt.Errorf("ошибка при применении флага --%s: %w", f.Name, setErr)
+		}
+	})
+	return err
+}
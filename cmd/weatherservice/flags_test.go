@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"testing"
+
+	"weatherInTheField/pkg/logging"
+)
+
+func TestApplyEnvFlags(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "info")
+	t.Setenv("COLLECTION_INTERVAL", "15")
+	t.Setenv("DB_NAME", "FromEnv")
+
+	fs := flag.NewFlagSet("weatherservice", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	registerEnvFlags(fs)
+	if err := fs.Parse([]string{"--log-level", "debug", "--interval", "5"}); err != nil {
+		t.Fatalf("ошибка при разборе флагов: %v", err)
+	}
+	if err := applyEnvFlags(fs); err != nil {
+		t.Fatalf("ошибка при применении флагов: %v", err)
+	}
+
+	// Заданные флаги переопределяют переменные окружения, остальные переменные не меняются
+	want := map[string]string{"LOG_LEVEL": "debug", "COLLECTION_INTERVAL": "5", "DB_NAME": "FromEnv"}
+	for env, value := range want {
+		if got := os.Getenv(env); got != value {
+			t.Errorf("%s=%q, ожидалось %q", env, got, value)
+		}
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	previous := logging.CurrentLevel()
+	t.Cleanup(func() { logging.SetLevel(previous) })
+
+	if err := setLogLevel("warn"); err != nil {
+		t.Fatalf("ошибка при установке уровня журнала: %v", err)
+	}
+	if logging.CurrentLevel() != logging.LevelWarn {
+		t.Errorf("уровень журнала %s, ожидался warn", logging.CurrentLevel())
+	}
+	if err := setLogLevel("verbose"); err == nil {
+		t.Error("уровень verbose принят, ожидалась ошибка")
+	}
+	if logging.CurrentLevel() != logging.LevelWarn {
+		t.Errorf("некорректный уровень изменил журнал: %s", logging.CurrentLevel())
+	}
+}
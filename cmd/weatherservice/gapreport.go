@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/database"
+)
+
+// printGapReport выводит пропуски в данных датчиков станции, длиннее ожидаемого интервала между измерениями.
+// Используется только база данных, запросы к API не выполняются
+func printGapReport(cfg *config.Config, stationID string, intervalMinutes int, out io.Writer) error {
+	if cfg.Sink != config.SinkMSSQL {
+		return fmt.Errorf("отчет о пропусках поддерживается только для хранилища %s", config.SinkMSSQL)
+	}
+	if intervalMinutes <= 0 {
+		return fmt.Errorf("ожидаемый интервал --gap-interval должен быть больше нуля")
+	}
+
+	dbManager, err := database.NewDBManager(cfg)
+	if err != nil {
+		return fmt.Errorf("ошибка при подключении к БД: %w", err)
+	}
+	defer dbManager.Close()
+
+	gaps, err := dbManager.GapReport(stationID, intervalMinutes)
+	if err != nil {
+		return err
+	}
+
+	if len(gaps) == 0 {
+		fmt.Fprintf(out, "Пропусков длиннее %d минут в данных станции %s нет\n", intervalMinutes, stationID)
+		return nil
+	}
+
+	fmt.Fprintf(out, "Пропуски длиннее %d минут в данных станции %s: %d\n\n", intervalMinutes, stationID, len(gaps))
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ДАТЧИК\tС\tПО\tМИНУТ")
+	for _, gap := range gaps {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", gap.SensorKey, formatTs(gap.From), formatTs(gap.To), gap.DurationMinutes)
+	}
+
+	return tw.Flush()
+}
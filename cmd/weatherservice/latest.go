@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/database"
+	"weatherInTheField/pkg/logging"
+)
+
+// storeLatestReadings сохраняет последние показания датчиков из списка устройств в таблицу LatestReadings.
+// Показания проходят те же преобразования, что и телеметрия, поэтому текущие значения доступны
+// сразу после получения списка устройств, до запроса истории. Ошибки только записываются в журнал
+func (c *collector) storeLatestReadings(ctx context.Context, devices []api.Device) {
+	store, ok := c.dbManager.(database.LatestReadingsStore)
+	if !ok {
+		return
+	}
+
+	collected := make(map[string]bool, len(c.sensorKeys))
+	for _, sensorKey := range c.enabledSensors(c.sensorKeys) {
+		collected[sensorKey] = true
+	}
+
+	stored := 0
+	for _, device := range devices {
+		readings := device.LastReadings()
+		for sensorKey := range readings {
+			if !collected[sensorKey] {
+				delete(readings, sensorKey)
+			}
+		}
+
+		c.dropInvalidTimestamps(device.ID, readings)
+		c.flattenObjectValues(device.ID, readings)
+		if len(readings) == 0 {
+			continue
+		}
+		c.convertUnits(readings)
+		c.roundValues(readings)
+
+		if err := store.StoreLatestReadings(ctx, device.ID, readings); err != nil {
+			logging.Errorf("Ошибка при сохранении последних показаний устройства %s: %v", device.ID, err)
+			continue
+		}
+		stored++
+	}
+
+	if stored > 0 {
+		log.Printf("Сохранены последние показания датчиков станций: %d", stored)
+	}
+}
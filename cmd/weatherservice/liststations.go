@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"weatherInTheField/pkg/api"
+)
+
+// listStations выводит таблицу метеостанций с временем последнего сообщения.
+// Станции сортируются по времени последнего сообщения: первыми выводятся давно не выходившие на связь
+func listStations(client api.WeatherClient, out io.Writer) error {
+	devices, err := client.GetDevices()
+	if err != nil {
+		return fmt.Errorf("ошибка при получении списка устройств: %w", err)
+	}
+
+	sort.SliceStable(devices, func(i, j int) bool {
+		return devices[i].LastMsg < devices[j].LastMsg
+	})
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "МЕТКА\tID\tШИРОТА\tДОЛГОТА\tЗАРЯД\tПОСЛЕДНЕЕ СООБЩЕНИЕ")
+	for _, device := range devices {
+		lastMsg := "-"
+		if device.LastMsg > 0 {
+			lastMsg = formatTs(device.LastMsg)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.6f\t%.6f\t%.1f\t%s\n",
+			device.Label, device.ID, device.Latitude, device.Longitude, device.BatteryCharge, lastMsg)
+	}
+
+	return tw.Flush()
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"os/signal"
 	"sync"
@@ -9,343 +13,424 @@ import (
 	"time"
 
 	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/clock"
 	"weatherInTheField/pkg/config"
 	"weatherInTheField/pkg/database"
+	"weatherInTheField/pkg/influx"
+	"weatherInTheField/pkg/logging"
+	"weatherInTheField/pkg/notify"
+	"weatherInTheField/pkg/publisher"
+	"weatherInTheField/pkg/server"
+	"weatherInTheField/pkg/tracing"
+	"weatherInTheField/pkg/units"
 )
 
-// Определяем ключи датчиков, которые нам нужны
-var sensorKeys = []string{
-	"airtemp",        // Температура воздуха
-	"soiltemp",       // Температура почвы
-	"airmoist",       // Влажность воздуха
-	"rainfall",       // Количество осадков
-	"rainfall_daily", // Количество осадков за предыдущие сутки
-	"windspeed",      // Скорость ветра
-	"windspeedmax",   // Порывы ветра
-	"winddir",        // Направление ветра
-	"winddirang",     // Направление ветра в градусах
-}
-
 func main() {
-	// Загружаем конфигурацию
-	cfg := config.LoadConfig()
-
-	// Инициализируем API клиент
-	weatherAPI := api.NewWeatherAPI(cfg)
+	discoverSensorsFlag := flag.Bool("discover-sensors", false, "вывести отчет о датчиках, которые сообщают станции, с отметкой собираемых и завершить работу")
+	listStationsFlag := flag.Bool("list-stations", false, "вывести список метеостанций с временем последнего сообщения и завершить работу")
+	validateFlag := flag.Bool("validate", false, "проверить конфигурацию, вход в API, подключение к хранилищу и схему базы данных и завершить работу")
+	statsFlag := flag.Bool("stats", false, "вывести статистику хранилища (количество записей, период данных, размер таблицы) и завершить работу")
+	exportParquetPath := flag.String("export-parquet", "", "выгрузить телеметрию станции в указанный файл Parquet и завершить работу")
+	deleteStationID := flag.String("delete-station", "", "удалить станцию с указанным ID и всю ее телеметрию (с подтверждением) и завершить работу")
+	gapReportStation := flag.String("gap-report", "", "вывести пропуски в данных датчиков станции с указанным ID и завершить работу")
+	gapInterval := flag.Int("gap-interval", server.DefaultGapIntervalMinutes, "ожидаемый интервал между измерениями в минутах для отчета о пропусках")
+	reprocessFlag := flag.Bool("reprocess", false, "заново заполнить производные таблицы (TelemetryWide) по сырым данным за период и завершить работу")
+	repairDateValuesFlag := flag.Bool("repair-datevalues", false, "пересчитать DateValue сохраненных строк по Timestamp в UTC и завершить работу")
+	repairBatchSize := flag.Int("repair-batch-size", database.DefaultDateValueBatchSize, "количество строк, исправляемых в одной транзакции при --repair-datevalues")
+	exportStation := flag.String("station", "", "ID станции для выгрузки")
+	exportFrom := flag.String("from", "", "начало периода выгрузки или пересчета (RFC3339 или ГГГГ-ММ-ДД)")
+	exportTo := flag.String("to", "", "конец периода выгрузки или пересчета (RFC3339 или ГГГГ-ММ-ДД, по умолчанию текущее время)")
+	registerEnvFlags(flag.CommandLine)
+	flag.Parse()
+
+	// Флаги переопределяют переменные окружения
+	if err := applyEnvFlags(flag.CommandLine); err != nil {
+		logging.Fatalf("Ошибка в параметрах командной строки: %v", err)
+	}
 
-	// Логин в API
-	if err := weatherAPI.Login(); err != nil {
-		log.Fatalf("Ошибка при авторизации: %v", err)
+	// Режим предварительной проверки: сбор данных не запускается, данные не изменяются
+	if *validateFlag {
+		os.Exit(validate(os.Stdout))
 	}
 
-	// Инициализируем менеджер БД
-	dbManager, err := database.NewDBManager(cfg)
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Ошибка при подключении к БД: %v", err)
+		logging.Fatalf("Ошибка в конфигурации: %v", err)
 	}
-	defer dbManager.Close()
 
-	// Создаем таблицы, если они не существуют
-	if err := dbManager.CreateTablesIfNotExists(); err != nil {
-		log.Fatalf("Ошибка при создании таблиц: %v", err)
+	// Уровень журнала (проверен при загрузке конфигурации, меняется по SIGHUP)
+	if err := setLogLevel(cfg.LogLevel); err != nil {
+		logging.Fatalf("Ошибка в конфигурации: %v", err)
 	}
+	logging.Install(os.Stderr)
 
-	// Канал для остановки сервиса
-	stopChan := make(chan os.Signal, 1)
-	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
-
-	// Запускаем регулярный сбор данных в отдельной горутине
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
-		// Запускаем первый сбор данных немедленно
-		collectData(weatherAPI, dbManager)
+	// Часовой пояс для вывода времени в логах
+	displayLocation = cfg.DisplayLocation
 
-		// Настраиваем периодический запуск
-		ticker := time.NewTicker(time.Duration(cfg.CollectionInterval) * time.Minute)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				collectData(weatherAPI, dbManager)
-			case <-stopChan:
-				log.Println("Получен сигнал остановки. Завершаем работу...")
-				return
-			}
+	// Настраиваем трассировку OpenTelemetry (без OTEL_EXPORTER_OTLP_ENDPOINT спаны не отправляются)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		logging.Fatalf("Ошибка при настройке трассировки: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logging.Errorf("Ошибка при отправке трассировок: %v", err)
 		}
 	}()
 
-	// Ожидаем сигнал остановки
-	<-stopChan
-	log.Println("Ожидаем завершения всех задач...")
-	wg.Wait()
-	log.Println("Сервис остановлен")
-}
-
-// collectData выполняет сбор данных со всех метеостанций и их сохранение в БД
-func collectData(weatherAPI *api.WeatherAPI, dbManager *database.DBManager) {
-	log.Println("Начинаем сбор данных...")
+	// Проверяем настроенные преобразования единиц измерения до подключения к API и базе данных
+	if _, err := units.Resolve(cfg.SensorConversions); err != nil {
+		logging.Fatalf("Ошибка в конфигурации преобразований единиц измерения: %v", err)
+	}
 
-	// Получаем список всех устройств
-	devices, err := weatherAPI.GetDevices()
-	if err != nil {
-		log.Printf("Ошибка при получении списка устройств: %v", err)
+	// Режим вывода статистики: API не используется
+	if *statsFlag {
+		if err := printStats(cfg, os.Stdout); err != nil {
+			logging.Fatalf("Ошибка при получении статистики: %v", err)
+		}
 		return
 	}
 
-	log.Printf("Найдено устройств: %d", len(devices))
-
-	// Сохраняем информацию о станциях в базу данных
-	if err := dbManager.StoreStations(devices); err != nil {
-		log.Printf("Ошибка при сохранении информации о станциях: %v", err)
+	// Режим выгрузки в Parquet: API не используется
+	if *exportParquetPath != "" {
+		if err := exportParquet(cfg, *exportParquetPath, *exportStation, *exportFrom, *exportTo); err != nil {
+			logging.Fatalf("Ошибка при выгрузке в Parquet: %v", err)
+		}
+		return
 	}
 
-	// Обрабатываем каждое устройство
-	for _, device := range devices {
-		processDevice(weatherAPI, dbManager, device)
+	// Режим удаления станции: API не используется
+	if *deleteStationID != "" {
+		if err := deleteStation(cfg, *deleteStationID, os.Stdin, os.Stdout); err != nil {
+			logging.Fatalf("Ошибка при удалении станции: %v", err)
+		}
+		return
 	}
 
-	log.Println("Сбор данных завершен")
-}
-
-// processDevice обрабатывает отдельное устройство (метеостанцию)
-func processDevice(weatherAPI *api.WeatherAPI, dbManager *database.DBManager, device api.Device) {
-	log.Printf("Обрабатываем устройство: %s (%s)", device.Label, device.ID)
-
-	// Текущее время в миллисекундах
-	now := time.Now().UnixNano() / int64(time.Millisecond)
-
-	// Стандартный интервал для получения данных (если нет данных в БД)
-	intervalMs := int64(15 * 60 * 1000) // 15 минут в миллисекундах
-
-	// Создаем карту для хранения данных о последнем timestamp для каждого датчика
-	sensorLastTs := make(map[string]int64)
+	// Режим отчета о пропусках в данных станции: API не используется
+	if *gapReportStation != "" {
+		if err := printGapReport(cfg, *gapReportStation, *gapInterval, os.Stdout); err != nil {
+			logging.Fatalf("Ошибка при построении отчета о пропусках: %v", err)
+		}
+		return
+	}
 
-	// Создаем два списка датчиков - новые (без данных) и существующие
-	var newSensors []string
-	var existingSensors []string
+	// Режим пересчета производных таблиц: API не используется
+	if *reprocessFlag {
+		if err := reprocess(cfg, *exportFrom, *exportTo); err != nil {
+			logging.Fatalf("Ошибка при пересчете производных таблиц: %v", err)
+		}
+		return
+	}
 
-	// Определяем минимальный tsFrom для существующих датчиков
-	minTsFrom := now
+	// Режим исправления DateValue: API не используется
+	if *repairDateValuesFlag {
+		if err := repairDateValues(cfg, *repairBatchSize, os.Stdout); err != nil {
+			logging.Fatalf("Ошибка при исправлении DateValue: %v", err)
+		}
+		return
+	}
 
-	// Пытаемся получить время последних данных для каждого ключа датчика
-	for _, sensorKey := range sensorKeys {
-		// Получаем время последней записи из базы данных
-		lastTs, err := dbManager.GetLatestTelemetryTimestamp(device.ID, sensorKey)
+	// Инициализируем API клиент
+	var apiOpts []api.Option
+	if cfg.RawStorePath != "" {
+		rawStore, err := api.NewRawStore(cfg.RawStorePath, time.Duration(cfg.RawStoreRetentionDays)*24*time.Hour)
 		if err != nil {
-			log.Printf("Ошибка при получении последнего timestamp для %s-%s: %v", device.ID, sensorKey, err)
-			// Если ошибка, считаем что данных нет
-			newSensors = append(newSensors, sensorKey)
-			continue
+			logging.Fatalf("Ошибка при настройке сохранения исходных ответов: %v", err)
 		}
+		apiOpts = append(apiOpts, api.WithRawStore(rawStore))
+	}
 
-		sensorLastTs[sensorKey] = lastTs
+	weatherAPI := api.NewWeatherAPI(cfg, apiOpts...)
 
-		// Проверяем, есть ли для этого датчика данные в базе
-		if lastTs > 0 {
-			existingSensors = append(existingSensors, sensorKey)
+	// Логин в API (временные ошибки повторяются, неверные учетные данные - сразу ошибка)
+	if err := loginWithRetry(weatherAPI); err != nil {
+		logging.Fatalf("Ошибка при авторизации: %v", err)
+	}
 
-			// Определяем минимальный timestamp для всех существующих датчиков
-			if lastTs < minTsFrom {
-				minTsFrom = lastTs
-			}
-		} else {
-			// Датчик есть в списке, но данных по нему нет
-			newSensors = append(newSensors, sensorKey)
+	// Режим вывода списка станций: база данных не используется
+	if *listStationsFlag {
+		if err := listStations(weatherAPI, os.Stdout); err != nil {
+			logging.Fatalf("Ошибка при выводе списка станций: %v", err)
 		}
+		return
 	}
 
-	// Рассчитываем tsFrom для существующих датчиков
-	tsFrom := now - intervalMs
-	if minTsFrom < now && minTsFrom > 0 {
-		// Добавляем 1 миллисекунду, чтобы не получать повторно ту же запись
-		tsFrom = minTsFrom + 1
+	// Режим отчета о датчиках станций: база данных не используется
+	if *discoverSensorsFlag {
+		if err := discoverSensors(cfg, weatherAPI, os.Stdout); err != nil {
+			logging.Fatalf("Ошибка при формировании отчета о датчиках: %v", err)
+		}
+		return
 	}
 
-	// Общее количество полученных записей
-	totalRecordsCount := 0
-
-	// Обрабатываем новые датчики, если они есть
-	if len(newSensors) > 0 {
-		log.Printf("Для устройства %s запрашиваем годовые данные для %d новых датчиков: %v",
-			device.ID, len(newSensors), newSensors)
-
-		// Определяем время начала годового периода
-		oneYearAgo := now - 365*24*60*60*1000 // 365 дней в миллисекундах
-
-		// Разбиваем год на месячные интервалы
-		periods := splitTimePeriodByMonth(oneYearAgo, now)
+	// Инициализируем хранилище данных
+	var store database.TelemetryStore
+	switch cfg.Sink {
+	case config.SinkInflux:
+		influxSink, err := influx.NewInfluxSink(cfg)
+		if err != nil {
+			logging.Fatalf("Ошибка при подключении к InfluxDB: %v", err)
+		}
+		defer influxSink.Close()
+		store = influxSink
+	default:
+		// Инициализируем менеджер БД
+		dbManager, err := database.NewDBManager(cfg, databaseOptions(cfg)...)
+		if err != nil {
+			logging.Fatalf("Ошибка при подключении к БД: %v", err)
+		}
+		defer dbManager.Close()
 
-		// Обрабатываем каждый временной период
-		for _, period := range periods {
-			// Получаем телеметрию за текущий период только для новых датчиков
-			telemetry, err := weatherAPI.GetTelemetry(device.ID, newSensors, period.from, period.to)
-			if err != nil {
-				log.Printf("Ошибка при получении телеметрии для новых датчиков устройства %s за период %s - %s: %v",
-					device.ID,
-					time.Unix(period.from/1000, 0).Format("2006-01-02 15:04:05"),
-					time.Unix(period.to/1000, 0).Format("2006-01-02 15:04:05"),
-					err)
-				continue
+		// Создаем таблицы, если они не существуют (в пробном режиме база данных не изменяется)
+		if !cfg.DryRun {
+			if err := dbManager.CreateTablesIfNotExists(); err != nil {
+				logging.Fatalf("Ошибка при создании таблиц: %v", err)
 			}
+		}
 
-			recordsCount := processAndSaveTelemetry(device.ID, telemetry, dbManager)
-			totalRecordsCount += recordsCount
+		// Проверяем, что схема базы данных соответствует ожидаемой. В пробном режиме таблицы не создаются
+		// и запись не выполняется, поэтому несоответствие схемы не мешает работе
+		if err := dbManager.VerifySchema(); err != nil {
+			if !cfg.DryRun {
+				logging.Fatalf("Ошибка при проверке схемы базы данных: %v", err)
+			}
+			logging.Warnf("Внимание: схема базы данных не соответствует ожидаемой, в пробном режиме работа продолжается: %v", err)
 		}
+		store = dbManager
+	}
+
+	// В пробном режиме данные запрашиваются из API, но не записываются
+	if cfg.DryRun {
+		log.Println("Включен пробный режим (DRY_RUN): данные не будут сохраняться и публиковаться")
+		store = database.NewDryRunStore(store)
 	}
 
-	// Обрабатываем существующие датчики, если они есть
-	if len(existingSensors) > 0 {
-		log.Printf("Для устройства %s запрашиваем обновленные данные для %d существующих датчиков с %s",
-			device.ID,
-			len(existingSensors),
-			time.Unix(tsFrom/1000, 0).Format("2006-01-02 15:04:05"))
-
-		// Определяем период запроса данных для существующих датчиков
-		var periods []timePeriod
-
-		// Если последняя запись старше месяца, разбиваем запросы на промежутки
-		oneMonthAgo := now - 30*24*60*60*1000 // 30 дней в миллисекундах
-		if tsFrom < oneMonthAgo {
-			log.Printf("Для устройства %s данные старше месяца. Разбиваем запрос на меньшие интервалы.", device.ID)
-			// Разбиваем период по 30 дней
-			periods = splitTimePeriodByDays(tsFrom, now, 30)
-		} else {
-			// Если период небольшой, делаем один запрос
-			minutesAgo := (now - tsFrom) / 1000 / 60
-			log.Printf("Для устройства %s запрашиваем данные за последние %d минут", device.ID, minutesAgo)
-			periods = []timePeriod{{tsFrom, now}}
+	// Подключаемся к MQTT брокеру, если он указан в конфигурации
+	var mqttPublisher publisher.Publisher
+	if cfg.MqttBroker != "" && !cfg.DryRun {
+		mqttPub, err := publisher.NewMQTTPublisher(cfg)
+		if err != nil {
+			logging.Fatalf("Ошибка при подключении к MQTT брокеру: %v", err)
 		}
+		defer mqttPub.Close()
+		mqttPublisher = mqttPub
+	}
 
-		// Обрабатываем каждый временной период
-		for _, period := range periods {
-			// Получаем телеметрию за текущий период только для существующих датчиков
-			telemetry, err := weatherAPI.GetTelemetry(device.ID, existingSensors, period.from, period.to)
-			if err != nil {
-				log.Printf("Ошибка при получении телеметрии для существующих датчиков устройства %s за период %s - %s: %v",
-					device.ID,
-					time.Unix(period.from/1000, 0).Format("2006-01-02 15:04:05"),
-					time.Unix(period.to/1000, 0).Format("2006-01-02 15:04:05"),
-					err)
-				continue
-			}
+	c := &collector{
+		weatherAPI: weatherAPI,
+		dbManager:  store,
+		publisher:  mqttPublisher,
+		clock:      clock.Real,
+
+		// Расписание опроса станций: станции без индивидуального интервала опрашиваются с общим интервалом
+		schedule: newStationSchedule(
+			time.Duration(cfg.CollectionInterval)*time.Minute,
+			cfg.StationIntervals,
+			time.Duration(cfg.CollectionJitterSeconds)*time.Second,
+		),
+	}
 
-			recordsCount := processAndSaveTelemetry(device.ID, telemetry, dbManager)
-			totalRecordsCount += recordsCount
-		}
+	// Параметры сбора, которые можно изменить без перезапуска (SIGHUP)
+	if err := c.applyConfig(cfg); err != nil {
+		logging.Fatalf("Ошибка в конфигурации: %v", err)
 	}
 
-	if totalRecordsCount > 0 {
-		log.Printf("Данные для устройства %s успешно обработаны. Всего получено %d записей.", device.ID, totalRecordsCount)
-	} else {
-		log.Printf("Для устройства %s не получено никаких новых данных.", device.ID)
+	// Уведомления о новых станциях
+	if cfg.NewStationWebhook != "" && !cfg.DryRun {
+		c.stationWebhook = notify.NewStationWebhook(cfg.NewStationWebhook)
 	}
-}
 
-// processAndSaveTelemetry обрабатывает и сохраняет полученную телеметрию
-func processAndSaveTelemetry(deviceID string, telemetry map[string][]api.TelemetryPoint, dbManager *database.DBManager) int {
-	// Считаем количество полученных записей
-	recordsCount := 0
-	for _, points := range telemetry {
-		recordsCount += len(points)
+	// Оповещения о повторяющихся сбоях (в пробном режиме не отправляются)
+	var notifier notify.Notifier = notify.NopNotifier{}
+	if !cfg.DryRun {
+		notifier = newNotifier(cfg)
 	}
+	c.alerts = notify.NewAlerter(notifier, cfg.AlertAfterFailures)
 
-	if recordsCount == 0 {
-		log.Printf("Для устройства %s новых данных не получено", deviceID)
-		return 0
+	// Буфер телеметрии на время недоступности хранилища
+	if cfg.SpoolMaxPoints > 0 {
+		c.spool = newTelemetrySpool(cfg.SpoolMaxPoints)
 	}
 
-	log.Printf("Для устройства %s получено %d новых записей. Сохраняем в базу данных...", deviceID, recordsCount)
+	// Очередь записи телеметрии, чтобы запросы к API не ожидали сохранения
+	if cfg.WriteQueueWorkers > 0 {
+		c.writes = newWriteQueue(cfg.WriteQueueWorkers, cfg.WriteQueueSize)
+	}
 
-	// Сохраняем телеметрию в базу данных
-	startTime := time.Now()
-	if err := dbManager.StoreTelemetry(deviceID, telemetry); err != nil {
-		log.Printf("Ошибка при сохранении телеметрии для устройства %s: %v", deviceID, err)
-		return 0
+	// Запускаем API чтения данных, если указан адрес
+	var apiServer *server.Server
+	if cfg.ApiServeAddr != "" {
+		serverStore, ok := store.(server.Store)
+		if !ok {
+			logging.Fatalf("API чтения данных не поддерживается хранилищем %s", cfg.Sink)
+		}
+		c.stream = server.NewBroker(cfg.CompassPoints)
+		apiServer = server.NewServer(cfg.ApiServeAddr, serverStore, server.WithBroker(c.stream))
+		apiServer.Start()
 	}
 
-	// Вычисляем, сколько времени заняло сохранение данных
-	elapsed := time.Since(startTime)
-	log.Printf("Данные для устройства %s успешно сохранены в базу (время: %.2f сек., скорость: %.1f записей/сек.)",
-		deviceID,
-		elapsed.Seconds(),
-		float64(recordsCount)/elapsed.Seconds())
+	// Запускаем отладочный эндпоинт, если указан адрес
+	var debugServer *server.DebugServer
+	if cfg.DebugAddr != "" {
+		var debugOpts []server.DebugOption
+		if reporter, ok := unwrapDryRun(store).(database.GapReporter); ok {
+			debugOpts = append(debugOpts, server.WithGapReporter(reporter))
+		}
+		debugServer = server.NewDebugServer(cfg.DebugAddr, cfg.DebugToken, c.debugState(weatherAPI), debugOpts...)
+		debugServer.Start()
+	}
 
-	return recordsCount
-}
+	// Канал для остановки сервиса
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
 
-// timePeriod представляет временной период с началом и концом
-type timePeriod struct {
-	from int64 // начало периода в миллисекундах
-	to   int64 // конец периода в миллисекундах
-}
+	// Канал для перезагрузки конфигурации
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
 
-// splitTimePeriodByMonth разбивает большой временной период на месячные интервалы
-func splitTimePeriodByMonth(tsFrom, tsTo int64) []timePeriod {
-	var periods []timePeriod
+	// Канал закрывается при остановке сервиса и оповещает все горутины
+	done := make(chan struct{})
 
-	// Преобразуем timestamp в time.Time для удобства работы с месяцами
-	fromTime := time.Unix(tsFrom/1000, 0)
-	toTime := time.Unix(tsTo/1000, 0)
+	// Запускаем регулярный сбор данных в отдельной горутине
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.serve(cfg, reloadChan, done)
+	}()
 
-	// Устанавливаем начало на первый день текущего месяца
-	currentMonth := time.Date(fromTime.Year(), fromTime.Month(), 1, 0, 0, 0, 0, fromTime.Location())
+	// Ожидаем сигнал остановки
+	<-stopChan
+	close(done)
+	log.Println("Ожидаем завершения всех задач...")
 
-	// Добавляем первый период от начальной даты до конца месяца
-	if fromTime.Day() > 1 {
-		// Начинаем с текущей даты до конца месяца
-		nextMonth := currentMonth.AddDate(0, 1, 0)
-		periodEnd := min(nextMonth.UnixNano()/int64(time.Millisecond), tsTo)
-		periods = append(periods, timePeriod{tsFrom, periodEnd})
-		currentMonth = nextMonth
+	// Останавливаем API чтения данных
+	if apiServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := apiServer.Shutdown(ctx); err != nil {
+			logging.Errorf("Ошибка при остановке API чтения данных: %v", err)
+		}
+		cancel()
 	}
 
-	// Добавляем полные месячные периоды
-	for currentMonth.Before(toTime) {
-		nextMonth := currentMonth.AddDate(0, 1, 0)
-		periodStart := currentMonth.UnixNano() / int64(time.Millisecond)
-		periodEnd := nextMonth.UnixNano() / int64(time.Millisecond)
-
-		// Если конец периода выходит за пределы запрашиваемого диапазона, ограничиваем его
-		if periodEnd > tsTo {
-			periodEnd = tsTo
+	// Останавливаем отладочный эндпоинт
+	if debugServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := debugServer.Shutdown(ctx); err != nil {
+			logging.Errorf("Ошибка при остановке отладочного эндпоинта: %v", err)
 		}
+		cancel()
+	}
 
-		periods = append(periods, timePeriod{periodStart, periodEnd})
-		currentMonth = nextMonth
+	wg.Wait()
 
-		// Если достигли конечной даты, выходим из цикла
-		if currentMonth.UnixNano()/int64(time.Millisecond) >= tsTo {
-			break
-		}
+	// Дожидаемся сохранения телеметрии из очереди записи
+	if c.writes != nil {
+		c.writes.close()
 	}
+	log.Println("Сервис остановлен")
+}
 
-	return periods
+// databaseOptions возвращает настройки менеджера БД, зависящие от конфигурации сбора:
+// широкая таблица телеметрии создается со столбцами запрашиваемых датчиков
+func databaseOptions(cfg *config.Config) []database.Option {
+	var opts []database.Option
+	if cfg.TelemetryWide {
+		opts = append(opts, database.WithWideTable(sensorKeysFor(cfg)))
+	}
+	return opts
 }
 
-// splitTimePeriodByDays разбивает большой временной период на интервалы по указанному количеству дней
-func splitTimePeriodByDays(tsFrom, tsTo int64, days int) []timePeriod {
-	var periods []timePeriod
+// Параметры повторного входа при запуске
+const (
+	maxLoginAttempts = 5
+	loginRetryDelay  = 10 * time.Second
+)
 
-	// Вычисляем длину одного интервала в миллисекундах
-	intervalMs := int64(days * 24 * 60 * 60 * 1000)
+// loginWithRetry выполняет вход в API. Временные ошибки (сеть, ошибка сервера, лимит запросов)
+// повторяются с увеличивающейся задержкой, остальные (например, неверный пароль) возвращаются сразу
+func loginWithRetry(weatherAPI *api.WeatherAPI) error {
+	var err error
+	for attempt := 1; attempt <= maxLoginAttempts; attempt++ {
+		err = weatherAPI.Login()
+		if err == nil || !api.IsTemporary(err) {
+			return err
+		}
 
-	// Начальная точка
-	current := tsFrom
+		if attempt < maxLoginAttempts {
+			delay := loginRetryDelay * time.Duration(1<<(attempt-1))
+			log.Printf("Временная ошибка при авторизации (попытка %d из %d), повтор через %s: %v",
+				attempt, maxLoginAttempts, delay, err)
+			time.Sleep(delay)
+		}
+	}
 
-	// Разбиваем период на интервалы указанной длины
-	for current < tsTo {
-		periodEnd := current + intervalMs
-		if periodEnd > tsTo {
-			periodEnd = tsTo
+	return fmt.Errorf("вход не выполнен после %d попыток: %w", maxLoginAttempts, err)
+}
+
+// serve запускает циклы сбора данных по расписанию и обновление данных станций по отдельному таймеру
+// с интервалом STATION_REFRESH_MINUTES, применяет новую конфигурацию по сигналу reload и завершается
+// при закрытии done. Циклы сбора, обновление станций и применение конфигурации выполняются
+// последовательно, поэтому не требуют синхронизации
+func (c *collector) serve(cfg *config.Config, reload <-chan os.Signal, done <-chan struct{}) {
+	// Источник случайных чисел для разброса времени запуска
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	jitter := time.Duration(cfg.CollectionJitterSeconds) * time.Second
+	cycleTimeout := time.Duration(cfg.CycleTimeoutMinutes) * time.Minute
+
+	if cfg.CollectOnStart {
+		// Смещаем первый запуск, чтобы несколько экземпляров не обращались к API одновременно
+		if delay := initialDelay(jitter, rnd); delay > 0 {
+			log.Printf("Первый сбор данных будет запущен через %s", delay.Round(time.Second))
+			select {
+			case <-time.After(delay):
+			case <-done:
+				log.Println("Получен сигнал остановки. Завершаем работу...")
+				return
+			}
 		}
 
-		periods = append(periods, timePeriod{current, periodEnd})
-		current = periodEnd
+		// Запускаем первый сбор данных
+		c.runCycle(context.Background(), cycleTimeout)
+	}
+
+	// Настраиваем периодический запуск: следующий цикл запускается к ближайшему
+	// запланированному опросу станции (без сбора при запуске - через интервал сбора)
+	timer := time.NewTimer(jitteredInterval(c.schedule.nextWake(c.clock.Now()), jitter, rnd))
+	defer timer.Stop()
+	if !cfg.CollectOnStart {
+		log.Println("Сбор данных при запуске отключен (COLLECT_ON_START=false), первый сбор - по расписанию")
 	}
 
-	return periods
+	// Данные всех станций обновляются по своему таймеру: интервал обновления может быть как больше,
+	// так и меньше интервала сбора
+	refreshInterval := c.stationRefresh.currentInterval()
+	refreshTicker := time.NewTicker(refreshInterval)
+	defer refreshTicker.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			c.runCycle(context.Background(), cycleTimeout)
+			timer.Reset(jitteredInterval(c.schedule.nextWake(c.clock.Now()), jitter, rnd))
+		case <-refreshTicker.C:
+			c.refreshStations(context.Background())
+		case <-reload:
+			cfg = c.reloadConfig(cfg)
+			jitter = time.Duration(cfg.CollectionJitterSeconds) * time.Second
+			cycleTimeout = time.Duration(cfg.CycleTimeoutMinutes) * time.Minute
+			timer.Reset(jitteredInterval(c.schedule.nextWake(c.clock.Now()), jitter, rnd))
+			if interval := c.stationRefresh.currentInterval(); interval != refreshInterval {
+				refreshInterval = interval
+				refreshTicker.Reset(refreshInterval)
+			}
+		case <-done:
+			log.Println("Получен сигнал остановки. Завершаем работу...")
+			return
+		}
+	}
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"weatherInTheField/pkg/api"
+)
+
+// maxObjectStringLen - максимальная длина строки, в которую сериализуется значение-объект
+// (длина столбца StrValue таблицы Telemetry)
+const maxObjectStringLen = 255
+
+// flattenObjectValues заменяет значения-объекты (например, {"raw": 1.2, "calibrated": 1.25}) скалярными.
+// Если для датчика в SENSOR_OBJECT_FIELDS указано поле и оно содержит число, сохраняется это число,
+// иначе объект сохраняется строкой JSON. Объекты, строка JSON которых длиннее столбца StrValue, отбрасываются
+func (c *collector) flattenObjectValues(deviceID string, telemetry map[string][]api.TelemetryPoint) {
+	missingField := make(map[string]int)
+	tooLong := make(map[string]int)
+	for sensorKey, points := range telemetry {
+		field := c.objectFields[sensorKey]
+
+		valid := points[:0]
+		for _, point := range points {
+			object, ok := objectValue(point.Value)
+			if !ok {
+				valid = append(valid, point)
+				continue
+			}
+
+			if field != "" {
+				if value, ok := numericField(object, field); ok {
+					point.Value = value
+					valid = append(valid, point)
+					continue
+				}
+				missingField[sensorKey]++
+			}
+
+			encoded, err := json.Marshal(object)
+			if err != nil || utf8.RuneCount(encoded) > maxObjectStringLen {
+				tooLong[sensorKey]++
+				continue
+			}
+			point.Value = string(encoded)
+			valid = append(valid, point)
+		}
+
+		if len(valid) == 0 {
+			delete(telemetry, sensorKey)
+			continue
+		}
+		telemetry[sensorKey] = valid
+	}
+
+	if len(missingField) > 0 {
+		log.Printf("Для устройства %s в значениях-объектах нет числового поля из SENSOR_OBJECT_FIELDS, сохранены строкой JSON: %s",
+			deviceID, formatSensorCounts(missingField))
+	}
+	if len(tooLong) > 0 {
+		log.Printf("Для устройства %s отброшены значения-объекты длиннее %d символов: %s",
+			deviceID, maxObjectStringLen, formatSensorCounts(tooLong))
+	}
+}
+
+// objectValue возвращает значение точки как объект JSON. Строки, содержащие объект JSON, также разбираются
+func objectValue(v interface{}) (map[string]interface{}, bool) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return value, true
+	case string:
+		trimmed := strings.TrimSpace(value)
+		if !strings.HasPrefix(trimmed, "{") {
+			return nil, false
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(trimmed), &object); err != nil {
+			return nil, false
+		}
+		return object, true
+	}
+	return nil, false
+}
+
+// numericField возвращает числовое значение поля объекта. Строки, содержащие число, преобразуются
+func numericField(object map[string]interface{}, field string) (float64, bool) {
+	switch value := object[field].(type) {
+	case float64:
+		return value, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/logging"
+	"weatherInTheField/pkg/units"
+)
+
+// hotReloadFields - параметры конфигурации, изменения которых применяются по SIGHUP без перезапуска
+var hotReloadFields = map[string]bool{
+	"CollectionInterval":            true,
+	"StationIntervals":              true,
+	"CollectionJitterSeconds":       true,
+	"CycleTimeoutMinutes":           true,
+	"SensorKeys":                    true,
+	"DisabledSensors":               true,
+	"SensorConversions":             true,
+	"ValueDecimals":                 true,
+	"SensorDecimals":                true,
+	"WindDirToDegrees":              true,
+	"MaxPeriodDays":                 true,
+	"FetchOverlapMs":                true,
+	"DeviceCacheSeconds":            true,
+	"DeviceLabelRegex":              true,
+	"SensorStaleMinutes":            true,
+	"PeriodFetchConcurrency":        true,
+	"MinValidDate":                  true,
+	"MaxFutureSkewMinutes":          true,
+	"StoreLatencyThresholdMs":       true,
+	"SlowWriteThresholdMs":          true,
+	"BackfillHours":                 true,
+	"StationRefreshMinutes":         true,
+	"SensorObjectFields":            true,
+	"CircuitBreakerFailures":        true,
+	"CircuitBreakerCooldownMinutes": true,
+	"StationErrorThreshold":         true,
+	"StationRetryMinutes":           true,
+	"LogLevel":                      true,
+}
+
+// ignoredReloadFields - производные параметры, которые не сравниваются при перезагрузке
+var ignoredReloadFields = map[string]bool{
+	"DisplayLocation":    true,
+	"DeviceLabelPattern": true,
+	"MinValidTime":       true,
+	"BackfillWindow":     true,
+}
+
+// configChanges возвращает имена измененных параметров конфигурации, разделяя их на применяемые
+// без перезапуска и требующие перезапуска сервиса (например, подключение к базе данных)
+func configChanges(old, updated *config.Config) (applied, restart []string) {
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(updated).Elem()
+	fields := oldValue.Type()
+
+	for i := 0; i < fields.NumField(); i++ {
+		name := fields.Field(i).Name
+		if ignoredReloadFields[name] {
+			continue
+		}
+		if reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			continue
+		}
+
+		if hotReloadFields[name] {
+			applied = append(applied, name)
+		} else {
+			restart = append(restart, name)
+		}
+	}
+
+	return applied, restart
+}
+
+// applyConfig применяет к сборщику параметры, которые можно изменить без перезапуска.
+// При ошибке сборщик не изменяется
+func (c *collector) applyConfig(cfg *config.Config) error {
+	conversions, err := units.Resolve(cfg.SensorConversions)
+	if err != nil {
+		return fmt.Errorf("ошибка в конфигурации преобразований единиц измерения: %w", err)
+	}
+	if err := setLogLevel(cfg.LogLevel); err != nil {
+		return err
+	}
+
+	c.sensorKeys = sensorKeysFor(cfg)
+	c.disabledSensors = make(map[string]bool, len(cfg.DisabledSensors))
+	for _, sensorKey := range cfg.DisabledSensors {
+		c.disabledSensors[sensorKey] = true
+	}
+	if len(cfg.DisabledSensors) > 0 {
+		log.Printf("Сбор датчиков отключен на всех станциях (DISABLED_SENSORS): %v", cfg.DisabledSensors)
+	}
+	c.conversions = conversions
+	c.valueDecimals = cfg.ValueDecimals
+	c.sensorDecimals = cfg.SensorDecimals
+	c.objectFields = cfg.SensorObjectFields
+	c.breaker.configure(cfg.CircuitBreakerFailures, time.Duration(cfg.CircuitBreakerCooldownMinutes)*time.Minute)
+	c.demotion.configure(cfg.StationErrorThreshold, time.Duration(cfg.StationRetryMinutes)*time.Minute)
+	c.windDirToDegrees = cfg.WindDirToDegrees
+	c.maxPeriodDays = cfg.MaxPeriodDays
+	c.fetchOverlapMs = int64(cfg.FetchOverlapMs)
+	c.periodConcurrency = cfg.PeriodFetchConcurrency
+	c.deviceCacheTTL = time.Duration(cfg.DeviceCacheSeconds) * time.Second
+	c.labelFilter = cfg.DeviceLabelPattern
+	c.staleAfter = time.Duration(cfg.SensorStaleMinutes) * time.Minute
+	c.minValidTime = cfg.MinValidTime
+	c.maxFutureSkew = time.Duration(cfg.MaxFutureSkewMinutes) * time.Minute
+	c.backfillWindow = cfg.BackfillWindow
+	c.backpressure.setThreshold(time.Duration(cfg.StoreLatencyThresholdMs) * time.Millisecond)
+	c.slowWriteThreshold = time.Duration(cfg.SlowWriteThresholdMs) * time.Millisecond
+	c.stationRefresh.setInterval(time.Duration(cfg.StationRefreshMinutes) * time.Minute)
+
+	if c.schedule != nil {
+		c.schedule.setIntervals(time.Duration(cfg.CollectionInterval)*time.Minute, cfg.StationIntervals)
+		c.schedule.setTolerance(time.Duration(cfg.CollectionJitterSeconds) * time.Second)
+	}
+
+	return nil
+}
+
+// setLogLevel задает уровень журнала по значению LOG_LEVEL
+func setLogLevel(name string) error {
+	level, err := logging.ParseLevel(name)
+	if err != nil {
+		return fmt.Errorf("некорректное значение LOG_LEVEL: %w", err)
+	}
+	logging.SetLevel(level)
+	return nil
+}
+
+// reloadConfig перечитывает конфигурацию и применяет изменения, допустимые без перезапуска.
+// Если новая конфигурация некорректна, продолжает работу со старой.
+// Возвращает действующую после перезагрузки конфигурацию
+func (c *collector) reloadConfig(current *config.Config) *config.Config {
+	log.Println("Получен сигнал SIGHUP, перечитываем конфигурацию...")
+
+	updated, err := config.LoadConfig()
+	if err != nil {
+		logging.Errorf("Ошибка в новой конфигурации, продолжаем работу с прежней: %v", err)
+		return current
+	}
+
+	applied, restart := configChanges(current, updated)
+	if len(applied) == 0 && len(restart) == 0 {
+		log.Println("Конфигурация не изменилась")
+		return current
+	}
+
+	if err := c.applyConfig(updated); err != nil {
+		logging.Errorf("Ошибка в новой конфигурации, продолжаем работу с прежней: %v", err)
+		return current
+	}
+
+	if len(applied) > 0 {
+		log.Printf("Применены изменения конфигурации: %s", strings.Join(applied, ", "))
+	}
+	if len(restart) > 0 {
+		log.Printf("Изменения требуют перезапуска сервиса и не применены: %s", strings.Join(restart, ", "))
+	}
+
+	// Параметры, требующие перезапуска, остаются прежними: сравниваем следующие изменения с ними
+	merged := *current
+	for _, name := range applied {
+		reflect.ValueOf(&merged).Elem().FieldByName(name).Set(reflect.ValueOf(updated).Elem().FieldByName(name))
+	}
+	return &merged
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/database"
+)
+
+// repairDateValues пересчитывает DateValue по Timestamp в UTC для сохраненных строк.
+// Используется только база данных, запросы к API не выполняются
+func repairDateValues(cfg *config.Config, batchSize int, out io.Writer) error {
+	if cfg.Sink != config.SinkMSSQL {
+		return fmt.Errorf("исправление DateValue поддерживается только для SINK=%s", config.SinkMSSQL)
+	}
+
+	dbManager, err := database.NewDBManager(cfg, databaseOptions(cfg)...)
+	if err != nil {
+		return fmt.Errorf("ошибка при подключении к БД: %w", err)
+	}
+	defer dbManager.Close()
+
+	rows, err := dbManager.RecomputeDateValues(batchSize)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Исправление DateValue завершено, обновлено строк: %d\n", rows)
+	return nil
+}
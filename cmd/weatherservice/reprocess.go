@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/database"
+)
+
+// reprocess заново заполняет производные таблицы по сырым данным за период.
+// Используется только база данных, запросы к API не выполняются
+func reprocess(cfg *config.Config, fromRaw, toRaw string) error {
+	if cfg.Sink != config.SinkMSSQL {
+		return fmt.Errorf("пересчет поддерживается только для SINK=%s", config.SinkMSSQL)
+	}
+
+	to := time.Now()
+	if toRaw != "" {
+		t, err := parseExportTime(toRaw)
+		if err != nil {
+			return fmt.Errorf("некорректный параметр --to: %w", err)
+		}
+		to = t
+	}
+
+	if fromRaw == "" {
+		return fmt.Errorf("не указано начало периода (--from)")
+	}
+	from, err := parseExportTime(fromRaw)
+	if err != nil {
+		return fmt.Errorf("некорректный параметр --from: %w", err)
+	}
+
+	// Сейчас единственная производная таблица - TelemetryWide
+	if !cfg.TelemetryWide {
+		log.Println("Нет производных таблиц для пересчета: таблица TelemetryWide не включена (TELEMETRY_WIDE), пересчет не выполняется")
+		return nil
+	}
+
+	dbManager, err := database.NewDBManager(cfg, databaseOptions(cfg)...)
+	if err != nil {
+		return fmt.Errorf("ошибка при подключении к БД: %w", err)
+	}
+	defer dbManager.Close()
+
+	// Создаем столбцы для датчиков, добавленных после создания производных таблиц
+	if err := dbManager.CreateTablesIfNotExists(); err != nil {
+		return fmt.Errorf("ошибка при создании таблиц: %w", err)
+	}
+
+	rows, err := dbManager.Reprocess(from, to)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Пересчет завершен, обновлено строк: %d\n", rows)
+	return nil
+}
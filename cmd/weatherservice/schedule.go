@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// minCollectionInterval - минимальный интервал между запусками сбора после применения разброса
+const minCollectionInterval = time.Second
+
+// jitteredInterval возвращает интервал до следующего запуска сбора данных,
+// случайно смещенный в пределах ±jitter. Источник случайных чисел передается явно,
+// чтобы результат можно было сделать детерминированным
+func jitteredInterval(interval, jitter time.Duration, rnd *rand.Rand) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+
+	// Смещение в диапазоне [-jitter, +jitter]
+	offset := time.Duration(rnd.Int63n(2*int64(jitter)+1)) - jitter
+
+	next := interval + offset
+	if next < minCollectionInterval {
+		next = minCollectionInterval
+	}
+	return next
+}
+
+// initialDelay возвращает случайную задержку первого запуска сбора данных в пределах [0, jitter]
+func initialDelay(jitter time.Duration, rnd *rand.Rand) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rnd.Int63n(int64(jitter) + 1))
+}
+
+// stationSchedule хранит время следующего опроса каждой станции.
+// Интервал опроса задается для станции отдельно (STATION_INTERVALS) или берется общий
+type stationSchedule struct {
+	mu sync.Mutex
+
+	defaultInterval time.Duration
+	intervals       map[string]time.Duration
+	nextRun         map[string]time.Time
+
+	// Станции, опрос которых наступит в пределах tolerance, опрашиваются в текущем цикле,
+	// чтобы разброс времени запуска не приводил к лишним циклам
+	tolerance time.Duration
+}
+
+// newStationSchedule создает расписание опроса станций. Интервалы задаются в минутах
+func newStationSchedule(defaultInterval time.Duration, stationIntervals map[string]int, tolerance time.Duration) *stationSchedule {
+	intervals := make(map[string]time.Duration, len(stationIntervals))
+	for stationID, minutes := range stationIntervals {
+		intervals[stationID] = time.Duration(minutes) * time.Minute
+	}
+
+	if tolerance < minCollectionInterval {
+		tolerance = minCollectionInterval
+	}
+
+	return &stationSchedule{
+		defaultInterval: defaultInterval,
+		intervals:       intervals,
+		nextRun:         make(map[string]time.Time),
+		tolerance:       tolerance,
+	}
+}
+
+// interval возвращает интервал опроса станции
+func (s *stationSchedule) interval(stationID string) time.Duration {
+	if interval, ok := s.intervals[stationID]; ok {
+		return interval
+	}
+	return s.defaultInterval
+}
+
+// due сообщает, нужно ли опрашивать станцию в цикле, запущенном в момент now.
+// Станции, которые еще не опрашивались, опрашиваются сразу
+func (s *stationSchedule) due(stationID string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, ok := s.nextRun[stationID]
+	return !ok || !next.After(now.Add(s.tolerance))
+}
+
+// markRun запоминает время опроса станции и планирует следующий опрос
+func (s *stationSchedule) markRun(stationID string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextRun[stationID] = now.Add(s.interval(stationID))
+}
+
+// setIntervals заменяет интервалы опроса станций. Запланированные опросы переносятся
+// с учетом нового интервала каждой станции
+func (s *stationSchedule) setIntervals(defaultInterval time.Duration, stationIntervals map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := make(map[string]time.Duration, len(s.nextRun))
+	for stationID := range s.nextRun {
+		old[stationID] = s.interval(stationID)
+	}
+
+	s.defaultInterval = defaultInterval
+	s.intervals = make(map[string]time.Duration, len(stationIntervals))
+	for stationID, minutes := range stationIntervals {
+		s.intervals[stationID] = time.Duration(minutes) * time.Minute
+	}
+
+	for stationID, next := range s.nextRun {
+		s.nextRun[stationID] = next.Add(s.interval(stationID) - old[stationID])
+	}
+}
+
+// setTolerance задает допуск, в пределах которого станции опрашиваются в текущем цикле
+func (s *stationSchedule) setTolerance(tolerance time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tolerance < minCollectionInterval {
+		tolerance = minCollectionInterval
+	}
+	s.tolerance = tolerance
+}
+
+// postponeOverdue переносит опрос просроченных станций на их обычный интервал.
+// Используется, когда цикл не смог получить список станций, чтобы не повторять запросы каждую секунду
+func (s *stationSchedule) postponeOverdue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for stationID, next := range s.nextRun {
+		if !next.After(now) {
+			s.nextRun[stationID] = now.Add(s.interval(stationID))
+		}
+	}
+}
+
+// nextWake возвращает время до ближайшего запланированного опроса станции.
+// Если станции еще не опрашивались, возвращается общий интервал
+func (s *stationSchedule) nextWake(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.nextRun) == 0 {
+		return s.defaultInterval
+	}
+
+	var earliest time.Time
+	for _, next := range s.nextRun {
+		if earliest.IsZero() || next.Before(earliest) {
+			earliest = next
+		}
+	}
+
+	wait := earliest.Sub(now)
+	if wait < minCollectionInterval {
+		wait = minCollectionInterval
+	}
+	return wait
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/logging"
+)
+
+// spoolEntry - телеметрия устройства, которую не удалось сохранить
+type spoolEntry struct {
+	seq       uint64
+	deviceID  string
+	telemetry map[string][]api.TelemetryPoint
+	points    int
+}
+
+// spoolState - заполненность буфера несохраненной телеметрии для отладочного эндпоинта
+type spoolState struct {
+	Entries   int `json:"entries"`
+	Points    int `json:"points"`
+	MaxPoints int `json:"max_points"`
+	Dropped   int `json:"dropped"`
+}
+
+// telemetrySpool хранит в памяти телеметрию, полученную из API, но не сохраненную из-за недоступности
+// хранилища. Размер буфера ограничен количеством точек: при переполнении удаляются самые старые записи
+type telemetrySpool struct {
+	mu        sync.Mutex
+	entries   []spoolEntry
+	points    int
+	maxPoints int
+	dropped   int // количество точек, удаленных при переполнении
+	nextSeq   uint64
+}
+
+// newTelemetrySpool создает буфер на указанное количество точек
+func newTelemetrySpool(maxPoints int) *telemetrySpool {
+	return &telemetrySpool{maxPoints: maxPoints}
+}
+
+// add помещает телеметрию устройства в буфер, удаляя при переполнении самые старые записи
+func (s *telemetrySpool) add(deviceID string, telemetry map[string][]api.TelemetryPoint) {
+	points := 0
+	for _, sensorPoints := range telemetry {
+		points += len(sensorPoints)
+	}
+	if points == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextSeq++
+	s.entries = append(s.entries, spoolEntry{seq: s.nextSeq, deviceID: deviceID, telemetry: telemetry, points: points})
+	s.points += points
+
+	evicted := 0
+	for s.points > s.maxPoints && len(s.entries) > 0 {
+		evicted += s.entries[0].points
+		s.points -= s.entries[0].points
+		s.entries = s.entries[1:]
+	}
+	if evicted > 0 {
+		s.dropped += evicted
+		logging.Warnf("Внимание: буфер несохраненной телеметрии переполнен, удалено самых старых точек: %d", evicted)
+	}
+}
+
+// flush сохраняет телеметрию из буфера в порядке поступления. При первой ошибке сохранение прекращается,
+// оставшиеся записи ждут следующей попытки. Возвращает количество сохраненных точек
+func (s *telemetrySpool) flush(ctx context.Context, store func(ctx context.Context, deviceID string, telemetry map[string][]api.TelemetryPoint) error) (int, error) {
+	stored := 0
+	for {
+		s.mu.Lock()
+		if len(s.entries) == 0 {
+			s.mu.Unlock()
+			return stored, nil
+		}
+		entry := s.entries[0]
+		s.mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return stored, err
+		}
+		if err := store(ctx, entry.deviceID, entry.telemetry); err != nil {
+			return stored, err
+		}
+
+		// Запись могла быть вытеснена, пока выполнялось сохранение
+		s.mu.Lock()
+		if len(s.entries) > 0 && s.entries[0].seq == entry.seq {
+			s.entries = s.entries[1:]
+			s.points -= entry.points
+		}
+		s.mu.Unlock()
+		stored += entry.points
+	}
+}
+
+// state возвращает текущую заполненность буфера
+func (s *telemetrySpool) state() spoolState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return spoolState{Entries: len(s.entries), Points: s.points, MaxPoints: s.maxPoints, Dropped: s.dropped}
+}
+
+// flushSpool сохраняет телеметрию, накопленную в буфере за время недоступности хранилища
+func (c *collector) flushSpool(ctx context.Context) {
+	if c.spool == nil || c.spool.state().Entries == 0 {
+		return
+	}
+
+	before := c.spool.state()
+	stored, err := c.spool.flush(ctx, func(ctx context.Context, deviceID string, telemetry map[string][]api.TelemetryPoint) error {
+		if err := c.storeTelemetry(ctx, deviceID, telemetry); err != nil {
+			return err
+		}
+		if c.publisher != nil {
+			c.publisher.Publish(deviceID, telemetry)
+		}
+		return nil
+	})
+	if stored > 0 {
+		log.Printf("Из буфера сохранено точек телеметрии: %d из %d", stored, before.Points)
+	}
+	if err != nil {
+		after := c.spool.state()
+		logging.Errorf("Ошибка при сохранении телеметрии из буфера, в буфере осталось записей: %d (точек: %d): %v",
+			after.Entries, after.Points, err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"weatherInTheField/pkg/database"
+	"weatherInTheField/pkg/logging"
+)
+
+// staleTracker запоминает датчики, переставшие передавать данные, чтобы предупреждать
+// об отключении один раз, а не в каждом цикле
+type staleTracker struct {
+	mu    sync.Mutex
+	stale map[string]bool // ключ - "станция/датчик"
+}
+
+// update отмечает состояние датчика и сообщает, изменилось ли оно
+func (t *staleTracker) update(stationID, sensorKey string, stale bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := stationID + "/" + sensorKey
+	if t.stale[key] == stale {
+		return false
+	}
+
+	if t.stale == nil {
+		t.stale = make(map[string]bool)
+	}
+	if stale {
+		t.stale[key] = true
+	} else {
+		delete(t.stale, key)
+	}
+	return true
+}
+
+// checkStaleSensors сравнивает время последних сохраненных данных датчиков станции с текущим временем.
+// О датчике, не передававшем данные дольше SENSOR_STALE_MINUTES, предупреждает один раз за отключение,
+// о возобновлении передачи - один раз. Датчики, по которым данных еще не было, не проверяются
+func (c *collector) checkStaleSensors(ctx context.Context, deviceID string, sensorKeys []string) {
+	latestTimestamps, err := c.dbManager.GetLatestTimestamps(deviceID)
+	if err != nil {
+		logging.Errorf("Ошибка при проверке датчиков устройства %s, переставших передавать данные: %v", deviceID, err)
+		return
+	}
+
+	now := c.clock.Now()
+	for _, sensorKey := range sensorKeys {
+		lastTs := latestTimestamps[sensorKey]
+		if lastTs == 0 {
+			continue
+		}
+
+		lastData := database.DateValueFromTimestamp(lastTs)
+		silence := now.Sub(lastData)
+		stale := silence > c.staleAfter
+		if !c.staleSensors.update(deviceID, sensorKey, stale) {
+			continue
+		}
+
+		if !stale {
+			log.Printf("Датчик возобновил передачу данных: station=%s sensor=%s last_data=%s",
+				deviceID, sensorKey, formatTs(lastTs))
+			continue
+		}
+
+		logging.Warnf("ВНИМАНИЕ: датчик перестал передавать данные: station=%s sensor=%s last_data=%s silence=%s",
+			deviceID, sensorKey, formatTs(lastTs), silence.Round(time.Minute))
+		if c.stationWebhook != nil {
+			if err := c.stationWebhook.NotifySensorStale(ctx, deviceID, sensorKey, lastData); err != nil {
+				logging.Errorf("Ошибка при отправке уведомления о датчике %s устройства %s: %v", sensorKey, deviceID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/logging"
+)
+
+// stationRefresh хранит интервал обновления данных станций и станции, которые уже сохранены.
+// Данные всех станций обновляются по отдельному таймеру с интервалом STATION_REFRESH_MINUTES
+// независимо от циклов сбора, а циклы сбора сохраняют только станции, которые еще не сохранялись:
+// без них не запишется телеметрия (внешний ключ на таблицу Stations)
+type stationRefresh struct {
+	mu       sync.Mutex
+	interval time.Duration
+	stored   map[string]bool
+}
+
+// setInterval задает интервал обновления данных всех станций
+func (r *stationRefresh) setInterval(interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interval = interval
+}
+
+// currentInterval возвращает интервал обновления данных всех станций
+func (r *stationRefresh) currentInterval() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.interval
+}
+
+// newDevices возвращает станции, которые еще не сохранялись
+func (r *stationRefresh) newDevices(devices []api.Device) []api.Device {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var result []api.Device
+	for _, device := range devices {
+		if !r.stored[device.ID] {
+			result = append(result, device)
+		}
+	}
+	return result
+}
+
+// markStored запоминает сохраненные станции
+func (r *stationRefresh) markStored(devices []api.Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stored == nil {
+		r.stored = make(map[string]bool, len(devices))
+	}
+	for _, device := range devices {
+		r.stored[device.ID] = true
+	}
+}
+
+// refreshStations обновляет данные всех станций (название, координаты) в таблице Stations.
+// Вызывается по таймеру обновления станций независимо от циклов сбора
+func (c *collector) refreshStations(ctx context.Context) {
+	devices, err := c.weatherAPI.GetDevicesCached(ctx, c.deviceCacheTTL)
+	if err != nil {
+		logging.Errorf("Ошибка при получении списка устройств для обновления станций: %v", err)
+		return
+	}
+	if c.labelFilter != nil {
+		devices = filterDevicesByLabel(devices, c.labelFilter)
+	}
+	if len(devices) == 0 {
+		return
+	}
+
+	var knownStations map[string]bool
+	if c.stationWebhook != nil {
+		knownStations, err = c.loadKnownStations()
+		if err != nil {
+			logging.Errorf("Ошибка при получении списка известных станций: %v", err)
+		}
+	}
+
+	if err := c.dbManager.StoreStations(devices); err != nil {
+		logging.Errorf("Ошибка при обновлении информации о станциях: %v", err)
+		return
+	}
+	c.stationRefresh.markStored(devices)
+	if knownStations != nil {
+		c.notifyNewStations(ctx, devices, knownStations)
+	}
+	log.Printf("Обновлены данные станций: %d", len(devices))
+}
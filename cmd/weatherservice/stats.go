@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/database"
+)
+
+// printStats выводит статистику хранилища: количество записей всего и по станциям, период данных
+// и размер таблицы телеметрии. Используется только база данных, запросы к API не выполняются
+func printStats(cfg *config.Config, out io.Writer) error {
+	if cfg.Sink != config.SinkMSSQL {
+		return fmt.Errorf("статистика поддерживается только для хранилища %s", config.SinkMSSQL)
+	}
+
+	dbManager, err := database.NewDBManager(cfg)
+	if err != nil {
+		return fmt.Errorf("ошибка при подключении к БД: %w", err)
+	}
+	defer dbManager.Close()
+
+	stats, err := dbManager.Stats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Записей телеметрии: %d\n", stats.TotalRows)
+	if stats.EarliestDate != nil && stats.LatestDate != nil {
+		fmt.Fprintf(out, "Период данных: %s - %s\n",
+			stats.EarliestDate.In(displayLocation).Format("2006-01-02 15:04:05"),
+			stats.LatestDate.In(displayLocation).Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintf(out, "Размер таблицы Telemetry: %.1f МБ\n\n", float64(stats.TableSizeBytes)/(1024*1024))
+
+	// Станции выводятся по убыванию количества записей
+	stations := make([]string, 0, len(stats.StationRows))
+	for stationID := range stats.StationRows {
+		stations = append(stations, stationID)
+	}
+	sort.Slice(stations, func(i, j int) bool {
+		if stats.StationRows[stations[i]] != stats.StationRows[stations[j]] {
+			return stats.StationRows[stations[i]] > stats.StationRows[stations[j]]
+		}
+		return stations[i] < stations[j]
+	})
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "СТАНЦИЯ\tЗАПИСЕЙ")
+	for _, stationID := range stations {
+		fmt.Fprintf(tw, "%s\t%d\n", stationID, stats.StationRows[stationID])
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/database"
+	"weatherInTheField/pkg/influx"
+	"weatherInTheField/pkg/units"
+)
+
+// validateCheck - одна проверка предварительного запуска. Если ready возвращает false
+// (например, конфигурация не загружена), проверка пропускается
+type validateCheck struct {
+	name  string
+	ready func() bool
+	run   func() error
+}
+
+// validate проверяет конфигурацию, вход в API, подключение к хранилищу и схему базы данных
+// и выводит результат каждой проверки. Сбор данных не запускается, данные и схема не изменяются.
+// Возвращает код завершения: 0, если все проверки пройдены, иначе 1
+func validate(out io.Writer) int {
+	var cfg *config.Config
+	var dbManager *database.DBManager
+	defer func() {
+		if dbManager != nil {
+			dbManager.Close()
+		}
+	}()
+
+	configLoaded := func() bool { return cfg != nil }
+
+	checks := []validateCheck{
+		{"Конфигурация", nil, func() error {
+			var err error
+			if cfg, err = config.LoadConfig(); err != nil {
+				return err
+			}
+			if _, err := units.Resolve(cfg.SensorConversions); err != nil {
+				return fmt.Errorf("ошибка в конфигурации преобразований единиц измерения: %w", err)
+			}
+			return nil
+		}},
+		{"Вход в API", configLoaded, func() error {
+			return api.NewWeatherAPI(cfg).Login()
+		}},
+		{"Подключение к хранилищу", configLoaded, func() error {
+			if cfg.Sink == config.SinkInflux {
+				sink, err := influx.NewInfluxSink(cfg)
+				if err != nil {
+					return err
+				}
+				return sink.Close()
+			}
+
+			var err error
+			if dbManager, err = database.NewDBManager(cfg, databaseOptions(cfg)...); err != nil {
+				return err
+			}
+			return dbManager.Healthy(context.Background())
+		}},
+		{"Схема базы данных", func() bool { return dbManager != nil }, func() error {
+			return dbManager.VerifySchema()
+		}},
+	}
+
+	failed := false
+	for _, check := range checks {
+		if check.ready != nil && !check.ready() {
+			fmt.Fprintf(out, "[ПРОПУСК] %s\n", check.name)
+			continue
+		}
+
+		if err := check.run(); err != nil {
+			failed = true
+			fmt.Fprintf(out, "[ОШИБКА] %s: %v\n", check.name, err)
+			continue
+		}
+		fmt.Fprintf(out, "[OK] %s\n", check.name)
+	}
+
+	if failed {
+		fmt.Fprintln(out, "Проверка не пройдена")
+		return 1
+	}
+	fmt.Fprintln(out, "Все проверки пройдены")
+	return 0
+}
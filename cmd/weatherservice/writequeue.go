@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// writeJob - сохранение телеметрии одного периода устройства
+type writeJob struct {
+	deviceID string
+	run      func()
+}
+
+// writeQueueState - заполненность очереди записи для отладочного эндпоинта
+type writeQueueState struct {
+	Workers  int `json:"workers"`
+	Depth    int `json:"depth"`
+	MaxDepth int `json:"max_depth"`
+	Capacity int `json:"capacity"`
+}
+
+// writeQueue сохраняет телеметрию в горутинах записи, пока сбор запрашивает следующие периоды и устройства.
+// У каждой горутины своя очередь ограниченного размера; все записи устройства попадают в одну очередь,
+// поэтому сохраняются в порядке получения. При заполнении очереди сбор ожидает освобождения места
+type writeQueue struct {
+	queues   []chan writeJob
+	capacity int
+	wg       sync.WaitGroup
+
+	// Количество записей в очередях и наибольшее количество с начала цикла
+	depth    atomic.Int64
+	maxDepth atomic.Int64
+
+	closeOnce sync.Once
+}
+
+// newWriteQueue создает очередь записи и запускает горутины записи. Размер очереди size
+// распределяется между горутинами поровну
+func newWriteQueue(workers, size int) *writeQueue {
+	perWorker := (size + workers - 1) / workers
+	q := &writeQueue{
+		queues:   make([]chan writeJob, workers),
+		capacity: perWorker * workers,
+	}
+
+	for i := range q.queues {
+		q.queues[i] = make(chan writeJob, perWorker)
+		q.wg.Add(1)
+		go q.work(q.queues[i])
+	}
+	return q
+}
+
+// work выполняет записи из очереди до ее закрытия
+func (q *writeQueue) work(jobs <-chan writeJob) {
+	defer q.wg.Done()
+	for job := range jobs {
+		job.run()
+		q.depth.Add(-1)
+	}
+}
+
+// enqueue помещает запись в очередь устройства. Если очередь заполнена, ожидает освобождения места;
+// при отмене контекста запись не помещается в очередь и возвращается ошибка контекста
+func (q *writeQueue) enqueue(ctx context.Context, job writeJob) error {
+	h := fnv.New32a()
+	h.Write([]byte(job.deviceID))
+	jobs := q.queues[h.Sum32()%uint32(len(q.queues))]
+
+	// Глубина увеличивается до помещения в очередь, чтобы горутина записи не уменьшила ее раньше
+	depth := q.depth.Add(1)
+	select {
+	case jobs <- job:
+	case <-ctx.Done():
+		q.depth.Add(-1)
+		return ctx.Err()
+	}
+
+	for {
+		maxDepth := q.maxDepth.Load()
+		if depth <= maxDepth || q.maxDepth.CompareAndSwap(maxDepth, depth) {
+			break
+		}
+	}
+	return nil
+}
+
+// takeMaxDepth возвращает наибольшее количество записей в очереди с предыдущего вызова
+func (q *writeQueue) takeMaxDepth() int {
+	return int(q.maxDepth.Swap(q.depth.Load()))
+}
+
+// state возвращает текущую заполненность очереди
+func (q *writeQueue) state() writeQueueState {
+	return writeQueueState{
+		Workers:  len(q.queues),
+		Depth:    int(q.depth.Load()),
+		MaxDepth: int(q.maxDepth.Load()),
+		Capacity: q.capacity,
+	}
+}
+
+// close закрывает очередь и ожидает сохранения уже помещенных в нее записей
+func (q *writeQueue) close() {
+	q.closeOnce.Do(func() {
+		for _, jobs := range q.queues {
+			close(jobs)
+		}
+	})
+	q.wg.Wait()
+}
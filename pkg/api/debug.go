@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// debugTransport выводит в лог запросы к API и ответы на них (API_DEBUG_HTTP).
+// Значения скрываемых полей JSON (по умолчанию пароль и токены сессии) в теле заменяются на "***"
+type debugTransport struct {
+	next http.RoundTripper
+
+	// Количество байт тела запроса и ответа, выводимых в лог (0 - тело не выводится)
+	bodyLimit int
+
+	// Имена скрываемых полей JSON в нижнем регистре
+	redacted map[string]bool
+}
+
+// newDebugTransport оборачивает транспорт логированием запросов и ответов.
+// Имена скрываемых полей сравниваются без учета регистра
+func newDebugTransport(next http.RoundTripper, bodyLimit int, redactFields []string) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	redacted := make(map[string]bool, len(redactFields))
+	for _, field := range redactFields {
+		redacted[strings.ToLower(field)] = true
+	}
+
+	return &debugTransport{next: next, bodyLimit: bodyLimit, redacted: redacted}
+}
+
+// RoundTrip выполняет запрос, выводя в лог его адрес и тело, статус и начало тела ответа
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		log.Printf("[HTTP] --> %s %s %s", req.Method, req.URL, t.redactBody(body))
+	} else {
+		log.Printf("[HTTP] --> %s %s", req.Method, req.URL)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		log.Printf("[HTTP] <-- %s %s ошибка: %v", req.Method, req.URL, err)
+		return nil, err
+	}
+
+	if t.bodyLimit <= 0 {
+		log.Printf("[HTTP] <-- %s %s %d", req.Method, req.URL, resp.StatusCode)
+		return resp, nil
+	}
+
+	// Сжатое тело не выводится: распаковка выполняется клиентом после транспорта
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		log.Printf("[HTTP] <-- %s %s %d [тело сжато: %s]", req.Method, req.URL, resp.StatusCode, encoding)
+		return resp, nil
+	}
+
+	// Читаем только начало тела ответа и возвращаем его обратно перед оставшейся частью
+	head, err := io.ReadAll(io.LimitReader(resp.Body, int64(t.bodyLimit)))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+
+	log.Printf("[HTTP] <-- %s %s %d %s", req.Method, req.URL, resp.StatusCode, t.redactBody(head))
+	return resp, nil
+}
+
+// redactBody возвращает начало тела для вывода в лог, скрывая значения скрываемых полей.
+// Поля скрываются в разобранном JSON по точному имени ключа на любом уровне вложенности.
+// Если тело не удается разобрать как JSON (например, оно обрезано), поля скрываются по имени в тексте
+func (t *debugTransport) redactBody(body []byte) string {
+	if t.bodyLimit <= 0 {
+		return ""
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err == nil {
+		t.redactValue(value)
+		if redacted, err := json.Marshal(value); err == nil {
+			body = redacted
+		}
+	} else {
+		body = t.redactText(body)
+	}
+
+	if len(body) > t.bodyLimit {
+		return string(body[:t.bodyLimit]) + "..."
+	}
+	return string(body)
+}
+
+// redactValue заменяет значения скрываемых полей в разобранном JSON
+func (t *debugTransport) redactValue(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if t.redacted[strings.ToLower(key)] {
+				v[key] = "***"
+				continue
+			}
+			t.redactValue(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			t.redactValue(item)
+		}
+	}
+}
+
+// redactText скрывает строковые значения скрываемых полей в тексте, который не является корректным JSON.
+// Если после имени поля нет закрывающей кавычки значения, скрывается вся оставшаяся часть текста
+func (t *debugTransport) redactText(body []byte) []byte {
+	result := append([]byte(nil), body...)
+	for field := range t.redacted {
+		marker := []byte(`"` + field + `":`)
+		for start := 0; ; {
+			i := bytes.Index(result[start:], marker)
+			if i < 0 {
+				break
+			}
+			valueStart := start + i + len(marker)
+			for valueStart < len(result) && result[valueStart] == ' ' {
+				valueStart++
+			}
+			if valueStart >= len(result) || result[valueStart] != '"' {
+				start = valueStart
+				continue
+			}
+
+			end := bytes.IndexByte(result[valueStart+1:], '"')
+			if end < 0 {
+				result = append(result[:valueStart], []byte(`"***`)...)
+				break
+			}
+			valueEnd := valueStart + 1 + end + 1
+			result = append(result[:valueStart], append([]byte(`"***"`), result[valueEnd:]...)...)
+			start = valueStart + len(`"***"`)
+		}
+	}
+	return result
+}
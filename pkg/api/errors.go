@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Ошибки API, с которыми можно сравнивать через errors.Is
+var (
+	// ErrSessionExpired - сессия недействительна или истекла, требуется повторный вход
+	ErrSessionExpired = errors.New("сессия недействительна")
+
+	// ErrRateLimited - превышен лимит запросов к API
+	ErrRateLimited = errors.New("превышен лимит запросов")
+
+	// ErrBadRequest - API отклонило некорректный запрос
+	ErrBadRequest = errors.New("некорректный запрос")
+
+	// ErrInvalidCredentials - API отклонило логин или пароль. Повторять вход бессмысленно
+	ErrInvalidCredentials = errors.New("неверный логин или пароль")
+
+	// ErrNoSession - API подтвердило вход, но не вернуло токен сессии
+	ErrNoSession = errors.New("отсутствует токен сессии в ответе")
+
+	// ErrNetwork - запрос не выполнен из-за сетевой ошибки (соединение, таймаут, обрыв ответа)
+	ErrNetwork = errors.New("ошибка сети")
+
+	// ErrServer - API вернуло ошибку сервера (HTTP 5xx)
+	ErrServer = errors.New("ошибка сервера API")
+
+	// ErrResponseTooLarge - тело ответа превышает MAX_RESPONSE_BYTES. Повторять запрос бессмысленно
+	ErrResponseTooLarge = errors.New("ответ слишком большой")
+)
+
+// Значения additional_code, по которым определяется причина ошибки. api.md описывает additional_code
+// как необязательное текстовое поле без перечня значений, поэтому код сравнивается целиком
+// (без учета регистра): код, лишь содержащий похожее слово (например, "auth_ok"), не распознается,
+// и ошибка классифицируется по HTTP статусу
+var (
+	sessionExpiredCodes = codeSet("session_expired", "invalid_sid", "unauthorized")
+	rateLimitedCodes    = codeSet("rate_limited", "too_many_requests")
+	badRequestCodes     = codeSet("bad_request", "invalid_params")
+
+	// Фрагменты текста ошибки, указывающие на недействительную сессию
+	sessionExpiredMessages = []string{"sid", "session", "сесси", "авториз"}
+)
+
+// APIError представляет собой ошибку, возвращенную API в ответе со статусом, отличным от OK
+type APIError struct {
+	HTTPStatus     int    // HTTP статус ответа
+	Status         string // значение поля status
+	Message        string // значение поля error
+	AdditionalCode string // значение поля additional_code
+}
+
+// Error возвращает описание ошибки
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("API вернуло ошибку (HTTP %d, статус %q)", e.HTTPStatus, e.Status)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.AdditionalCode != "" {
+		msg += " [" + e.AdditionalCode + "]"
+	}
+	return msg
+}
+
+// Is позволяет сравнивать APIError с ErrSessionExpired, ErrRateLimited и ErrBadRequest
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrSessionExpired:
+		return e.HTTPStatus == http.StatusUnauthorized ||
+			e.HTTPStatus == http.StatusForbidden ||
+			sessionExpiredCodes[strings.ToLower(e.AdditionalCode)] ||
+			messageContains(e.Message, sessionExpiredMessages)
+	case ErrRateLimited:
+		return e.HTTPStatus == http.StatusTooManyRequests ||
+			rateLimitedCodes[strings.ToLower(e.AdditionalCode)]
+	case ErrBadRequest:
+		return e.HTTPStatus == http.StatusBadRequest ||
+			badRequestCodes[strings.ToLower(e.AdditionalCode)]
+	}
+	return false
+}
+
+// IsSessionExpired сообщает, вызвана ли ошибка недействительной сессией
+func IsSessionExpired(err error) bool {
+	return errors.Is(err, ErrSessionExpired)
+}
+
+// IsRateLimited сообщает, вызвана ли ошибка превышением лимита запросов
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsTemporary сообщает, что ошибка временная и запрос (в том числе вход) можно повторить позже:
+// сетевая ошибка, ошибка сервера или превышение лимита запросов
+func IsTemporary(err error) bool {
+	return errors.Is(err, ErrNetwork) || errors.Is(err, ErrServer) || errors.Is(err, ErrRateLimited)
+}
+
+// codeSet возвращает множество кодов additional_code
+func codeSet(codes ...string) map[string]bool {
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}
+
+// messageContains проверяет, содержит ли текст ошибки один из известных фрагментов
+func messageContains(message string, fragments []string) bool {
+	message = strings.ToLower(message)
+	if message == "" {
+		return false
+	}
+	for _, fragment := range fragments {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestAPIErrorCodes(t *testing.T) {
+	tests := []struct {
+		code           string
+		sessionExpired bool
+		rateLimited    bool
+		badRequest     bool
+	}{
+		{code: "session_expired", sessionExpired: true},
+		{code: "INVALID_SID", sessionExpired: true},
+		{code: "unauthorized", sessionExpired: true},
+		{code: "rate_limited", rateLimited: true},
+		{code: "too_many_requests", rateLimited: true},
+		{code: "bad_request", badRequest: true},
+		{code: "invalid_params", badRequest: true},
+
+		// Коды, лишь содержащие похожие слова, не распознаются
+		{code: "auth_ok"},
+		{code: "token_refreshed"},
+		{code: "sid"},
+		{code: "invalid_device"},
+		{code: "param"},
+		{code: "limit"},
+		{code: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			err := &APIError{HTTPStatus: http.StatusOK, Status: "error", AdditionalCode: tt.code}
+
+			if got := errors.Is(err, ErrSessionExpired); got != tt.sessionExpired {
+				t.Errorf("ErrSessionExpired = %v, ожидалось %v", got, tt.sessionExpired)
+			}
+			if got := errors.Is(err, ErrRateLimited); got != tt.rateLimited {
+				t.Errorf("ErrRateLimited = %v, ожидалось %v", got, tt.rateLimited)
+			}
+			if got := errors.Is(err, ErrBadRequest); got != tt.badRequest {
+				t.Errorf("ErrBadRequest = %v, ожидалось %v", got, tt.badRequest)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencySummary содержит сводку времени ответа API на запросы к одному эндпоинту
+type LatencySummary struct {
+	Count int
+	P50   time.Duration
+	P95   time.Duration
+	Max   time.Duration
+}
+
+// LatencyReporter реализуется клиентами, измеряющими время ответа API.
+// Проверяется приведением типа, как и другие необязательные возможности
+type LatencyReporter interface {
+	// TakeLatencies возвращает сводку по эндпоинтам с момента предыдущего вызова и сбрасывает накопленные замеры
+	TakeLatencies() map[string]LatencySummary
+}
+
+// Проверка, что WeatherAPI реализует интерфейс LatencyReporter
+var _ LatencyReporter = (*WeatherAPI)(nil)
+
+// latencyRecorder накапливает замеры времени ответа по эндпоинтам
+type latencyRecorder struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+}
+
+// record добавляет замер времени ответа эндпоинта
+func (r *latencyRecorder) record(endpoint string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.samples == nil {
+		r.samples = make(map[string][]time.Duration)
+	}
+	r.samples[endpoint] = append(r.samples[endpoint], d)
+}
+
+// take возвращает сводку по накопленным замерам и сбрасывает их
+func (r *latencyRecorder) take() map[string]LatencySummary {
+	r.mu.Lock()
+	samples := r.samples
+	r.samples = nil
+	r.mu.Unlock()
+
+	result := make(map[string]LatencySummary, len(samples))
+	for endpoint, durations := range samples {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		result[endpoint] = LatencySummary{
+			Count: len(durations),
+			P50:   percentile(durations, 50),
+			P95:   percentile(durations, 95),
+			Max:   durations[len(durations)-1],
+		}
+	}
+	return result
+}
+
+// percentile возвращает перцентиль p упорядоченных по возрастанию замеров (метод ближайшего ранга)
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// TakeLatencies возвращает сводку времени ответа API по эндпоинтам с момента предыдущего вызова
+func (w *WeatherAPI) TakeLatencies() map[string]LatencySummary {
+	return w.latencies.take()
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"weatherInTheField/pkg/clock"
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/logging"
+)
+
+// Option задает дополнительную настройку API клиента
+type Option func(*WeatherAPI)
+
+// WithTransport задает собственный http.RoundTripper для API клиента.
+// Заменяет транспорт, созданный по конфигурации (включая настройки прокси)
+func WithTransport(rt http.RoundTripper) Option {
+	return func(w *WeatherAPI) {
+		w.Client.Transport = rt
+	}
+}
+
+// WithSession задает заранее полученный токен сессии (и токен обновления), чтобы не выполнять
+// первоначальный вход. Если сессия окажется недействительной, клиент автоматически войдет заново
+func WithSession(sid, refresh string) Option {
+	return func(w *WeatherAPI) {
+		w.SessionID = sid
+		w.RefreshToken = refresh
+	}
+}
+
+// WithRawStore задает хранилище, в которое сохраняются исходные ответы на запросы телеметрии (RAW_STORE_PATH)
+func WithRawStore(store *RawStore) Option {
+	return func(w *WeatherAPI) {
+		w.rawStore = store
+	}
+}
+
+// WithClock задает источник текущего времени вместо системных часов
+func WithClock(c clock.Clock) Option {
+	return func(w *WeatherAPI) {
+		w.clock = c
+	}
+}
+
+// newTransport создает HTTP транспорт по настройкам конфигурации.
+// Если API_PROXY не указан, используются стандартные переменные HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+func newTransport(cfg *config.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.ApiProxy != "" {
+		// Адрес прокси проверяется при загрузке конфигурации
+		if proxyURL, err := url.Parse(cfg.ApiProxy); err == nil {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	} else {
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+
+	if cfg.ApiCACert != "" || cfg.ApiInsecureSkipVerify {
+		tlsConfig, err := newTLSConfig(cfg.ApiCACert, cfg.ApiInsecureSkipVerify)
+		if err != nil {
+			// Файл сертификатов проверяется при загрузке конфигурации
+			logging.Errorf("Ошибка при настройке TLS для API, используются системные сертификаты: %v", err)
+		} else {
+			transport.TLSClientConfig = tlsConfig
+		}
+	}
+
+	return transport
+}
+
+// newTLSConfig создает настройки TLS для запросов к API. Сертификаты из caCertPath (PEM) добавляются
+// к системным, чтобы проходила проверка сертификатов, выпущенных внутренним центром сертификации
+// (например, прокси с перехватом TLS). При insecureSkipVerify сертификат сервера не проверяется
+func newTLSConfig(caCertPath string, insecureSkipVerify bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	if caCertPath != "" {
+		pem, err := os.ReadFile(caCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении сертификатов API_CA_CERT: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("в файле %s нет сертификатов в формате PEM", caCertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"weatherInTheField/pkg/config"
+)
+
+// loginOK - ответ API на успешный вход
+const loginOK = `{"status":"ok","data":{"sid":"test-sid","refresh":"test-refresh"}}`
+
+// testConfig возвращает минимальную конфигурацию API клиента с указанными адресами API
+func testConfig(baseURLs ...string) *config.Config {
+	return &config.Config{
+		ApiBaseURL:  baseURLs[0],
+		ApiBaseURLs: baseURLs,
+		ApiLogin:    "user",
+		ApiPassword: "secret",
+		ApiTsUnit:   config.TsUnitMillis,
+	}
+}
+
+func TestProxyFromConfig(t *testing.T) {
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// Прокси получает запрос с абсолютным адресом целевого сервера
+		proxiedHost = r.URL.Host
+		io.WriteString(rw, loginOK)
+	}))
+	defer proxy.Close()
+
+	cfg := testConfig("http://weather.invalid")
+	cfg.ApiProxy = proxy.URL
+	w := NewWeatherAPI(cfg)
+
+	if err := w.Login(); err != nil {
+		t.Fatalf("вход через прокси: %v", err)
+	}
+	if proxiedHost != "weather.invalid" {
+		t.Errorf("прокси получил запрос к %q, ожидался weather.invalid", proxiedHost)
+	}
+	if w.sessionID() != "test-sid" {
+		t.Errorf("токен сессии %q, ожидался test-sid", w.sessionID())
+	}
+}
+
+// roundTripFunc позволяет использовать функцию как http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestWithTransport(t *testing.T) {
+	var requested string
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.String()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(loginOK)),
+			Request:    req,
+		}, nil
+	})
+
+	w := NewWeatherAPI(testConfig("http://weather.invalid"), WithTransport(transport))
+	if err := w.Login(); err != nil {
+		t.Fatalf("вход через собственный транспорт: %v", err)
+	}
+	if requested != "http://weather.invalid/login" {
+		t.Errorf("запрос отправлен на %q, ожидался http://weather.invalid/login", requested)
+	}
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, loginOK)
+	}))
+	defer srv.Close()
+
+	// Самоподписанный сертификат тестового сервера в формате PEM
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatalf("ошибка при записи сертификата: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		caCert   string
+		insecure bool
+		wantErr  bool
+	}{
+		{name: "системные сертификаты", wantErr: true},
+		{name: "API_CA_CERT", caCert: caFile},
+		{name: "API_INSECURE_SKIP_VERIFY", insecure: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(srv.URL)
+			cfg.ApiCACert = tt.caCert
+			cfg.ApiInsecureSkipVerify = tt.insecure
+
+			err := NewWeatherAPI(cfg).Login()
+			if tt.wantErr && err == nil {
+				t.Fatal("вход выполнен без доверия к сертификату сервера, ожидалась ошибка TLS")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("ошибка входа: %v", err)
+			}
+		})
+	}
+}
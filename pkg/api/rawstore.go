@@ -0,0 +1,129 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"weatherInTheField/pkg/logging"
+)
+
+// rawStoreCleanupInterval - минимальный интервал между удалениями устаревших файлов
+const rawStoreCleanupInterval = time.Hour
+
+// unsafeFileChars - символы ID устройства, недопустимые в имени файла
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// RawStore сохраняет исходные ответы API на запросы телеметрии в сжатые файлы,
+// чтобы полученные данные можно было обработать повторно без обращения к API
+type RawStore struct {
+	dir       string
+	retention time.Duration // 0 - файлы не удаляются
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+// NewRawStore создает хранилище исходных ответов в каталоге dir.
+// Файлы старше retention удаляются (0 - хранятся бессрочно)
+func NewRawStore(dir string, retention time.Duration) (*RawStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("ошибка при создании каталога исходных ответов %s: %w", dir, err)
+	}
+	return &RawStore{dir: dir, retention: retention}, nil
+}
+
+// Save сохраняет исходный ответ на запрос телеметрии устройства за период [tsFrom, tsTo].
+// Имя файла содержит ID устройства, период и время получения ответа: <устройство>_<from>_<to>_<получено>.json.gz
+func (s *RawStore) Save(deviceID string, tsFrom, tsTo int64, body []byte) error {
+	name := fmt.Sprintf("%s_%d_%d_%d.json.gz",
+		unsafeFileChars.ReplaceAllString(deviceID, "_"), tsFrom, tsTo, time.Now().UnixMilli())
+
+	// Пишем во временный файл и переименовываем, чтобы не оставлять недописанные файлы
+	tmp, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка при создании файла исходного ответа: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	if _, err := gz.Write(body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка при записи исходного ответа: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка при записи исходного ответа: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка при записи исходного ответа: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("ошибка при сохранении исходного ответа: %w", err)
+	}
+
+	s.cleanup()
+	return nil
+}
+
+// cleanup удаляет файлы исходных ответов старше срока хранения. Выполняется не чаще раза в час
+func (s *RawStore) cleanup() {
+	if s.retention <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	if time.Since(s.lastCleanup) < rawStoreCleanupInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastCleanup = time.Now()
+	s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		logging.Errorf("Ошибка при чтении каталога исходных ответов %s: %v", s.dir, err)
+		return
+	}
+
+	removed := 0
+	cutoff := time.Now().Add(-s.retention)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json.gz") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil {
+			logging.Errorf("Ошибка при удалении устаревшего исходного ответа %s: %v", entry.Name(), err)
+			continue
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		log.Printf("Удалено устаревших файлов исходных ответов: %d", removed)
+	}
+}
+
+// rawCapture запоминает исходное тело ответа при разборе JSON в out
+type rawCapture struct {
+	out interface{}
+	raw []byte
+}
+
+// UnmarshalJSON сохраняет копию тела и разбирает его в out
+func (r *rawCapture) UnmarshalJSON(data []byte) error {
+	r.raw = append([]byte(nil), data...)
+	return json.Unmarshal(data, r.out)
+}
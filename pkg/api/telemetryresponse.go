@@ -0,0 +1,163 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// UnmarshalJSON разбирает ответ на запрос телеметрии. Поле data может быть плоским массивом записей,
+// объектом по ключам датчиков из api.md: {"<ключ датчика>": [{"ts": ..., "value": ...}]}, или объектом,
+// сгруппированным по ID устройства: {"<ID>": [записи]} или {"<ID>": {"<ключ датчика>": [записи]}}.
+// В сгруппированных форматах недостающие entity_id и key заполняются ключами объекта, а записи
+// упорядочиваются по устройству и датчику. Неизвестный формат data возвращается ошибкой, чтобы
+// изменение формата ответа не приводило к пустым данным
+func (r *TelemetryResponse) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Status       string          `json:"status"`
+		RecordsCount int             `json:"records_count"`
+		Data         json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	r.Status = raw.Status
+	r.RecordsCount = raw.RecordsCount
+	r.Data = nil
+
+	items, err := decodeTelemetryData(raw.Data)
+	if err != nil {
+		return err
+	}
+	r.Data = items
+	return nil
+}
+
+// decodeTelemetryData разбирает поле data ответа на запрос телеметрии в любом из поддерживаемых форматов
+func decodeTelemetryData(raw json.RawMessage) ([]TelemetryData, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	switch raw[0] {
+	case '[':
+		var items []TelemetryData
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, fmt.Errorf("ошибка при разборе массива data: %w", err)
+		}
+		return items, nil
+	case '{':
+		var byDevice map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &byDevice); err != nil {
+			return nil, fmt.Errorf("ошибка при разборе объекта data: %w", err)
+		}
+
+		if sensorKeyed(byDevice) {
+			return decodeSensorTelemetry(byDevice)
+		}
+
+		var items []TelemetryData
+		for _, deviceID := range sortedKeys(byDevice) {
+			deviceItems, err := decodeDeviceTelemetry(deviceID, byDevice[deviceID])
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, deviceItems...)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат поля data ответа на запрос телеметрии: %.50s", raw)
+	}
+}
+
+// sensorKeyed сообщает, что объект data сгруппирован по ключам датчиков (формат из api.md):
+// все значения - массивы записей со значением value и без entity_id и key. В объекте, сгруппированном
+// по устройствам, записи содержат ключ датчика или вложены в объект по датчикам
+func sensorKeyed(byKey map[string]json.RawMessage) bool {
+	records := 0
+	for _, raw := range byKey {
+		var items []map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return false
+		}
+		for _, item := range items {
+			_, hasValue := item["value"]
+			_, hasEntity := item["entity_id"]
+			_, hasKey := item["key"]
+			if !hasValue || hasEntity || hasKey {
+				return false
+			}
+			records++
+		}
+	}
+	return records > 0
+}
+
+// decodeSensorTelemetry разбирает объект data, сгруппированный по ключам датчиков.
+// Ключ датчика записей берется из ключа объекта
+func decodeSensorTelemetry(bySensor map[string]json.RawMessage) ([]TelemetryData, error) {
+	var items []TelemetryData
+	for _, sensorKey := range sortedKeys(bySensor) {
+		var sensorItems []TelemetryData
+		if err := json.Unmarshal(bySensor[sensorKey], &sensorItems); err != nil {
+			return nil, fmt.Errorf("ошибка при разборе данных датчика %s: %w", sensorKey, err)
+		}
+		for _, item := range sensorItems {
+			item.Key = sensorKey
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// decodeDeviceTelemetry разбирает записи одного устройства из сгруппированного поля data:
+// массив записей или объект с массивами записей по ключам датчиков
+func decodeDeviceTelemetry(deviceID string, raw json.RawMessage) ([]TelemetryData, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	var items []TelemetryData
+	switch raw[0] {
+	case '[':
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, fmt.Errorf("ошибка при разборе данных устройства %s: %w", deviceID, err)
+		}
+	case '{':
+		var bySensor map[string][]TelemetryData
+		if err := json.Unmarshal(raw, &bySensor); err != nil {
+			return nil, fmt.Errorf("ошибка при разборе данных устройства %s: %w", deviceID, err)
+		}
+		for _, sensorKey := range sortedKeys(bySensor) {
+			for _, item := range bySensor[sensorKey] {
+				if item.Key == "" {
+					item.Key = sensorKey
+				}
+				items = append(items, item)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный формат данных устройства %s в ответе на запрос телеметрии", deviceID)
+	}
+
+	for i := range items {
+		if items[i].EntityID == "" {
+			items[i].EntityID = deviceID
+		}
+	}
+	return items, nil
+}
+
+// sortedKeys возвращает ключи объекта в порядке возрастания
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTelemetryResponseShapes(t *testing.T) {
+	want := []TelemetryData{
+		{EntityID: "st-1", Key: "airtemp", Ts: 1000, DblV: 12.5},
+		{EntityID: "st-1", Key: "winddir", Ts: 1000, StrV: "NW"},
+		{EntityID: "st-2", Key: "airtemp", Ts: 2000, DblV: -3},
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    []TelemetryData
+		wantErr string
+	}{
+		{
+			name: "плоский массив",
+			body: `{"status":"OK","records_count":3,"data":[
+				{"entity_id":"st-1","key":"airtemp","ts":1000,"dbl_v":12.5},
+				{"entity_id":"st-1","key":"winddir","ts":1000,"str_v":"NW"},
+				{"entity_id":"st-2","key":"airtemp","ts":2000,"dbl_v":-3}]}`,
+			want: want,
+		},
+		{
+			name: "массивы по устройствам",
+			body: `{"status":"OK","records_count":3,"data":{
+				"st-2":[{"key":"airtemp","ts":2000,"dbl_v":-3}],
+				"st-1":[{"key":"airtemp","ts":1000,"dbl_v":12.5},{"key":"winddir","ts":1000,"str_v":"NW"}]}}`,
+			want: want,
+		},
+		{
+			name: "датчики по устройствам",
+			body: `{"status":"OK","records_count":3,"data":{
+				"st-1":{"winddir":[{"ts":1000,"str_v":"NW"}],"airtemp":[{"ts":1000,"dbl_v":12.5}]},
+				"st-2":{"airtemp":[{"ts":2000,"dbl_v":-3}]}}}`,
+			want: want,
+		},
+		{
+			name: "нет данных",
+			body: `{"status":"OK","records_count":0,"data":null}`,
+		},
+		{
+			name:    "неизвестный формат",
+			body:    `{"status":"OK","data":"st-1"}`,
+			wantErr: "неизвестный формат поля data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp TelemetryResponse
+			err := json.Unmarshal([]byte(tt.body), &resp)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ошибка %v, ожидалась ошибка с %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ошибка при разборе ответа: %v", err)
+			}
+			if resp.Status != "OK" {
+				t.Errorf("статус %q, ожидался OK", resp.Status)
+			}
+			if !reflect.DeepEqual(resp.Data, tt.want) {
+				t.Errorf("записи %+v, ожидалось %+v", resp.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestTelemetryResponseSensorKeyed(t *testing.T) {
+	// Пример ответа /telemetry из api.md
+	body := `{
+      "data": {
+        "airtemp": [
+          {"ts": 1714521600000, "value": 24.0},
+          {"ts": 1714525200000, "value": 25.0}
+        ],
+        "soiltemp": [
+          {"ts": 1714521600000, "value": 19.0},
+          {"ts": 1714525200000, "value": 19.0}
+        ]
+      }
+    }`
+
+	var resp TelemetryResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("ошибка при разборе ответа: %v", err)
+	}
+
+	want := map[string][]TelemetryPoint{
+		"airtemp":  {{Ts: 1714521600000, Value: 24.0}, {Ts: 1714525200000, Value: 25.0}},
+		"soiltemp": {{Ts: 1714521600000, Value: 19.0}, {Ts: 1714525200000, Value: 19.0}},
+	}
+	if got := telemetryToPoints(resp.Data); !reflect.DeepEqual(got, want) {
+		t.Errorf("точки %+v, ожидалось %+v", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import "weatherInTheField/pkg/config"
+
+// Преобразование timestamp выполняется только на границе с API (API_TS_UNIT):
+// остальной код всегда работает с timestamp в миллисекундах
+
+// secondsUnit сообщает, что API использует timestamp в секундах
+func (w *WeatherAPI) secondsUnit() bool {
+	return w.Config.ApiTsUnit == config.TsUnitSeconds
+}
+
+// toAPIFrom преобразует начало периода в миллисекундах в единицы API (с округлением вниз)
+func (w *WeatherAPI) toAPIFrom(ms int64) int64 {
+	if w.secondsUnit() {
+		return ms / 1000
+	}
+	return ms
+}
+
+// toAPITo преобразует конец периода в миллисекундах в единицы API (с округлением вверх,
+// чтобы не потерять данные последней неполной секунды)
+func (w *WeatherAPI) toAPITo(ms int64) int64 {
+	if w.secondsUnit() {
+		return (ms + 999) / 1000
+	}
+	return ms
+}
+
+// fromAPITs преобразует timestamp из единиц API в миллисекунды
+func (w *WeatherAPI) fromAPITs(ts int64) int64 {
+	if w.secondsUnit() {
+		return ts * 1000
+	}
+	return ts
+}
+
+// telemetryFromAPI преобразует timestamp полученных точек телеметрии в миллисекунды
+func (w *WeatherAPI) telemetryFromAPI(data []TelemetryData) []TelemetryData {
+	if !w.secondsUnit() {
+		return data
+	}
+	for i := range data {
+		data[i].Ts = w.fromAPITs(data[i].Ts)
+	}
+	return data
+}
+
+// devicesFromAPI преобразует timestamp последнего сообщения и показаний датчиков устройств в миллисекунды
+func (w *WeatherAPI) devicesFromAPI(devices []Device) []Device {
+	if !w.secondsUnit() {
+		return devices
+	}
+	for i := range devices {
+		devices[i].LastMsg = w.fromAPITs(devices[i].LastMsg)
+		for key, sensor := range devices[i].Sensors {
+			sensor.Ts = w.fromAPITs(sensor.Ts)
+			devices[i].Sensors[key] = sensor
+		}
+	}
+	return devices
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"weatherInTheField/pkg/config"
+)
+
+func TestTelemetryTimestampUnits(t *testing.T) {
+	// Период запроса в миллисекундах с неполными секундами на границах
+	const from, to = 1700000000500, 1700000060500
+	want := time.Date(2023, 11, 14, 22, 13, 50, 0, time.UTC)
+
+	tests := []struct {
+		unit             string
+		wantFrom, wantTo int64
+		apiTs            int64
+	}{
+		{unit: config.TsUnitMillis, wantFrom: from, wantTo: to, apiTs: want.UnixMilli()},
+		{unit: config.TsUnitSeconds, wantFrom: 1700000000, wantTo: 1700000061, apiTs: want.Unix()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.unit, func(t *testing.T) {
+			var req TelemetryRequest
+			srv := newMockAPI(t, map[string]http.HandlerFunc{
+				"/telemetry": func(rw http.ResponseWriter, r *http.Request) {
+					if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+						t.Errorf("ошибка при разборе запроса: %v", err)
+					}
+					writeJSON(t, rw, TelemetryResponse{Status: "OK", Data: []TelemetryData{{Key: "airtemp", Ts: tt.apiTs, DblV: 1.5}}})
+				},
+			})
+
+			cfg := testConfig(srv.URL)
+			cfg.ApiTsUnit = tt.unit
+			points, err := NewWeatherAPI(cfg).GetTelemetry("st-1", []string{"airtemp"}, from, to)
+			if err != nil {
+				t.Fatalf("ошибка при получении телеметрии: %v", err)
+			}
+
+			if req.TsFrom != tt.wantFrom || req.TsTo != tt.wantTo {
+				t.Errorf("запрошен период %d-%d, ожидался %d-%d", req.TsFrom, req.TsTo, tt.wantFrom, tt.wantTo)
+			}
+			if len(points["airtemp"]) != 1 {
+				t.Fatalf("получено точек %d, ожидалась 1", len(points["airtemp"]))
+			}
+			if got := time.UnixMilli(points["airtemp"][0].Ts).UTC(); !got.Equal(want) {
+				t.Errorf("время точки %s, ожидалось %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Version - версия сервиса, передаваемая в заголовке User-Agent. Задается при сборке:
+// go build -ldflags "-X weatherInTheField/pkg/api.Version=1.2.3"
+var Version = "dev"
+
+// instanceIDHeader - заголовок с идентификатором экземпляра сервиса, по которому поставщик API
+// может найти запросы конкретной установки
+const instanceIDHeader = "X-Client-Instance-ID"
+
+// defaultUserAgent возвращает значение заголовка User-Agent по умолчанию
+func defaultUserAgent() string {
+	return "weatherInTheField/" + Version
+}
+
+// newInstanceID генерирует случайный идентификатор экземпляра сервиса
+func newInstanceID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
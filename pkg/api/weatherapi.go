@@ -2,19 +2,95 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"weatherInTheField/pkg/clock"
 	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracer создает спаны трассировки для запросов к API
+var tracer = otel.Tracer("weatherInTheField/pkg/api")
+
+// WeatherClient описывает методы API, необходимые для сбора данных.
+// Позволяет подменять реальный клиент в тестах
+type WeatherClient interface {
+	GetDevices() ([]Device, error)
+	GetDevicesContext(ctx context.Context) ([]Device, error)
+	GetDevicesCached(ctx context.Context, ttl time.Duration) ([]Device, error)
+	GetTelemetry(deviceID string, keys []string, tsFrom int64, tsTo int64) (map[string][]TelemetryPoint, error)
+	GetTelemetryContext(ctx context.Context, deviceID string, keys []string, tsFrom int64, tsTo int64) (map[string][]TelemetryPoint, error)
+	GetLatestTelemetry(deviceIDs []string, keys []string) (map[string][]TelemetryPoint, error)
+	GetLatestTelemetryWithin(deviceIDs []string, keys []string, lookback time.Duration) (map[string][]TelemetryPoint, error)
+}
+
+// Проверка, что WeatherAPI реализует интерфейс WeatherClient
+var _ WeatherClient = (*WeatherAPI)(nil)
+
 // WeatherAPI представляет API клиент для работы с погодавполе.рф
 type WeatherAPI struct {
-	Config    *config.Config
-	Client    *http.Client
-	SessionID string
+	Config       *config.Config
+	Client       *http.Client
+	SessionID    string
+	RefreshToken string
+
+	// Время последнего успешного входа (защищено sessionMu)
+	sessionMu  sync.RWMutex
+	loggedInAt time.Time
+
+	// Последний полученный список устройств (защищен devicesMu)
+	devicesMu        sync.Mutex
+	devicesCache     []Device
+	devicesFetchedAt time.Time
+
+	// Интервалы агрегации, отклоненные API: по ним агрегированные данные больше не запрашиваются
+	// (защищены aggregationMu)
+	aggregationMu        sync.Mutex
+	unsupportedIntervals map[time.Duration]bool
+
+	// Индекс адреса API, последний запрос к которому был успешным
+	activeBase atomic.Int32
+
+	// Хранилище исходных ответов на запросы телеметрии (может отсутствовать)
+	rawStore *RawStore
+
+	// Замеры времени ответа API по эндпоинтам
+	latencies latencyRecorder
+
+	// Заголовки User-Agent и идентификатора экземпляра, передаваемые в каждом запросе
+	userAgent  string
+	instanceID string
+
+	// Максимальный размер распакованного тела ответа в байтах
+	maxResponseBytes int
+
+	// Источник текущего времени для периодов запросов, времени входа и кэша устройств
+	clock clock.Clock
+}
+
+// SessionInfo описывает состояние сессии API
+type SessionInfo struct {
+	Active     bool      `json:"active"`                 // Токен сессии получен
+	LoggedInAt time.Time `json:"logged_in_at,omitempty"` // Время последнего успешного входа
 }
 
 // Структуры для запросов и ответов API
@@ -40,8 +116,10 @@ type LoginResponse struct {
 
 // DevicesRequest представляет собой запрос на получение списка устройств
 type DevicesRequest struct {
-	Sid    string      `json:"sid"`
-	Filter interface{} `json:"filter,omitempty"`
+	Sid      string      `json:"sid"`
+	Filter   interface{} `json:"filter,omitempty"`
+	PageNum  int         `json:"page_num,omitempty"`
+	PageSize int         `json:"page_size,omitempty"`
 }
 
 // Device представляет собой устройство (метеостанцию)
@@ -74,6 +152,20 @@ type Device struct {
 	} `json:"sensors"`
 }
 
+// LastReadings возвращает последние показания датчиков устройства из списка устройств
+// (поля last_value и ts) в виде телеметрии: по одной точке на датчик. Значения преобразуются
+// так же, как значения телеметрии; датчики без времени или значения пропускаются
+func (d Device) LastReadings() map[string][]TelemetryPoint {
+	readings := make(map[string][]TelemetryPoint)
+	for key, sensor := range d.Sensors {
+		if sensor.Ts <= 0 || sensor.LastValue == nil {
+			continue
+		}
+		readings[key] = []TelemetryPoint{{Ts: sensor.Ts, Value: parseStringValue(sensor.LastValue)}}
+	}
+	return readings
+}
+
 // DevicesResponse представляет собой ответ на получение списка устройств
 type DevicesResponse struct {
 	Status       string   `json:"status"`
@@ -92,6 +184,16 @@ type TelemetryRequest struct {
 	TsTo    int64    `json:"ts_to"`
 }
 
+// PlotRequest представляет собой запрос на получение телеметрии устройства, сгруппированной сервером (/plot)
+type PlotRequest struct {
+	Sid      string   `json:"sid"`
+	Device   string   `json:"device"`
+	Keys     []string `json:"keys,omitempty"`
+	TsFrom   int64    `json:"ts_from"`
+	TsTo     int64    `json:"ts_to"`
+	Interval int64    `json:"interval"` // Интервал группировки в миллисекундах
+}
+
 // TelemetryData представляет собой точку данных телеметрии в новом формате
 type TelemetryData struct {
 	EntityID string      `json:"entity_id"`
@@ -99,12 +201,65 @@ type TelemetryData struct {
 	Ts       int64       `json:"ts"`
 	DblV     float64     `json:"dbl_v"`
 	StrV     interface{} `json:"str_v"`
+	Value    interface{} `json:"value"` // Значение в формате api.md (вместо dbl_v и str_v)
 }
 
 // TelemetryPoint представляет собой точку данных телеметрии
 type TelemetryPoint struct {
 	Ts    int64       `json:"ts"`
 	Value interface{} `json:"value"`
+	Unit  string      `json:"unit,omitempty"` // Единица измерения после преобразования (пусто - исходная)
+}
+
+// Float возвращает значение точки в виде float64.
+// Второе значение равно false, если значение не является числом
+func (p TelemetryPoint) Float() (float64, bool) {
+	switch v := p.Value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// FloatPoint - точка телеметрии с числовым значением. OK равно false, если значение не является числом
+// (в этом случае Value равно 0)
+type FloatPoint struct {
+	Ts    int64
+	Value float64
+	OK    bool
+}
+
+// FloatPoint преобразует точку телеметрии в точку с числовым значением. Помимо чисел
+// преобразуются строки с числом (например, "12.5"); NaN и бесконечность считаются числами
+func (p TelemetryPoint) FloatPoint() FloatPoint {
+	if value, ok := p.Float(); ok {
+		return FloatPoint{Ts: p.Ts, Value: value, OK: true}
+	}
+	if value, ok := parseStringValue(p.Value).(float64); ok {
+		return FloatPoint{Ts: p.Ts, Value: value, OK: true}
+	}
+	return FloatPoint{Ts: p.Ts}
+}
+
+// FloatTelemetry преобразует телеметрию в точки с числовыми значениями, сохраняя порядок точек.
+// Нечисловые точки остаются в результате с OK равным false
+func FloatTelemetry(telemetry map[string][]TelemetryPoint) map[string][]FloatPoint {
+	result := make(map[string][]FloatPoint, len(telemetry))
+	for sensorKey, points := range telemetry {
+		floats := make([]FloatPoint, len(points))
+		for i, point := range points {
+			floats[i] = point.FloatPoint()
+		}
+		result[sensorKey] = floats
+	}
+	return result
 }
 
 // TelemetryResponse представляет собой ответ на получение телеметрии
@@ -122,230 +277,727 @@ type ErrorResponse struct {
 }
 
 // NewWeatherAPI создает новый экземпляр API клиента
-func NewWeatherAPI(cfg *config.Config) *WeatherAPI {
-	return &WeatherAPI{
+func NewWeatherAPI(cfg *config.Config, opts ...Option) *WeatherAPI {
+	w := &WeatherAPI{
 		Config: cfg,
 		Client: &http.Client{
-			Timeout: 120 * time.Second,
+			Timeout:   120 * time.Second,
+			Transport: newTransport(cfg),
 		},
+		userAgent:        cfg.ApiUserAgent,
+		instanceID:       cfg.ApiInstanceID,
+		maxResponseBytes: cfg.MaxResponseBytes,
+		clock:            clock.Real,
+	}
+
+	if w.userAgent == "" {
+		w.userAgent = defaultUserAgent()
+	}
+	if w.instanceID == "" {
+		w.instanceID = newInstanceID()
+	}
+	if w.maxResponseBytes <= 0 {
+		w.maxResponseBytes = defaultMaxResponseBytes
+	}
+	log.Printf("Запросы к API: User-Agent %q, идентификатор экземпляра %s", w.userAgent, w.instanceID)
+
+	if cfg.ApiInsecureSkipVerify {
+		logging.Warnf("ВНИМАНИЕ: проверка TLS сертификата API отключена (API_INSECURE_SKIP_VERIFY=true), соединение не защищено от перехвата")
 	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	// Логирование запросов и ответов устанавливается поверх выбранного транспорта
+	if cfg.ApiDebugHTTP {
+		w.Client.Transport = newDebugTransport(w.Client.Transport, cfg.ApiDebugBodyLimit, cfg.ApiDebugRedactFields)
+	}
+
+	return w
 }
 
 // Login выполняет аутентификацию и получает токен сессии
 func (w *WeatherAPI) Login() error {
+	return w.LoginContext(context.Background())
+}
+
+// LoginContext выполняет аутентификацию с учетом контекста запроса.
+// Вход ограничен собственным таймаутом LOGIN_TIMEOUT_SECONDS, независимо от таймаута запросов телеметрии
+func (w *WeatherAPI) LoginContext(ctx context.Context) error {
+	if timeout := time.Duration(w.Config.LoginTimeoutSeconds) * time.Second; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	loginReq := LoginRequest{
 		Login:    w.Config.ApiLogin,
 		Password: w.Config.ApiPassword,
 	}
 
-	jsonData, err := json.Marshal(loginReq)
+	body, httpStatus, err := w.doPost(ctx, "/login", loginReq)
 	if err != nil {
-		return fmt.Errorf("ошибка при сериализации запроса: %w", err)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("вход не выполнен за %d сек.: %w", w.Config.LoginTimeoutSeconds, err)
+		}
+		return err
 	}
 
-	resp, err := w.Client.Post(
-		w.Config.ApiBaseURL+"/login",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
-	if err != nil {
-		return fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	// Ошибка сервера может не содержать JSON (например, страница балансировщика)
+	if httpStatus >= http.StatusInternalServerError {
+		return fmt.Errorf("ошибка аутентификации: %w: %w", ErrServer, newAPIError(httpStatus, body))
 	}
-	defer resp.Body.Close()
 
 	var loginResp LoginResponse
-	if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
-		return fmt.Errorf("ошибка при десериализации ответа: %w", err)
+	if err := decodeResponse(body, httpStatus, &loginResp); err != nil {
+		return err
 	}
 
 	if loginResp.Status == "error" {
-		return fmt.Errorf("ошибка аутентификации")
+		apiErr := newAPIError(httpStatus, body)
+		if apiErr.Is(ErrRateLimited) {
+			return fmt.Errorf("ошибка аутентификации: %w", apiErr)
+		}
+		return fmt.Errorf("ошибка аутентификации: %w: %w", ErrInvalidCredentials, apiErr)
 	}
 
 	if loginResp.Data.Sid == "" {
-		return fmt.Errorf("отсутствует токен сессии в ответе")
+		return ErrNoSession
 	}
 
+	w.sessionMu.Lock()
 	w.SessionID = loginResp.Data.Sid
+	w.RefreshToken = loginResp.Data.Refresh
+	w.loggedInAt = w.clock.Now()
+	w.sessionMu.Unlock()
 	return nil
 }
 
-// GetDevices получает список всех устройств (метеостанций)
+// SessionInfo возвращает состояние текущей сессии
+func (w *WeatherAPI) SessionInfo() SessionInfo {
+	w.sessionMu.RLock()
+	defer w.sessionMu.RUnlock()
+
+	return SessionInfo{
+		Active:     w.SessionID != "",
+		LoggedInAt: w.loggedInAt,
+	}
+}
+
+// sessionID возвращает текущий токен сессии
+func (w *WeatherAPI) sessionID() string {
+	w.sessionMu.RLock()
+	defer w.sessionMu.RUnlock()
+	return w.SessionID
+}
+
+// maxDevicePages ограничивает количество запрашиваемых страниц списка устройств
+const maxDevicePages = 1000
+
+// GetDevices получает список всех устройств (метеостанций), объединяя все страницы ответа
 func (w *WeatherAPI) GetDevices() ([]Device, error) {
-	if w.SessionID == "" {
-		if err := w.Login(); err != nil {
-			return nil, err
+	return w.GetDevicesContext(context.Background())
+}
+
+// GetDevicesContext получает список всех устройств с учетом контекста запроса
+func (w *WeatherAPI) GetDevicesContext(ctx context.Context) ([]Device, error) {
+	var devices []Device
+	seen := make(map[string]bool)
+	recordsCount := 0
+
+	// Первая страница запрашивается без номера страницы, последующие - с номером, начиная со второй
+	for pageNum := 1; pageNum <= maxDevicePages; pageNum++ {
+		requestPage := pageNum
+		if pageNum == 1 {
+			requestPage = 0
+		}
+
+		page, err := w.getDevicesPage(ctx, requestPage)
+		if err != nil {
+			// API может не поддерживать page_num (по документации такой запрос возвращает ошибку 500).
+			// Ошибка следующей страницы не отменяет уже полученные устройства
+			if pageNum == 1 || ctx.Err() != nil {
+				return nil, err
+			}
+			logging.Warnf("Внимание: ошибка при получении страницы %d списка устройств, используются уже полученные устройства (%d): %v",
+				pageNum, len(devices), err)
+			break
+		}
+
+		// Пустая страница означает конец списка
+		if len(page.Data) == 0 {
+			break
+		}
+
+		// Если сервер не поддерживает пагинацию и вернул уже полученные устройства, прекращаем запросы
+		added := 0
+		for _, device := range page.Data {
+			if seen[device.ID] {
+				continue
+			}
+			seen[device.ID] = true
+			devices = append(devices, device)
+			added++
+		}
+		if added == 0 {
+			break
+		}
+
+		// Получены все устройства
+		recordsCount = page.RecordsCount
+		if page.RecordsCount <= len(devices) {
+			break
 		}
 	}
 
-	devicesReq := DevicesRequest{
-		Sid: w.SessionID,
+	// Количество полученных устройств не совпадает с records_count - часть устройств могла быть потеряна
+	if recordsCount > 0 && recordsCount != len(devices) {
+		logging.Warnf("Внимание: получено устройств %d из %d (records_count), список может быть неполным",
+			len(devices), recordsCount)
 	}
 
-	jsonData, err := json.Marshal(devicesReq)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка при сериализации запроса: %w", err)
+	return w.devicesFromAPI(devices), nil
+}
+
+// GetDevicesCached возвращает список устройств, полученный не более ttl назад, или запрашивает его заново.
+// Позволяет нескольким подсистемам получать список станций без повторных запросов к API.
+// При ttl <= 0 список всегда запрашивается заново
+func (w *WeatherAPI) GetDevicesCached(ctx context.Context, ttl time.Duration) ([]Device, error) {
+	w.devicesMu.Lock()
+	defer w.devicesMu.Unlock()
+
+	if ttl > 0 && w.devicesCache != nil && w.clock.Now().Sub(w.devicesFetchedAt) < ttl {
+		return append([]Device(nil), w.devicesCache...), nil
 	}
 
-	resp, err := w.Client.Post(
-		w.Config.ApiBaseURL+"/devices",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	devices, err := w.GetDevicesContext(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var devicesResp DevicesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&devicesResp); err != nil {
-		return nil, fmt.Errorf("ошибка при десериализации ответа: %w", err)
-	}
+	w.devicesCache = devices
+	w.devicesFetchedAt = w.clock.Now()
+	return append([]Device(nil), devices...), nil
+}
 
-	if devicesResp.Status != "OK" {
-		// Предполагаем, что если статус не OK, то сессия может быть недействительной
-		// Пробуем войти снова и повторить запрос
-		if err := w.Login(); err != nil {
-			return nil, err
+// InvalidateDevices сбрасывает сохраненный список устройств, следующий вызов GetDevicesCached запросит его заново
+func (w *WeatherAPI) InvalidateDevices() {
+	w.devicesMu.Lock()
+	defer w.devicesMu.Unlock()
+
+	w.devicesCache = nil
+	w.devicesFetchedAt = time.Time{}
+}
+
+// getDevicesPage получает одну страницу списка устройств.
+// Номер страницы 0 означает запрос без параметра page_num
+func (w *WeatherAPI) getDevicesPage(ctx context.Context, pageNum int) (*DevicesResponse, error) {
+	var devicesResp DevicesResponse
+	err := w.callWithSession(ctx, "/devices", func(sid string) interface{} {
+		return DevicesRequest{
+			Sid:     sid,
+			PageNum: pageNum,
 		}
-		return w.GetDevices()
+	}, &devicesResp)
+	if err != nil {
+		return nil, err
 	}
 
-	return devicesResp.Data, nil
+	return &devicesResp, nil
 }
 
 // GetTelemetry получает телеметрию для устройства за указанный период
 func (w *WeatherAPI) GetTelemetry(deviceID string, keys []string, tsFrom int64, tsTo int64) (map[string][]TelemetryPoint, error) {
-	if w.SessionID == "" {
-		if err := w.Login(); err != nil {
-			return nil, err
+	return w.GetTelemetryContext(context.Background(), deviceID, keys, tsFrom, tsTo)
+}
+
+// GetAllTelemetry получает телеметрию всех датчиков, о которых сообщает устройство, за указанный период.
+// Ключи датчиков в запросе не передаются, результат сгруппирован по ключам, полученным из API.
+// Используется для поиска датчиков, которые не указаны в SENSOR_KEYS
+func (w *WeatherAPI) GetAllTelemetry(deviceID string, tsFrom int64, tsTo int64) (map[string][]TelemetryPoint, error) {
+	return w.GetAllTelemetryContext(context.Background(), deviceID, tsFrom, tsTo)
+}
+
+// GetAllTelemetryContext получает телеметрию всех датчиков устройства за период с учетом контекста запроса
+func (w *WeatherAPI) GetAllTelemetryContext(ctx context.Context, deviceID string, tsFrom int64, tsTo int64) (map[string][]TelemetryPoint, error) {
+	// Пустой список ключей не попадает в запрос (keys,omitempty), и API возвращает все датчики
+	return w.GetTelemetryContext(ctx, deviceID, nil, tsFrom, tsTo)
+}
+
+// GetTelemetryFloat получает телеметрию для устройства за указанный период с числовыми значениями
+func (w *WeatherAPI) GetTelemetryFloat(deviceID string, keys []string, tsFrom int64, tsTo int64) (map[string][]FloatPoint, error) {
+	return w.GetTelemetryFloatContext(context.Background(), deviceID, keys, tsFrom, tsTo)
+}
+
+// GetTelemetryFloatContext получает телеметрию как GetTelemetryContext и преобразует значения в числа
+// (см. FloatTelemetry), чтобы вызывающему коду не нужно было разбирать значения разных типов
+func (w *WeatherAPI) GetTelemetryFloatContext(ctx context.Context, deviceID string, keys []string, tsFrom int64, tsTo int64) (map[string][]FloatPoint, error) {
+	telemetry, err := w.GetTelemetryContext(ctx, deviceID, keys, tsFrom, tsTo)
+	if err != nil {
+		return nil, err
+	}
+	return FloatTelemetry(telemetry), nil
+}
+
+// GetTelemetryContext получает телеметрию для устройства за указанный период с учетом контекста запроса.
+// Отмена контекста прерывает выполняющийся HTTP запрос
+func (w *WeatherAPI) GetTelemetryContext(ctx context.Context, deviceID string, keys []string, tsFrom int64, tsTo int64) (map[string][]TelemetryPoint, error) {
+	ctx, span := tracer.Start(ctx, "api.GetTelemetry", trace.WithAttributes(
+		attribute.String("device.id", deviceID),
+		attribute.Int("sensor.count", len(keys)),
+	))
+	defer span.End()
+
+	telemetryResp, err := w.fetchTelemetry(ctx, deviceID, keys, tsFrom, tsTo)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// Количество записей в ответе не совпадает с records_count - ответ, вероятно, обрезан.
+	// Запрашиваем период повторно и используем ответ, содержащий больше записей
+	if partialResponse(telemetryResp) {
+		logging.Warnf("Внимание: для устройства %s за период %d-%d получено %d записей из %d (records_count), запрашиваем повторно",
+			deviceID, tsFrom, tsTo, len(telemetryResp.Data), telemetryResp.RecordsCount)
+		span.SetAttributes(attribute.Bool("partial_response", true))
+
+		retry, err := w.fetchTelemetry(ctx, deviceID, keys, tsFrom, tsTo)
+		if err != nil {
+			logging.Errorf("Ошибка при повторном запросе телеметрии для устройства %s: %v", deviceID, err)
+		} else {
+			if len(retry.Data) > len(telemetryResp.Data) {
+				telemetryResp = retry
+			}
+			if partialResponse(retry) {
+				logging.Warnf("Внимание: повторный ответ для устройства %s также неполный: %d записей из %d",
+					deviceID, len(retry.Data), retry.RecordsCount)
+			}
 		}
 	}
 
-	telemetryReq := TelemetryRequest{
-		Sid:     w.SessionID,
-		Devices: []string{deviceID},
-		Keys:    keys,
-		TsFrom:  tsFrom,
-		TsTo:    tsTo,
+	span.SetAttributes(attribute.Int("record.count", len(telemetryResp.Data)))
+	return telemetryToPoints(w.telemetryFromAPI(telemetryResp.Data)), nil
+}
+
+// fetchTelemetry выполняет один запрос телеметрии устройства за период
+func (w *WeatherAPI) fetchTelemetry(ctx context.Context, deviceID string, keys []string, tsFrom int64, tsTo int64) (*TelemetryResponse, error) {
+	// При сохранении исходных ответов запоминаем тело ответа до разбора
+	var telemetryResp TelemetryResponse
+	var out interface{} = &telemetryResp
+	var capture *rawCapture
+	if w.rawStore != nil {
+		capture = &rawCapture{out: &telemetryResp}
+		out = capture
 	}
 
-	jsonData, err := json.Marshal(telemetryReq)
+	err := w.callWithSession(ctx, "/telemetry", func(sid string) interface{} {
+		return TelemetryRequest{
+			Sid:     sid,
+			Devices: []string{deviceID},
+			Keys:    keys,
+			TsFrom:  w.toAPIFrom(tsFrom),
+			TsTo:    w.toAPITo(tsTo),
+		}
+	}, out)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при сериализации запроса: %w", err)
+		return nil, err
 	}
 
-	resp, err := w.Client.Post(
-		w.Config.ApiBaseURL+"/telemetry",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	// Ошибка сохранения исходного ответа не мешает обработке данных
+	if capture != nil {
+		if err := w.rawStore.Save(deviceID, tsFrom, tsTo, capture.raw); err != nil {
+			logging.Errorf("Ошибка при сохранении исходного ответа для устройства %s: %v", deviceID, err)
+		}
+	}
+
+	return &telemetryResp, nil
+}
+
+// partialResponse сообщает, что ответ содержит не все записи, указанные в records_count.
+// Нулевой records_count означает, что API не сообщило количество записей
+func partialResponse(resp *TelemetryResponse) bool {
+	return resp.RecordsCount > 0 && resp.RecordsCount != len(resp.Data)
+}
+
+// GetTelemetryAggregated получает телеметрию устройства, сгруппированную сервером по интервалу (например, time.Hour)
+func (w *WeatherAPI) GetTelemetryAggregated(deviceID string, keys []string, tsFrom, tsTo int64, interval time.Duration) (map[string][]TelemetryPoint, error) {
+	return w.GetTelemetryAggregatedContext(context.Background(), deviceID, keys, tsFrom, tsTo, interval)
+}
+
+// GetTelemetryAggregatedContext получает телеметрию устройства, сгруппированную сервером по интервалу (/plot).
+// Нулевой интервал означает запрос исходных данных. Если API отклоняет запрос как некорректный,
+// запрашиваются исходные данные. Если исходные данные по тому же запросу получены, ошибка относилась
+// к параметру группировки: последующие вызовы с этим интервалом сразу запрашивают исходные данные.
+// Если шаг между точками ответа не кратен интервалу, API не выполнило группировку и возвращается ошибка
+func (w *WeatherAPI) GetTelemetryAggregatedContext(ctx context.Context, deviceID string, keys []string, tsFrom, tsTo int64, interval time.Duration) (map[string][]TelemetryPoint, error) {
+	if interval <= 0 || w.intervalUnsupported(interval) {
+		return w.GetTelemetryContext(ctx, deviceID, keys, tsFrom, tsTo)
+	}
+	intervalMs := interval.Milliseconds()
+	if intervalMs <= 0 {
+		return nil, fmt.Errorf("интервал группировки %s меньше 1 мс", interval)
 	}
-	defer resp.Body.Close()
+
+	ctx, span := tracer.Start(ctx, "api.GetTelemetryAggregated", trace.WithAttributes(
+		attribute.String("device.id", deviceID),
+		attribute.Int("sensor.count", len(keys)),
+		attribute.Int64("interval_ms", intervalMs),
+	))
+	defer span.End()
 
 	var telemetryResp TelemetryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&telemetryResp); err != nil {
-		return nil, fmt.Errorf("ошибка при десериализации ответа: %w", err)
+	err := w.callWithSession(ctx, "/plot", func(sid string) interface{} {
+		return PlotRequest{
+			Sid:      sid,
+			Device:   deviceID,
+			Keys:     keys,
+			TsFrom:   w.toAPIFrom(tsFrom),
+			TsTo:     w.toAPITo(tsTo),
+			Interval: intervalMs,
+		}
+	}, &telemetryResp)
+	if errors.Is(err, ErrBadRequest) {
+		logging.Warnf("Внимание: API отклонило запрос телеметрии с группировкой по %s, запрашиваем исходные данные: %v", interval, err)
+		span.SetAttributes(attribute.Bool("aggregation.fallback", true))
+		points, rawErr := w.GetTelemetryContext(ctx, deviceID, keys, tsFrom, tsTo)
+		if rawErr != nil {
+			// Исходные данные по тому же запросу также не получены - ошибка не связана с группировкой
+			return nil, rawErr
+		}
+		logging.Warnf("Внимание: API не поддерживает группировку телеметрии по %s, далее запрашиваются исходные данные", interval)
+		w.markIntervalUnsupported(interval)
+		return points, nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	points := telemetryToPoints(w.telemetryFromAPI(telemetryResp.Data))
+	if err := checkPointSpacing(points, intervalMs); err != nil {
+		err = fmt.Errorf("телеметрия устройства %s: %w", deviceID, err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("record.count", len(telemetryResp.Data)))
+	return points, nil
+}
+
+// checkPointSpacing проверяет, что точки каждого датчика идут с шагом, кратным интервалу группировки.
+// Кратный шаг допускается, так как за интервал без измерений API не возвращает точку
+func checkPointSpacing(points map[string][]TelemetryPoint, intervalMs int64) error {
+	for _, key := range sortedKeys(points) {
+		ts := make([]int64, len(points[key]))
+		for i, point := range points[key] {
+			ts[i] = point.Ts
+		}
+		sort.Slice(ts, func(i, j int) bool { return ts[i] < ts[j] })
+
+		for i := 1; i < len(ts); i++ {
+			step := ts[i] - ts[i-1]
+			if step == 0 || step%intervalMs != 0 {
+				return fmt.Errorf("шаг %d мс между точками датчика %s (ts %d) не кратен интервалу группировки %d мс",
+					step, key, ts[i], intervalMs)
+			}
+		}
+	}
+	return nil
+}
+
+// intervalUnsupported сообщает, что API отклонило группировку по интервалу
+func (w *WeatherAPI) intervalUnsupported(interval time.Duration) bool {
+	w.aggregationMu.Lock()
+	defer w.aggregationMu.Unlock()
+	return w.unsupportedIntervals[interval]
+}
+
+// markIntervalUnsupported запоминает интервал группировки, который API не поддерживает
+func (w *WeatherAPI) markIntervalUnsupported(interval time.Duration) {
+	w.aggregationMu.Lock()
+	defer w.aggregationMu.Unlock()
+	if w.unsupportedIntervals == nil {
+		w.unsupportedIntervals = make(map[time.Duration]bool)
+	}
+	w.unsupportedIntervals[interval] = true
+}
+
+// DefaultLatestLookback - период поиска последних данных по умолчанию
+const DefaultLatestLookback = 24 * time.Hour
+
+// GetLatestTelemetry получает последние данные телеметрии для устройств за последние 24 часа
+func (w *WeatherAPI) GetLatestTelemetry(deviceIDs []string, keys []string) (map[string][]TelemetryPoint, error) {
+	return w.GetLatestTelemetryWithin(deviceIDs, keys, DefaultLatestLookback)
+}
+
+// GetLatestTelemetryWithin получает последние данные телеметрии для устройств.
+// API возвращает последнее значение каждого датчика в пределах периода lookback до текущего момента,
+// поэтому для датчиков, передающих данные редко (например, rainfall_daily), период следует увеличить
+func (w *WeatherAPI) GetLatestTelemetryWithin(deviceIDs []string, keys []string, lookback time.Duration) (map[string][]TelemetryPoint, error) {
+	if lookback <= 0 {
+		lookback = DefaultLatestLookback
 	}
 
-	if telemetryResp.Status != "OK" {
-		// Предполагаем, что если статус не OK, то сессия может быть недействительной.
-		// Пробуем войти снова и повторить запрос
-		if err := w.Login(); err != nil {
-			return nil, err
+	now := w.clock.Now().UnixMilli()
+	from := now - lookback.Milliseconds()
+
+	var telemetryResp TelemetryResponse
+	err := w.callWithSession(context.Background(), "/last_telemetry", func(sid string) interface{} {
+		return TelemetryRequest{
+			Sid:     sid,
+			Devices: deviceIDs,
+			Keys:    keys,
+			TsFrom:  w.toAPIFrom(from),
+			TsTo:    w.toAPITo(now),
 		}
-		return w.GetTelemetry(deviceID, keys, tsFrom, tsTo)
+	}, &telemetryResp)
+	if err != nil {
+		return nil, err
 	}
 
-	// Преобразуем данные из нового формата в карту для совместимости
+	return telemetryToPoints(w.telemetryFromAPI(telemetryResp.Data)), nil
+}
+
+// telemetryToPoints преобразует данные из нового формата в карту точек по ключам датчиков для совместимости
+func telemetryToPoints(data []TelemetryData) map[string][]TelemetryPoint {
 	result := make(map[string][]TelemetryPoint)
-	for _, data := range telemetryResp.Data {
+	for _, item := range data {
 		point := TelemetryPoint{
-			Ts: data.Ts,
+			Ts: item.Ts,
 		}
 
-		// Используем числовое значение, если оно есть
-		if data.DblV != 0 {
-			point.Value = data.DblV
-		} else {
-			point.Value = data.StrV
+		// Используем числовое значение, если оно есть, затем значение value и строковое значение.
+		// Строка, содержащая число (например, "12.4"), преобразуется в число, остальные строки
+		// сохраняются как есть
+		switch {
+		case item.DblV != 0:
+			point.Value = item.DblV
+		case item.Value != nil:
+			point.Value = parseStringValue(item.Value)
+		default:
+			point.Value = parseStringValue(item.StrV)
 		}
 
 		// Добавляем точку в соответствующий массив по ключу
-		result[data.Key] = append(result[data.Key], point)
+		result[item.Key] = append(result[item.Key], point)
 	}
 
-	return result, nil
+	return result
 }
 
-// GetLatestTelemetry получает последние данные телеметрии для устройств
-func (w *WeatherAPI) GetLatestTelemetry(deviceIDs []string, keys []string) (map[string][]TelemetryPoint, error) {
-	if w.SessionID == "" {
-		if err := w.Login(); err != nil {
-			return nil, err
+// parseStringValue преобразует строковое значение в float64, если строка содержит число.
+// Нечисловые строки (например, направление ветра "NW") и значения других типов возвращаются без изменений
+func parseStringValue(v interface{}) interface{} {
+	str, ok := v.(string)
+	if !ok {
+		return v
+	}
+
+	trimmed := strings.TrimSpace(str)
+	if trimmed == "" {
+		return str
+	}
+
+	// Строки "NaN" и "Inf" также преобразуются в число: такие значения сохраняются как NULL
+	value, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil {
+		return str
+	}
+	return value
+}
+
+// callWithSession выполняет запрос, требующий токен сессии. Функция buildReq формирует тело
+// запроса для переданного токена. Если API сообщает о недействительной сессии,
+// выполняется повторный вход и запрос повторяется один раз
+func (w *WeatherAPI) callWithSession(ctx context.Context, endpoint string, buildReq func(sid string) interface{}, out interface{}) error {
+	if w.sessionID() == "" {
+		if err := w.LoginContext(ctx); err != nil {
+			return err
 		}
 	}
 
-	// Для получения последней телеметрии используем текущее время и время 24 часа назад
-	now := time.Now().UnixNano() / int64(time.Millisecond)
-	dayAgo := now - 24*60*60*1000 // 24 часа в миллисекундах
+	err := w.postJSON(ctx, endpoint, buildReq(w.sessionID()), out)
+	if err == nil || !IsSessionExpired(err) {
+		return err
+	}
 
-	telemetryReq := TelemetryRequest{
-		Sid:     w.SessionID,
-		Devices: deviceIDs,
-		Keys:    keys,
-		TsFrom:  dayAgo,
-		TsTo:    now,
+	// Сессия недействительна - пробуем войти снова и повторить запрос
+	if err := w.LoginContext(ctx); err != nil {
+		return err
 	}
+	return w.postJSON(ctx, endpoint, buildReq(w.sessionID()), out)
+}
 
-	jsonData, err := json.Marshal(telemetryReq)
+// statusResponse используется для проверки статуса ответа перед его разбором
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+// postJSON отправляет POST запрос с телом в формате JSON на эндпоинт API и разбирает ответ в out.
+// Если статус ответа отличается от OK, возвращает *APIError
+func (w *WeatherAPI) postJSON(ctx context.Context, endpoint string, in interface{}, out interface{}) error {
+	body, httpStatus, err := w.doPost(ctx, endpoint, in)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при сериализации запроса: %w", err)
+		return err
 	}
 
-	resp, err := w.Client.Post(
-		w.Config.ApiBaseURL+"/last_telemetry",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка при выполнении запроса: %w", err)
+	var status statusResponse
+	if err := decodeResponse(body, httpStatus, &status); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
 
-	var telemetryResp TelemetryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&telemetryResp); err != nil {
-		return nil, fmt.Errorf("ошибка при десериализации ответа: %w", err)
+	if status.Status != "OK" {
+		return newAPIError(httpStatus, body)
 	}
 
-	if telemetryResp.Status != "OK" {
-		// Предполагаем, что если статус не OK, то сессия может быть недействительной
-		// Пробуем войти снова и повторить запрос
-		if err := w.Login(); err != nil {
-			return nil, err
-		}
-		return w.GetLatestTelemetry(deviceIDs, keys)
+	return decodeResponse(body, httpStatus, out)
+}
+
+// baseURLs возвращает адреса API в порядке перебора
+func (w *WeatherAPI) baseURLs() []string {
+	if len(w.Config.ApiBaseURLs) > 0 {
+		return w.Config.ApiBaseURLs
+	}
+	return []string{w.Config.ApiBaseURL}
+}
+
+// doPost отправляет POST запрос с телом в формате JSON и возвращает тело ответа и HTTP статус.
+// Если задано несколько адресов API, при сетевой ошибке или ошибке сервера (HTTP 5xx) запрос
+// повторяется на следующем адресе. Адрес, ответивший успешно, используется для следующих запросов
+func (w *WeatherAPI) doPost(ctx context.Context, endpoint string, in interface{}) ([]byte, int, error) {
+	jsonData, err := json.Marshal(in)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка при сериализации запроса: %w", err)
 	}
 
-	// Преобразуем данные из нового формата в карту для совместимости
-	result := make(map[string][]TelemetryPoint)
-	for _, data := range telemetryResp.Data {
-		point := TelemetryPoint{
-			Ts: data.Ts,
+	bases := w.baseURLs()
+	start := int(w.activeBase.Load()) % len(bases)
+
+	var body []byte
+	var httpStatus int
+	for i := 0; i < len(bases); i++ {
+		index := (start + i) % len(bases)
+		started := time.Now()
+		body, httpStatus, err = w.postTo(ctx, bases[index]+endpoint, jsonData)
+		w.latencies.record(endpoint, time.Since(started))
+
+		failed := errors.Is(err, ErrNetwork) || httpStatus >= http.StatusInternalServerError
+		if !failed || ctx.Err() != nil {
+			if err == nil && index != start {
+				log.Printf("Запросы к API переключены на адрес %s", bases[index])
+				w.activeBase.Store(int32(index))
+			}
+			return body, httpStatus, err
 		}
 
-		// Используем числовое значение, если оно есть
-		if data.DblV != 0 {
-			point.Value = data.DblV
-		} else {
-			point.Value = data.StrV
+		if i < len(bases)-1 {
+			next := bases[(index+1)%len(bases)]
+			if err != nil {
+				log.Printf("Адрес API %s недоступен, пробуем %s: %v", bases[index], next, err)
+			} else {
+				log.Printf("Адрес API %s вернул HTTP %d, пробуем %s", bases[index], httpStatus, next)
+			}
 		}
+	}
 
-		// Добавляем точку в соответствующий массив по ключу
-		result[data.Key] = append(result[data.Key], point)
+	return body, httpStatus, err
+}
+
+// postTo отправляет POST запрос с телом в формате JSON на указанный URL и возвращает тело ответа и HTTP статус
+func (w *WeatherAPI) postTo(ctx context.Context, url string, jsonData []byte) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка при создании запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", w.userAgent)
+	req.Header.Set(instanceIDHeader, w.instanceID)
+
+	// Запрашиваем сжатый ответ явно, чтобы сжатие работало и с транспортом, заданным через WithTransport.
+	// Распаковка выполняется в decompressBody
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: ошибка при выполнении запроса: %w", ErrNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	reader, err := decompressBody(resp)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("%w: ошибка при распаковке ответа (HTTP %d): %w", ErrNetwork, resp.StatusCode, err)
+	}
+	defer reader.Close()
+
+	// Читаем тело целиком с ограничением размера, чтобы при ошибке разбора знать, сколько данных получено.
+	// Ограничение применяется к распакованным данным
+	body, err := io.ReadAll(io.LimitReader(reader, int64(w.maxResponseBytes)+1))
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("%w: ошибка при чтении ответа (HTTP %d, получено %d байт, соединение прервано: %t): %w",
+			ErrNetwork, resp.StatusCode, len(body), errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF), err)
+	}
+	if len(body) > w.maxResponseBytes {
+		return nil, resp.StatusCode, fmt.Errorf("%w: размер ответа (HTTP %d) превышает %d байт (MAX_RESPONSE_BYTES)",
+			ErrResponseTooLarge, resp.StatusCode, w.maxResponseBytes)
+	}
+
+	return body, resp.StatusCode, nil
+}
+
+// defaultMaxResponseBytes - ограничение размера тела ответа API, если MAX_RESPONSE_BYTES не задан
+const defaultMaxResponseBytes = 256 << 20
+
+// decompressBody возвращает тело ответа, распакованное в соответствии с заголовком Content-Encoding.
+// Несжатое тело возвращается без изменений
+func decompressBody(resp *http.Response) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		return zlib.NewReader(resp.Body)
+	default:
+		return io.NopCloser(resp.Body), nil
+	}
+}
+
+// decodeResponse разбирает тело ответа в out. В ошибку добавляется размер полученного тела и признак
+// обрыва данных, чтобы отличать обрезанный ответ от некорректного JSON
+func decodeResponse(body []byte, httpStatus int, out interface{}) error {
+	err := json.Unmarshal(body, out)
+	if err == nil {
+		return nil
 	}
 
-	return result, nil
+	return fmt.Errorf("ошибка при десериализации ответа (HTTP %d, получено %d байт, ответ обрезан: %t): %w",
+		httpStatus, len(body), isTruncated(body, err), err)
+}
+
+// isTruncated определяет, что ошибка разбора вызвана преждевременным концом данных
+func isTruncated(body []byte, err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Offset >= int64(len(body))
+	}
+
+	return false
+}
+
+// newAPIError разбирает тело ответа с ошибкой в *APIError
+func newAPIError(httpStatus int, body []byte) *APIError {
+	var errResp ErrorResponse
+	_ = json.Unmarshal(body, &errResp)
+	return &APIError{
+		HTTPStatus:     httpStatus,
+		Status:         errResp.Status,
+		Message:        errResp.Error,
+		AdditionalCode: errResp.AdditionalCode,
+	}
 }
@@ -0,0 +1,462 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newMockAPI запускает тестовый сервер API, который принимает вход и обрабатывает остальные
+// эндпоинты указанными обработчиками
+func newMockAPI(t *testing.T, handlers map[string]http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, loginOK)
+	})
+	for endpoint, handler := range handlers {
+		mux.HandleFunc(endpoint, handler)
+	}
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// writeJSON записывает v в ответ в формате JSON
+func writeJSON(t *testing.T, rw http.ResponseWriter, v interface{}) {
+	t.Helper()
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		t.Errorf("ошибка при записи ответа: %v", err)
+	}
+}
+
+func TestGetDevicesPagination(t *testing.T) {
+	pages := map[int][]Device{
+		0: {{ID: "st-1"}, {ID: "st-2"}},
+		2: {{ID: "st-3"}},
+	}
+
+	var requested []int
+	srv := newMockAPI(t, map[string]http.HandlerFunc{
+		"/devices": func(rw http.ResponseWriter, r *http.Request) {
+			var req DevicesRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("ошибка при разборе запроса: %v", err)
+			}
+			requested = append(requested, req.PageNum)
+			writeJSON(t, rw, DevicesResponse{Status: "OK", RecordsCount: 3, PageNum: req.PageNum, Data: pages[req.PageNum]})
+		},
+	})
+
+	devices, err := NewWeatherAPI(testConfig(srv.URL)).GetDevices()
+	if err != nil {
+		t.Fatalf("ошибка при получении устройств: %v", err)
+	}
+
+	var ids []string
+	for _, device := range devices {
+		ids = append(ids, device.ID)
+	}
+	if len(ids) != 3 || ids[0] != "st-1" || ids[1] != "st-2" || ids[2] != "st-3" {
+		t.Errorf("получены устройства %v, ожидались [st-1 st-2 st-3]", ids)
+	}
+	if len(requested) != 2 || requested[0] != 0 || requested[1] != 2 {
+		t.Errorf("запрошены страницы %v, ожидались [0 2]", requested)
+	}
+}
+
+func TestGetDevicesLaterPageError(t *testing.T) {
+	var requested []int
+	srv := newMockAPI(t, map[string]http.HandlerFunc{
+		"/devices": func(rw http.ResponseWriter, r *http.Request) {
+			var req DevicesRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("ошибка при разборе запроса: %v", err)
+			}
+			requested = append(requested, req.PageNum)
+			if req.PageNum != 0 {
+				// page_num не реализован в API и возвращает ошибку 500
+				http.Error(rw, `{"status":"error","error":"internal error"}`, http.StatusInternalServerError)
+				return
+			}
+			writeJSON(t, rw, DevicesResponse{Status: "OK", RecordsCount: 3, Data: []Device{{ID: "st-1"}, {ID: "st-2"}}})
+		},
+	})
+
+	devices, err := NewWeatherAPI(testConfig(srv.URL)).GetDevices()
+	if err != nil {
+		t.Fatalf("ошибка второй страницы не должна отменять первую: %v", err)
+	}
+
+	var ids []string
+	for _, device := range devices {
+		ids = append(ids, device.ID)
+	}
+	if len(ids) != 2 || ids[0] != "st-1" || ids[1] != "st-2" {
+		t.Errorf("получены устройства %v, ожидались [st-1 st-2]", ids)
+	}
+	if len(requested) != 2 || requested[0] != 0 || requested[1] != 2 {
+		t.Errorf("запрошены страницы %v, ожидались [0 2]", requested)
+	}
+}
+
+func TestTelemetryToPointsStringValues(t *testing.T) {
+	tests := []struct {
+		name string
+		strV interface{}
+		want interface{}
+	}{
+		{name: "число в строке", strV: "12.4", want: 12.4},
+		{name: "число с пробелами", strV: " 7 ", want: 7.0},
+		{name: "направление ветра", strV: "NW", want: "NW"},
+		{name: "пустая строка", strV: "", want: ""},
+		{name: "нет значения", strV: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := telemetryToPoints([]TelemetryData{{Key: "k", Ts: 1000, StrV: tt.strV}})
+			if len(points["k"]) != 1 {
+				t.Fatalf("получено точек %d, ожидалась 1", len(points["k"]))
+			}
+			if got := points["k"][0].Value; got != tt.want {
+				t.Errorf("значение %#v, ожидалось %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTelemetryToPointsNaNString(t *testing.T) {
+	points := telemetryToPoints([]TelemetryData{{Key: "k", Ts: 1000, StrV: "NaN"}})
+	if len(points["k"]) != 1 {
+		t.Fatalf("получено точек %d, ожидалась 1", len(points["k"]))
+	}
+	value, ok := points["k"][0].Float()
+	if !ok || !math.IsNaN(value) {
+		t.Errorf("значение %#v, ожидалось число NaN", points["k"][0].Value)
+	}
+}
+
+func TestCompressedResponse(t *testing.T) {
+	tests := []struct {
+		encoding string
+		compress func(io.Writer) io.WriteCloser
+	}{
+		{encoding: "gzip", compress: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{encoding: "deflate", compress: func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			var compressed bytes.Buffer
+			zw := tt.compress(&compressed)
+			io.WriteString(zw, loginOK)
+			zw.Close()
+
+			var acceptEncoding string
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				acceptEncoding = r.Header.Get("Accept-Encoding")
+				rw.Header().Set("Content-Encoding", tt.encoding)
+				rw.Write(compressed.Bytes())
+			}))
+			defer srv.Close()
+
+			w := NewWeatherAPI(testConfig(srv.URL))
+			if err := w.Login(); err != nil {
+				t.Fatalf("вход со сжатым ответом: %v", err)
+			}
+			if w.sessionID() != "test-sid" {
+				t.Errorf("токен сессии %q, ожидался test-sid", w.sessionID())
+			}
+			if !strings.Contains(acceptEncoding, tt.encoding) {
+				t.Errorf("Accept-Encoding %q не содержит %s", acceptEncoding, tt.encoding)
+			}
+		})
+	}
+}
+
+// plotResponse формирует ответ /plot в формате api.md: точки датчика airtemp с метками ts
+func plotResponse(ts ...int64) map[string]interface{} {
+	records := make([]map[string]interface{}, 0, len(ts))
+	for _, t := range ts {
+		records = append(records, map[string]interface{}{"ts": t, "value": 1.5})
+	}
+	return map[string]interface{}{"status": "OK", "data": map[string]interface{}{"airtemp": records}}
+}
+
+func TestGetTelemetryAggregatedFallback(t *testing.T) {
+	const hourMs, dayMs = int64(time.Hour / time.Millisecond), int64(24 * time.Hour / time.Millisecond)
+
+	var mu sync.Mutex
+	requested := make(map[string]int)
+	srv := newMockAPI(t, map[string]http.HandlerFunc{
+		"/plot": func(rw http.ResponseWriter, r *http.Request) {
+			var req PlotRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("ошибка при разборе запроса: %v", err)
+			}
+			mu.Lock()
+			requested[fmt.Sprintf("plot/%s/%d", req.Device, req.Interval)]++
+			mu.Unlock()
+
+			// API не поддерживает группировку по часу и не знает устройство st-bad
+			if req.Interval == hourMs || req.Device == "st-bad" {
+				rw.WriteHeader(http.StatusBadRequest)
+				writeJSON(t, rw, ErrorResponse{Status: "error", Error: "bad request"})
+				return
+			}
+			writeJSON(t, rw, plotResponse(0, req.Interval))
+		},
+		"/telemetry": func(rw http.ResponseWriter, r *http.Request) {
+			var req TelemetryRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("ошибка при разборе запроса: %v", err)
+			}
+			mu.Lock()
+			requested["telemetry/"+req.Devices[0]]++
+			mu.Unlock()
+
+			if req.Devices[0] == "st-bad" {
+				rw.WriteHeader(http.StatusBadRequest)
+				writeJSON(t, rw, ErrorResponse{Status: "error", Error: "bad request"})
+				return
+			}
+			writeJSON(t, rw, TelemetryResponse{Status: "OK", Data: []TelemetryData{{Key: "airtemp", Ts: 1000, DblV: 1.5}}})
+		},
+	})
+	w := NewWeatherAPI(testConfig(srv.URL))
+
+	// Ошибка не связана с группировкой: исходные данные также не получены, интервал не запоминается
+	if _, err := w.GetTelemetryAggregated("st-bad", nil, 0, 1000, 24*time.Hour); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("ошибка %v, ожидалась ErrBadRequest", err)
+	}
+
+	// Группировка по часу отклонена: данные запрашиваются без группировки, и /plot по часу больше не запрашивается
+	for i := 0; i < 2; i++ {
+		points, err := w.GetTelemetryAggregated("st-1", nil, 0, 1000, time.Hour)
+		if err != nil {
+			t.Fatalf("ошибка при получении телеметрии: %v", err)
+		}
+		if len(points["airtemp"]) != 1 {
+			t.Fatalf("получено точек %d, ожидалась 1", len(points["airtemp"]))
+		}
+	}
+
+	// Другой интервал по-прежнему запрашивается с группировкой
+	points, err := w.GetTelemetryAggregated("st-1", nil, 0, dayMs, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("ошибка при получении телеметрии по суткам: %v", err)
+	}
+	if len(points["airtemp"]) != 2 {
+		t.Fatalf("получено точек %d, ожидалось 2", len(points["airtemp"]))
+	}
+
+	want := map[string]int{
+		fmt.Sprintf("plot/st-bad/%d", dayMs): 1,
+		"telemetry/st-bad":                   1,
+		fmt.Sprintf("plot/st-1/%d", hourMs):  1,
+		"telemetry/st-1":                     2,
+		fmt.Sprintf("plot/st-1/%d", dayMs):   1,
+	}
+	for key, count := range want {
+		if requested[key] != count {
+			t.Errorf("запросов %s: %d, ожидалось %d (все запросы: %v)", key, requested[key], count, requested)
+		}
+	}
+}
+
+func TestGetTelemetryAggregatedSpacing(t *testing.T) {
+	const minuteMs = int64(time.Minute / time.Millisecond)
+
+	tests := []struct {
+		name    string
+		ts      []int64
+		wantErr bool
+	}{
+		{name: "шаг равен интервалу", ts: []int64{0, 60 * minuteMs, 120 * minuteMs}},
+		{name: "пропущенный интервал", ts: []int64{0, 60 * minuteMs, 180 * minuteMs}},
+		{name: "исходные данные без группировки", ts: []int64{0, 5 * minuteMs, 10 * minuteMs}, wantErr: true},
+		{name: "шаг не кратен интервалу", ts: []int64{0, 90 * minuteMs}, wantErr: true},
+		{name: "повторяющаяся метка", ts: []int64{0, 0}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rawRequests atomic.Int32
+			srv := newMockAPI(t, map[string]http.HandlerFunc{
+				"/plot": func(rw http.ResponseWriter, r *http.Request) {
+					writeJSON(t, rw, plotResponse(tt.ts...))
+				},
+				"/telemetry": func(rw http.ResponseWriter, r *http.Request) {
+					rawRequests.Add(1)
+					writeJSON(t, rw, TelemetryResponse{Status: "OK"})
+				},
+			})
+			w := NewWeatherAPI(testConfig(srv.URL))
+
+			points, err := w.GetTelemetryAggregated("st-1", nil, 0, 200*minuteMs, time.Hour)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "не кратен интервалу группировки") {
+					t.Fatalf("ошибка %v, ожидалась ошибка о шаге между точками", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("неожиданная ошибка: %v", err)
+				}
+				if len(points["airtemp"]) != len(tt.ts) {
+					t.Errorf("получено точек %d, ожидалось %d", len(points["airtemp"]), len(tt.ts))
+				}
+			}
+			if rawRequests.Load() != 0 {
+				t.Errorf("запрошены исходные данные: %d запросов, ожидалось 0", rawRequests.Load())
+			}
+		})
+	}
+}
+
+func TestBaseURLFailover(t *testing.T) {
+	tests := []struct {
+		name    string
+		primary func(t *testing.T) string
+	}{
+		{
+			name: "адрес недоступен",
+			primary: func(t *testing.T) string {
+				srv := httptest.NewServer(http.NotFoundHandler())
+				srv.Close()
+				return srv.URL
+			},
+		},
+		{
+			name: "ошибка сервера",
+			primary: func(t *testing.T) string {
+				srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+					rw.WriteHeader(http.StatusBadGateway)
+				}))
+				t.Cleanup(srv.Close)
+				return srv.URL
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logins int
+			secondary := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				logins++
+				io.WriteString(rw, loginOK)
+			}))
+			defer secondary.Close()
+
+			w := NewWeatherAPI(testConfig(tt.primary(t), secondary.URL))
+			if err := w.Login(); err != nil {
+				t.Fatalf("вход с переключением на второй адрес: %v", err)
+			}
+			if w.sessionID() != "test-sid" {
+				t.Errorf("токен сессии %q, ожидался test-sid", w.sessionID())
+			}
+			if w.activeBase.Load() != 1 {
+				t.Errorf("активный адрес %d, ожидался второй адрес (1)", w.activeBase.Load())
+			}
+
+			// Следующий запрос сразу отправляется на работающий адрес
+			if err := w.Login(); err != nil {
+				t.Fatalf("повторный вход: %v", err)
+			}
+			if logins != 2 {
+				t.Errorf("запросов ко второму адресу %d, ожидалось 2", logins)
+			}
+		})
+	}
+}
+
+func TestLoginTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// Сервер не отвечает на вход, пока клиент не прервет запрос
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	cfg := testConfig(srv.URL)
+	cfg.LoginTimeoutSeconds = 1
+	w := NewWeatherAPI(cfg)
+
+	started := time.Now()
+	err := w.Login()
+	if err == nil || !strings.Contains(err.Error(), "вход не выполнен за 1 сек.") {
+		t.Fatalf("ошибка %v, ожидался таймаут входа", err)
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Errorf("вход прерван через %s, ожидалось около 1 сек.", elapsed)
+	}
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	large := `{"status":"OK","data":[{"id":"` + strings.Repeat("x", 2000) + `"}]}`
+
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	io.WriteString(zw, large)
+	zw.Close()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "без сжатия",
+			handler: func(rw http.ResponseWriter, r *http.Request) {
+				io.WriteString(rw, large)
+			},
+		},
+		{
+			// Сжатый ответ меньше ограничения, но ограничение применяется к распакованным данным
+			name: "gzip",
+			handler: func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("Content-Encoding", "gzip")
+				rw.Write(gzipped.Bytes())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			srv := newMockAPI(t, map[string]http.HandlerFunc{
+				"/devices": func(rw http.ResponseWriter, r *http.Request) {
+					requests++
+					tt.handler(rw, r)
+				},
+			})
+
+			cfg := testConfig(srv.URL)
+			cfg.MaxResponseBytes = 1000
+			_, err := NewWeatherAPI(cfg).GetDevices()
+			if !errors.Is(err, ErrResponseTooLarge) {
+				t.Fatalf("ошибка %v, ожидалась ErrResponseTooLarge", err)
+			}
+			if requests != 1 {
+				t.Errorf("запросов %d, ожидался 1: слишком большой ответ не запрашивается повторно", requests)
+			}
+		})
+	}
+}
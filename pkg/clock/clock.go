@@ -0,0 +1,26 @@
+package clock
+
+import "time"
+
+// Clock возвращает текущее время. Расчеты, зависящие от текущего времени (периоды запросов телеметрии,
+// часы загрузки истории, расписание опроса), получают его через Clock, чтобы время можно было зафиксировать
+type Clock interface {
+	Now() time.Time
+}
+
+// Real - системные часы
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fixed - часы, всегда возвращающие одно и то же время
+type Fixed time.Time
+
+// Now возвращает зафиксированное время
+func (f Fixed) Now() time.Time {
+	return time.Time(f)
+}
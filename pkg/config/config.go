@@ -1,11 +1,45 @@
 package config
 
 import (
+	"crypto/x509"
+	"errors"
 	"fmt"
-	"log"
+	"io/fs"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"weatherInTheField/pkg/logging"
+)
+
+// Поддерживаемые хранилища данных
+const (
+	SinkMSSQL  = "mssql"
+	SinkInflux = "influx"
+)
+
+// Типы столбца числовых значений телеметрии
+const (
+	ValueTypeFloat   = "float"
+	ValueTypeDecimal = "decimal"
+)
+
+// Поведение при сохранении телеметрии, уже записанной ранее
+const (
+	ConflictUpdate = "update" // значение заменяется новым
+	ConflictSkip   = "skip"   // сохраняется первое записанное значение
+)
+
+// Единицы timestamp в запросах и ответах API
+const (
+	TsUnitMillis  = "ms"
+	TsUnitSeconds = "s"
 )
 
 // Config содержит настройки приложения
@@ -14,6 +48,39 @@ type Config struct {
 	ApiLogin    string
 	ApiPassword string
 	ApiBaseURL  string
+	ApiProxy    string
+
+	// Файл с дополнительными сертификатами центров сертификации (PEM) для проверки TLS сервера API
+	ApiCACert string
+	// Не проверять TLS сертификат сервера API (только для отладки)
+	ApiInsecureSkipVerify bool
+
+	// Заголовок User-Agent запросов к API (пусто - weatherInTheField/<версия>)
+	ApiUserAgent string
+	// Идентификатор экземпляра сервиса в запросах к API (пусто - генерируется при запуске)
+	ApiInstanceID string
+	// Максимальный размер распакованного тела ответа API в байтах
+	MaxResponseBytes int
+
+	// Все адреса API из API_BASE_URL в порядке перебора (ApiBaseURL - первый из них)
+	ApiBaseURLs []string
+
+	// Максимальное время ожидания ответа на запрос входа в секундах (0 - общий таймаут HTTP клиента)
+	LoginTimeoutSeconds int
+
+	// Вывод в лог запросов к API и ответов на них (пароль и токены сессии скрываются),
+	// количество выводимых байт тела и имена скрываемых полей JSON
+	ApiDebugHTTP         bool
+	ApiDebugBodyLimit    int
+	ApiDebugRedactFields []string
+
+	// Единица timestamp в API (ms или s). Внутри сервиса timestamp всегда в миллисекундах
+	ApiTsUnit string
+
+	// Каталог для сохранения исходных ответов на запросы телеметрии (не сохраняются, если не указан)
+	// и срок их хранения в днях (0 - бессрочно)
+	RawStorePath          string
+	RawStoreRetentionDays int
 
 	// Данные для базы данных
 	DbServer   string
@@ -21,41 +88,773 @@ type Config struct {
 	DbPassword string
 	DbName     string
 
+	// Порт и именованный экземпляр SQL Server (0 и пусто - по умолчанию)
+	DbPort     int
+	DbInstance string
+	// Шифрование соединения с базой данных: true, false или disable (пусто - по умолчанию драйвера)
+	DbEncrypt string
+	// Не проверять сертификат сервера базы данных
+	DbTrustServerCertificate bool
+
+	// Параметры пула соединений с базой данных
+	DbMaxOpenConns           int
+	DbMaxIdleConns           int
+	DbConnMaxLifetimeSeconds int
+
+	// Максимальное время выполнения одного запроса к базе данных в секундах (0 - без ограничения)
+	DbStatementTimeoutSeconds int
+
+	// Максимальное количество одновременно открытых транзакций записи (0 - без ограничения)
+	DbMaxConcurrentTx int
+
+	// Хранилище данных: mssql (по умолчанию) или influx
+	Sink string
+
+	// Максимальное количество точек телеметрии в буфере на время недоступности хранилища (0 - буфер отключен)
+	SpoolMaxPoints int
+
+	// Дополнительно сохранять телеметрию в таблицу TelemetryWide (один столбец на датчик)
+	TelemetryWide bool
+
+	// Тип столбца Value таблицы Telemetry: float (по умолчанию) или decimal (DECIMAL(18,6))
+	ValueType string
+
+	// Поведение при повторном сохранении записи телеметрии: update (по умолчанию) или skip
+	ConflictPolicy string
+
+	// Данные для InfluxDB (используются при Sink = influx)
+	InfluxURL    string
+	InfluxToken  string
+	InfluxOrg    string
+	InfluxBucket string
+
 	// Интервал сбора данных в минутах
 	CollectionInterval int
+
+	// Интервал обновления данных станций (Stations) в минутах (по умолчанию равен интервалу сбора)
+	StationRefreshMinutes int
+
+	// Индивидуальные интервалы опроса станций в минутах (ID станции -> интервал)
+	StationIntervals map[string]int
+
+	// Часовые пояса станций (ID станции -> имя часового пояса IANA), сохраняются в таблицу Stations.
+	// Для станций без указанного часового пояса сохраняется UTC
+	StationTimeZones map[string]string
+
+	// Время использования ранее полученного списка устройств в секундах (0 - запрашивать каждый цикл)
+	DeviceCacheSeconds int
+
+	// Максимальная длительность периода одного запроса телеметрии в днях
+	MaxPeriodDays int
+
+	// Перекрытие периода запроса телеметрии с уже сохраненными данными в миллисекундах
+	FetchOverlapMs int
+
+	// Количество периодов телеметрии одного устройства, запрашиваемых параллельно
+	PeriodFetchConcurrency int
+
+	// Количество горутин записи телеметрии (0 - телеметрия сохраняется сразу после получения)
+	// и размер очереди записи в периодах телеметрии
+	WriteQueueWorkers int
+	WriteQueueSize    int
+
+	// Порог среднего времени сохранения телеметрии одного периода в миллисекундах, при превышении которого
+	// снижается нагрузка на хранилище (0 - не снижается)
+	StoreLatencyThresholdMs int
+
+	// Время сохранения телеметрии периода в миллисекундах, начиная с которого в лог выводится
+	// время и скорость сохранения (0 - выводится всегда)
+	SlowWriteThresholdMs int
+
+	// Максимальная длительность одного цикла сбора в минутах (0 - без ограничения)
+	CycleTimeoutMinutes int
+
+	// Время без новых данных в минутах, после которого датчик считается переставшим передавать данные
+	// (0 - проверка отключена)
+	SensorStaleMinutes int
+
+	// Границы допустимого времени измерений: точки раньше MinValidDate (ГГГГ-ММ-ДД) или позже текущего
+	// времени более чем на MaxFutureSkewMinutes отбрасываются как заведомо ошибочные
+	MinValidDate         string
+	MinValidTime         time.Time
+	MaxFutureSkewMinutes int
+
+	// Часы загрузки истории (ЧЧ-ЧЧ в часовом поясе DISPLAY_TZ): вне этого интервала история новых
+	// и давно не обновлявшихся датчиков не запрашивается (пусто - загружается в любое время)
+	BackfillHours  string
+	BackfillWindow *HourWindow
+
+	// Максимальное случайное смещение времени запуска сбора в секундах (0 - без смещения)
+	CollectionJitterSeconds int
+
+	// Выполнять сбор данных сразу после запуска сервиса (иначе первый сбор - через интервал сбора)
+	CollectOnStart bool
+
+	// Ключи запрашиваемых датчиков (пусто - набор по умолчанию)
+	SensorKeys []string
+
+	// Ключи датчиков, сбор которых временно отключен на всех станциях
+	DisabledSensors []string
+
+	// Регулярное выражение для отбора станций по пользовательскому имени (Label).
+	// Если не задано, опрашиваются все станции
+	DeviceLabelRegex   string
+	DeviceLabelPattern *regexp.Regexp
+
+	// Преобразования единиц измерения по ключам датчиков (ключ датчика -> имя преобразования)
+	SensorConversions map[string]string
+
+	// Вычислять направление ветра в градусах по обозначению румба, если станция не сообщает градусы
+	WindDirToDegrees bool
+
+	// Количество румбов (8 или 16) для обозначения направления ветра в API чтения данных и выгрузках
+	CompassPoints int
+
+	// Количество знаков после запятой при сохранении числовых значений (-1 - без округления)
+	// и переопределения по ключам датчиков
+	ValueDecimals  int
+	SensorDecimals map[string]int
+
+	// Поле значения-объекта, которое сохраняется как числовое значение датчика (ключ датчика -> поле)
+	SensorObjectFields map[string]string
+
+	// Данные для публикации в MQTT (публикация отключена, если брокер не указан)
+	MqttBroker      string
+	MqttTopicPrefix string
+	MqttClientID    string
+	MqttUsername    string
+	MqttPassword    string
+
+	// Часовой пояс для вывода времени в логах и выгрузках (в базе данных время хранится в UTC)
+	DisplayTZ       string
+	DisplayLocation *time.Location
+
+	// Адрес OTLP/HTTP коллектора трассировок OpenTelemetry (трассировка отключена, если не указан)
+	OtelEndpoint string
+
+	// Пробный режим: данные запрашиваются из API, но не записываются в хранилище
+	DryRun bool
+
+	// Адрес HTTP API чтения данных, например :8080 (API отключено, если не указан)
+	ApiServeAddr string
+
+	// URL для уведомлений о новых метеостанциях (уведомления отключены, если не указан)
+	NewStationWebhook string
+
+	// Оповещения о повторяющихся сбоях сбора данных: Slack webhook и/или электронная почта через SMTP.
+	// Оповещение отправляется после AlertAfterFailures сбоев подряд
+	AlertSlackWebhook  string
+	AlertSmtpAddr      string
+	AlertSmtpUsername  string
+	AlertSmtpPassword  string
+	AlertEmailFrom     string
+	AlertEmailTo       []string
+	AlertAfterFailures int
+
+	// Количество неудачных циклов сбора подряд, после которого сбор приостанавливается (0 - не приостанавливается),
+	// и длительность паузы в минутах
+	CircuitBreakerFailures        int
+	CircuitBreakerCooldownMinutes int
+
+	// Количество циклов подряд с ошибками станции, после которого она опрашивается редко (0 - не переводится),
+	// и интервал опроса такой станции в минутах
+	StationErrorThreshold int
+	StationRetryMinutes   int
+
+	// Адрес отладочного эндпоинта /debug (отключен, если не указан) и токен доступа к нему
+	DebugAddr  string
+	DebugToken string
+
+	// Источник секретов, на которые ссылаются значения вида secret://... (env или aws)
+	SecretProvider string
+
+	// Уровень журнала: debug, info (по умолчанию), warn или error
+	LogLevel string
 }
 
 // LoadConfig загружает конфигурацию из .env файла и переменных окружения
-func LoadConfig() *Config {
-	// Попытка загрузить .env файл, если он существует
-	_ = godotenv.Load()
+func LoadConfig() (*Config, error) {
+	// Загрузка .env файла, если он существует. При повторной загрузке (SIGHUP) изменения файла применяются
+	if err := loadEnvFile(envFile); err != nil {
+		return nil, err
+	}
 
 	cfg := &Config{
 		// API данные
-		ApiLogin:    getEnv("API_LOGIN", ""),
-		ApiPassword: getEnv("API_PASSWORD", ""),
-		ApiBaseURL:  getEnv("API_BASE_URL", "https://api3.ttrackagro.ru"),
+		ApiBaseURL: getEnv("API_BASE_URL", "https://api3.ttrackagro.ru"),
+		ApiProxy:   getEnv("API_PROXY", ""),
+
+		// Настройки TLS для API (по умолчанию системные сертификаты)
+		ApiCACert:             getEnv("API_CA_CERT", ""),
+		ApiInsecureSkipVerify: getEnvAsBool("API_INSECURE_SKIP_VERIFY", false),
+
+		// Идентификация клиента в запросах к API
+		ApiUserAgent:  getEnv("API_USER_AGENT", ""),
+		ApiInstanceID: getEnv("API_INSTANCE_ID", ""),
+
+		// Ограничение размера ответа API (по умолчанию 256 МБ)
+		MaxResponseBytes: getEnvAsInt("MAX_RESPONSE_BYTES", 256<<20),
+
+		// Таймаут входа в API (по умолчанию 15 секунд)
+		LoginTimeoutSeconds: getEnvAsInt("LOGIN_TIMEOUT_SECONDS", 15),
+
+		// Логирование HTTP запросов к API (по умолчанию отключено)
+		ApiDebugHTTP:         getEnvAsBool("API_DEBUG_HTTP", false),
+		ApiDebugBodyLimit:    getEnvAsInt("API_DEBUG_BODY_LIMIT", 2048),
+		ApiDebugRedactFields: getEnvAsList("API_DEBUG_REDACT_FIELDS"),
+
+		// Единица timestamp в API (по умолчанию миллисекунды)
+		ApiTsUnit: getEnv("API_TS_UNIT", TsUnitMillis),
+
+		// Сохранение исходных ответов API (по умолчанию отключено, файлы хранятся 30 дней)
+		RawStorePath:          getEnv("RAW_STORE_PATH", ""),
+		RawStoreRetentionDays: getEnvAsInt("RAW_STORE_RETENTION_DAYS", 30),
 
 		// Данные базы данных
-		DbServer:   getEnv("DB_SERVER", "ACLSDWHODS001.acl.agroconcern.ru"),
-		DbLogin:    getEnv("DB_LOGIN", ""),
-		DbPassword: getEnv("DB_PASSWORD", ""),
-		DbName:     getEnv("DB_NAME", "WeatherData"),
+		DbServer: getEnv("DB_SERVER", "ACLSDWHODS001.acl.agroconcern.ru"),
+		DbLogin:  getEnv("DB_LOGIN", ""),
+		DbName:   getEnv("DB_NAME", "WeatherData"),
+
+		// Порт, экземпляр и шифрование (по умолчанию параметры драйвера)
+		DbPort:                   getEnvAsInt("DB_PORT", 0),
+		DbInstance:               getEnv("DB_INSTANCE", ""),
+		DbEncrypt:                strings.ToLower(getEnv("DB_ENCRYPT", "")),
+		DbTrustServerCertificate: getEnvAsBool("DB_TRUST_SERVER_CERTIFICATE", false),
+
+		// Пул соединений (по умолчанию 10 открытых, 5 простаивающих, время жизни 5 минут)
+		DbMaxOpenConns:           getEnvAsInt("DB_MAX_OPEN_CONNS", 10),
+		DbMaxIdleConns:           getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
+		DbConnMaxLifetimeSeconds: getEnvAsInt("DB_CONN_MAX_LIFETIME_SECONDS", 300),
+
+		// Время выполнения запроса к базе данных (по умолчанию без ограничения)
+		DbStatementTimeoutSeconds: getEnvAsInt("DB_STATEMENT_TIMEOUT_SECONDS", 0),
+
+		// Одновременные транзакции (по умолчанию без ограничения)
+		DbMaxConcurrentTx: getEnvAsInt("DB_MAX_CONCURRENT_TX", 0),
+
+		// Хранилище данных
+		Sink:         strings.ToLower(getEnv("SINK", SinkMSSQL)),
+		InfluxURL:    getEnv("INFLUX_URL", ""),
+		InfluxToken:  getEnv("INFLUX_TOKEN", ""),
+		InfluxOrg:    getEnv("INFLUX_ORG", ""),
+		InfluxBucket: getEnv("INFLUX_BUCKET", "weather"),
+
+		// Буфер телеметрии на время недоступности хранилища (по умолчанию отключен)
+		SpoolMaxPoints: getEnvAsInt("SPOOL_MAX_POINTS", 0),
+
+		// Широкая таблица телеметрии (по умолчанию отключена)
+		TelemetryWide: getEnvAsBool("TELEMETRY_WIDE", false),
+
+		// Тип столбца числовых значений (по умолчанию FLOAT)
+		ValueType: strings.ToLower(getEnv("VALUE_TYPE", ValueTypeFloat)),
+
+		// Повторно полученные записи по умолчанию обновляются
+		ConflictPolicy: strings.ToLower(getEnv("CONFLICT_POLICY", ConflictUpdate)),
 
 		// Интервал сбора данных (по умолчанию 15 минут)
 		CollectionInterval: getEnvAsInt("COLLECTION_INTERVAL", 15),
+
+		// Интервал обновления данных станций (0 - равен интервалу сбора)
+		StationRefreshMinutes: getEnvAsInt("STATION_REFRESH_MINUTES", 0),
+
+		// Кэширование списка устройств (по умолчанию отключено)
+		DeviceCacheSeconds: getEnvAsInt("DEVICE_CACHE_SECONDS", 0),
+
+		// Максимальный период одного запроса телеметрии (по умолчанию 30 дней)
+		MaxPeriodDays: getEnvAsInt("MAX_PERIOD_DAYS", 30),
+
+		// Перекрытие периода запроса с сохраненными данными (по умолчанию без перекрытия)
+		FetchOverlapMs: getEnvAsInt("FETCH_OVERLAP_MS", 0),
+
+		// Параллельные запросы периодов телеметрии (по умолчанию последовательно)
+		PeriodFetchConcurrency: getEnvAsInt("PERIOD_FETCH_CONCURRENCY", 1),
+
+		// Очередь записи телеметрии (по умолчанию отключена)
+		WriteQueueWorkers: getEnvAsInt("WRITE_QUEUE_WORKERS", 0),
+		WriteQueueSize:    getEnvAsInt("WRITE_QUEUE_SIZE", 16),
+
+		// Снижение нагрузки на медленное хранилище (по умолчанию отключено)
+		StoreLatencyThresholdMs: getEnvAsInt("STORE_LATENCY_THRESHOLD_MS", 0),
+
+		// Вывод времени сохранения только для медленных записей (по умолчанию для всех)
+		SlowWriteThresholdMs: getEnvAsInt("SLOW_WRITE_THRESHOLD_MS", 0),
+
+		// Ограничение длительности цикла сбора (по умолчанию отключено)
+		CycleTimeoutMinutes: getEnvAsInt("CYCLE_TIMEOUT_MINUTES", 0),
+
+		// Проверка датчиков, переставших передавать данные (по умолчанию отключена)
+		SensorStaleMinutes: getEnvAsInt("SENSOR_STALE_MINUTES", 0),
+
+		// Границы допустимого времени измерений (по умолчанию с 2015-01-01 и не более часа в будущем)
+		MinValidDate:         getEnv("MIN_VALID_DATE", "2015-01-01"),
+		MaxFutureSkewMinutes: getEnvAsInt("MAX_FUTURE_SKEW_MINUTES", 60),
+
+		// Часы загрузки истории (по умолчанию в любое время)
+		BackfillHours: getEnv("BACKFILL_HOURS", ""),
+
+		// Разброс времени запуска сбора (по умолчанию отключен)
+		CollectionJitterSeconds: getEnvAsInt("COLLECTION_JITTER_SECONDS", 0),
+
+		// Сбор данных при запуске (по умолчанию включен)
+		CollectOnStart: getEnvAsBool("COLLECT_ON_START", true),
+
+		// Ключи запрашиваемых датчиков (по умолчанию стандартный набор)
+		SensorKeys: getEnvAsList("SENSOR_KEYS"),
+
+		// Отключенные датчики (по умолчанию нет)
+		DisabledSensors: getEnvAsList("DISABLED_SENSORS"),
+
+		// Фильтр станций по имени (по умолчанию все станции)
+		DeviceLabelRegex: getEnv("DEVICE_LABEL_REGEX", ""),
+
+		// Преобразования единиц измерения (по умолчанию не используются)
+		SensorConversions: getEnvAsMap("SENSOR_CONVERSIONS"),
+
+		// Направление ветра в градусах по румбу (по умолчанию отключено)
+		WindDirToDegrees: getEnvAsBool("WINDDIR_TO_DEGREES", false),
+
+		// Обозначение направления ветра румбами (по умолчанию 16 румбов)
+		CompassPoints: getEnvAsInt("COMPASS_POINTS", 16),
+
+		// Округление значений (по умолчанию отключено)
+		ValueDecimals: getEnvAsInt("VALUE_DECIMALS", -1),
+
+		// Публикация в MQTT (по умолчанию отключена)
+		MqttBroker:      getEnv("MQTT_BROKER", ""),
+		MqttTopicPrefix: strings.TrimRight(getEnv("MQTT_TOPIC_PREFIX", "weather"), "/"),
+		MqttClientID:    getEnv("MQTT_CLIENT_ID", "weatherservice"),
+		MqttUsername:    getEnv("MQTT_USERNAME", ""),
+		MqttPassword:    getEnv("MQTT_PASSWORD", ""),
+
+		// Часовой пояс для вывода времени (по умолчанию часовой пояс сервера)
+		DisplayTZ: getEnv("DISPLAY_TZ", ""),
+
+		// Трассировка OpenTelemetry (по умолчанию отключена)
+		OtelEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+
+		// Пробный режим (по умолчанию отключен)
+		DryRun: getEnvAsBool("DRY_RUN", false),
+
+		// API чтения данных (по умолчанию отключено)
+		ApiServeAddr: getEnv("API_SERVE_ADDR", ""),
+
+		// Уведомления о новых станциях (по умолчанию отключены)
+		NewStationWebhook: getEnv("NEW_STATION_WEBHOOK", ""),
+
+		// Оповещения о сбоях (по умолчанию отключены, отправляются после 3 сбоев подряд)
+		AlertSlackWebhook:  getEnv("ALERT_SLACK_WEBHOOK", ""),
+		AlertSmtpAddr:      getEnv("ALERT_SMTP_ADDR", ""),
+		AlertSmtpUsername:  getEnv("ALERT_SMTP_USERNAME", ""),
+		AlertEmailFrom:     getEnv("ALERT_EMAIL_FROM", ""),
+		AlertEmailTo:       getEnvAsList("ALERT_EMAIL_TO"),
+		AlertAfterFailures: getEnvAsInt("ALERT_AFTER_FAILURES", 3),
+
+		// Приостановка сбора при недоступности API (по умолчанию отключена, пауза 30 минут)
+		CircuitBreakerFailures:        getEnvAsInt("CIRCUIT_BREAKER_FAILURES", 0),
+		CircuitBreakerCooldownMinutes: getEnvAsInt("CIRCUIT_BREAKER_COOLDOWN_MINUTES", 30),
+
+		// Редкий опрос станций с повторяющимися ошибками (по умолчанию отключен, раз в час)
+		StationErrorThreshold: getEnvAsInt("STATION_ERROR_THRESHOLD", 0),
+		StationRetryMinutes:   getEnvAsInt("STATION_RETRY_MINUTES", 60),
+
+		// Отладочный эндпоинт (по умолчанию отключен)
+		DebugAddr: getEnv("DEBUG_ADDR", ""),
+
+		// Источник секретов для ссылок secret://... (по умолчанию переменные окружения)
+		SecretProvider: getEnv("SECRET_PROVIDER", SecretProviderEnv),
+
+		// Уровень журнала (по умолчанию info)
+		LogLevel: strings.ToLower(getEnv("LOG_LEVEL", "info")),
+	}
+
+	// Секреты могут передаваться через файлы (переменные с суффиксом _FILE)
+	// или ссылками вида secret://... на источник секретов
+	secrets, err := newSecretProvider(cfg.SecretProvider)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ApiLogin, err = getSecret(secrets, "API_LOGIN"); err != nil {
+		return nil, err
+	}
+	if cfg.ApiPassword, err = getSecret(secrets, "API_PASSWORD"); err != nil {
+		return nil, err
+	}
+	if cfg.DbPassword, err = getSecret(secrets, "DB_PASSWORD"); err != nil {
+		return nil, err
+	}
+	if cfg.DebugToken, err = getSecret(secrets, "DEBUG_TOKEN"); err != nil {
+		return nil, err
+	}
+	if cfg.AlertSmtpPassword, err = getSecret(secrets, "ALERT_SMTP_PASSWORD"); err != nil {
+		return nil, err
 	}
 
 	// Проверка обязательных полей
 	if cfg.ApiLogin == "" || cfg.ApiPassword == "" {
-		log.Fatal("API_LOGIN и API_PASSWORD должны быть указаны")
+		return nil, fmt.Errorf("API_LOGIN и API_PASSWORD должны быть указаны")
+	}
+
+	switch cfg.Sink {
+	case SinkMSSQL:
+		if cfg.DbLogin == "" || cfg.DbPassword == "" {
+			return nil, fmt.Errorf("DB_LOGIN и DB_PASSWORD должны быть указаны")
+		}
+	case SinkInflux:
+		if cfg.InfluxURL == "" || cfg.InfluxToken == "" || cfg.InfluxOrg == "" {
+			return nil, fmt.Errorf("INFLUX_URL, INFLUX_TOKEN и INFLUX_ORG должны быть указаны при SINK=influx")
+		}
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище SINK=%q: допустимые значения %s и %s", cfg.Sink, SinkMSSQL, SinkInflux)
+	}
+
+	if cfg.ConflictPolicy != ConflictUpdate && cfg.ConflictPolicy != ConflictSkip {
+		return nil, fmt.Errorf("неизвестное поведение CONFLICT_POLICY=%q: допустимые значения %s и %s", cfg.ConflictPolicy, ConflictUpdate, ConflictSkip)
+	}
+
+	if cfg.ValueType != ValueTypeFloat && cfg.ValueType != ValueTypeDecimal {
+		return nil, fmt.Errorf("неизвестный тип значений VALUE_TYPE=%q: допустимые значения %s и %s", cfg.ValueType, ValueTypeFloat, ValueTypeDecimal)
+	}
+
+	if cfg.DbStatementTimeoutSeconds < 0 {
+		return nil, fmt.Errorf("DB_STATEMENT_TIMEOUT_SECONDS не может быть отрицательным")
+	}
+
+	if cfg.DbPort < 0 || cfg.DbPort > 65535 {
+		return nil, fmt.Errorf("некорректный порт базы данных DB_PORT=%d", cfg.DbPort)
+	}
+
+	switch cfg.DbEncrypt {
+	case "", "true", "false", "disable":
+	default:
+		return nil, fmt.Errorf("некорректное значение DB_ENCRYPT=%q: допустимые значения true, false и disable", cfg.DbEncrypt)
+	}
+
+	if cfg.DbMaxOpenConns < 0 || cfg.DbMaxIdleConns < 0 || cfg.DbConnMaxLifetimeSeconds < 0 {
+		return nil, fmt.Errorf("DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS и DB_CONN_MAX_LIFETIME_SECONDS не могут быть отрицательными")
+	}
+
+	if cfg.DbMaxConcurrentTx < 0 {
+		return nil, fmt.Errorf("DB_MAX_CONCURRENT_TX не может быть отрицательным")
+	}
+
+	if cfg.CollectionInterval <= 0 {
+		return nil, fmt.Errorf("COLLECTION_INTERVAL должен быть больше нуля")
+	}
+
+	if cfg.StationRefreshMinutes < 0 {
+		return nil, fmt.Errorf("STATION_REFRESH_MINUTES не может быть отрицательным")
+	}
+	if cfg.StationRefreshMinutes == 0 {
+		cfg.StationRefreshMinutes = cfg.CollectionInterval
 	}
 
-	if cfg.DbLogin == "" || cfg.DbPassword == "" {
-		log.Fatal("DB_LOGIN и DB_PASSWORD должны быть указаны")
+	// Индивидуальные интервалы опроса станций
+	cfg.StationIntervals = make(map[string]int)
+	for stationID, value := range getEnvAsMap("STATION_INTERVALS") {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes <= 0 {
+			return nil, fmt.Errorf("некорректный интервал %q для станции %s в STATION_INTERVALS: ожидается положительное число минут", value, stationID)
+		}
+		cfg.StationIntervals[stationID] = minutes
 	}
 
-	return cfg
+	// Часовые пояса станций
+	cfg.StationTimeZones = make(map[string]string)
+	for stationID, name := range getEnvAsMap("STATION_TIMEZONES") {
+		if _, err := time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("некорректный часовой пояс %q для станции %s в STATION_TIMEZONES: %w", name, stationID, err)
+		}
+		cfg.StationTimeZones[stationID] = name
+	}
+
+	if cfg.CompassPoints != 8 && cfg.CompassPoints != 16 {
+		return nil, fmt.Errorf("COMPASS_POINTS должно быть 8 или 16")
+	}
+
+	if cfg.SpoolMaxPoints < 0 {
+		return nil, fmt.Errorf("SPOOL_MAX_POINTS не может быть отрицательным")
+	}
+
+	if cfg.DeviceCacheSeconds < 0 {
+		return nil, fmt.Errorf("DEVICE_CACHE_SECONDS не может быть отрицательным")
+	}
+
+	if cfg.MaxPeriodDays <= 0 {
+		return nil, fmt.Errorf("MAX_PERIOD_DAYS должен быть больше нуля")
+	}
+
+	if cfg.FetchOverlapMs < 0 {
+		return nil, fmt.Errorf("FETCH_OVERLAP_MS не может быть отрицательным")
+	}
+
+	if cfg.PeriodFetchConcurrency <= 0 {
+		return nil, fmt.Errorf("PERIOD_FETCH_CONCURRENCY должен быть больше нуля")
+	}
+
+	if cfg.WriteQueueWorkers < 0 {
+		return nil, fmt.Errorf("WRITE_QUEUE_WORKERS не может быть отрицательным")
+	}
+
+	if cfg.WriteQueueSize <= 0 {
+		return nil, fmt.Errorf("WRITE_QUEUE_SIZE должен быть больше нуля")
+	}
+
+	if cfg.ApiTsUnit != TsUnitMillis && cfg.ApiTsUnit != TsUnitSeconds {
+		return nil, fmt.Errorf("некорректное значение API_TS_UNIT %q, допустимо %q или %q", cfg.ApiTsUnit, TsUnitMillis, TsUnitSeconds)
+	}
+
+	// Скрываемые поля по умолчанию - пароль и токены сессии
+	if len(cfg.ApiDebugRedactFields) == 0 {
+		cfg.ApiDebugRedactFields = []string{"password", "sid", "refresh"}
+	}
+
+	if cfg.MaxResponseBytes <= 0 {
+		return nil, fmt.Errorf("MAX_RESPONSE_BYTES должен быть больше нуля")
+	}
+
+	if cfg.ApiDebugBodyLimit < 0 {
+		return nil, fmt.Errorf("API_DEBUG_BODY_LIMIT не может быть отрицательным")
+	}
+
+	if cfg.LoginTimeoutSeconds < 0 {
+		return nil, fmt.Errorf("LOGIN_TIMEOUT_SECONDS не может быть отрицательным")
+	}
+
+	if cfg.RawStoreRetentionDays < 0 {
+		return nil, fmt.Errorf("RAW_STORE_RETENTION_DAYS не может быть отрицательным")
+	}
+
+	if cfg.StoreLatencyThresholdMs < 0 {
+		return nil, fmt.Errorf("STORE_LATENCY_THRESHOLD_MS не может быть отрицательным")
+	}
+
+	if cfg.SlowWriteThresholdMs < 0 {
+		return nil, fmt.Errorf("SLOW_WRITE_THRESHOLD_MS не может быть отрицательным")
+	}
+
+	if cfg.SensorStaleMinutes < 0 {
+		return nil, fmt.Errorf("SENSOR_STALE_MINUTES не может быть отрицательным")
+	}
+
+	// Нижняя граница допустимого времени измерений (в UTC)
+	if cfg.MinValidTime, err = time.Parse("2006-01-02", cfg.MinValidDate); err != nil {
+		return nil, fmt.Errorf("некорректная дата MIN_VALID_DATE %q: ожидается формат ГГГГ-ММ-ДД", cfg.MinValidDate)
+	}
+
+	if cfg.MaxFutureSkewMinutes < 0 {
+		return nil, fmt.Errorf("MAX_FUTURE_SKEW_MINUTES не может быть отрицательным")
+	}
+
+	if cfg.CycleTimeoutMinutes < 0 {
+		return nil, fmt.Errorf("CYCLE_TIMEOUT_MINUTES не может быть отрицательным")
+	}
+
+	// Проверка настроек округления значений
+	if cfg.ValueDecimals < -1 || cfg.ValueDecimals > maxDecimals {
+		return nil, fmt.Errorf("VALUE_DECIMALS должно быть в диапазоне от 0 до %d или -1 (без округления)", maxDecimals)
+	}
+	cfg.SensorDecimals = make(map[string]int)
+	for sensorKey, value := range getEnvAsMap("SENSOR_DECIMALS") {
+		decimals, err := strconv.Atoi(value)
+		if err != nil || decimals < 0 || decimals > maxDecimals {
+			return nil, fmt.Errorf("некорректное количество знаков %q для датчика %s в SENSOR_DECIMALS: допустимо от 0 до %d", value, sensorKey, maxDecimals)
+		}
+		cfg.SensorDecimals[sensorKey] = decimals
+	}
+
+	cfg.SensorObjectFields = getEnvAsMap("SENSOR_OBJECT_FIELDS")
+
+	// Компиляция фильтра станций по имени
+	if cfg.DeviceLabelRegex != "" {
+		pattern, err := regexp.Compile(cfg.DeviceLabelRegex)
+		if err != nil {
+			return nil, fmt.Errorf("некорректное регулярное выражение DEVICE_LABEL_REGEX %q: %w", cfg.DeviceLabelRegex, err)
+		}
+		cfg.DeviceLabelPattern = pattern
+	}
+
+	// Загрузка часового пояса для вывода времени
+	cfg.DisplayLocation = time.Local
+	if cfg.DisplayTZ != "" {
+		loc, err := time.LoadLocation(cfg.DisplayTZ)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный часовой пояс DISPLAY_TZ %q: %w", cfg.DisplayTZ, err)
+		}
+		cfg.DisplayLocation = loc
+	}
+
+	// Разбор часов загрузки истории
+	if cfg.BackfillHours != "" {
+		window, err := ParseHourWindow(cfg.BackfillHours)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный интервал BACKFILL_HOURS %q: %w", cfg.BackfillHours, err)
+		}
+		cfg.BackfillWindow = window
+	}
+
+	// Проверка и нормализация базовых URL API. Первый адрес - основной, остальные - резервные
+	for _, raw := range strings.Split(cfg.ApiBaseURL, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		baseURL, err := normalizeBaseURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ApiBaseURLs = append(cfg.ApiBaseURLs, baseURL)
+	}
+	if len(cfg.ApiBaseURLs) == 0 {
+		return nil, fmt.Errorf("API_BASE_URL должен содержать хотя бы один адрес")
+	}
+	cfg.ApiBaseURL = cfg.ApiBaseURLs[0]
+
+	if cfg.NewStationWebhook != "" {
+		if u, err := url.Parse(cfg.NewStationWebhook); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("некорректный адрес NEW_STATION_WEBHOOK %q", cfg.NewStationWebhook)
+		}
+	}
+
+	if cfg.AlertSlackWebhook != "" {
+		if u, err := url.Parse(cfg.AlertSlackWebhook); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("некорректный адрес ALERT_SLACK_WEBHOOK %q", cfg.AlertSlackWebhook)
+		}
+	}
+
+	if cfg.AlertSmtpAddr != "" && (cfg.AlertEmailFrom == "" || len(cfg.AlertEmailTo) == 0) {
+		return nil, fmt.Errorf("при указании ALERT_SMTP_ADDR должны быть заданы ALERT_EMAIL_FROM и ALERT_EMAIL_TO")
+	}
+
+	if cfg.AlertAfterFailures <= 0 {
+		return nil, fmt.Errorf("ALERT_AFTER_FAILURES должен быть больше нуля")
+	}
+
+	if cfg.CircuitBreakerFailures < 0 {
+		return nil, fmt.Errorf("CIRCUIT_BREAKER_FAILURES не может быть отрицательным")
+	}
+	if cfg.CircuitBreakerCooldownMinutes <= 0 {
+		return nil, fmt.Errorf("CIRCUIT_BREAKER_COOLDOWN_MINUTES должен быть больше нуля")
+	}
+
+	if cfg.StationErrorThreshold < 0 {
+		return nil, fmt.Errorf("STATION_ERROR_THRESHOLD не может быть отрицательным")
+	}
+	if cfg.StationRetryMinutes <= 0 {
+		return nil, fmt.Errorf("STATION_RETRY_MINUTES должен быть больше нуля")
+	}
+
+	if cfg.DebugAddr != "" && cfg.DebugToken == "" {
+		return nil, fmt.Errorf("при указании DEBUG_ADDR должен быть задан DEBUG_TOKEN")
+	}
+
+	if cfg.ApiProxy != "" {
+		if err := validateProxyURL(cfg.ApiProxy); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.ApiCACert != "" {
+		pem, err := os.ReadFile(cfg.ApiCACert)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении сертификатов API_CA_CERT: %w", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("в файле API_CA_CERT=%s нет сертификатов в формате PEM", cfg.ApiCACert)
+		}
+	}
+
+	if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+		return nil, fmt.Errorf("некорректное значение LOG_LEVEL: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// envFile - файл с переменными окружения, загружаемый при запуске и при перечитывании конфигурации
+const envFile = ".env"
+
+var (
+	// envFileMu защищает envFromFile
+	envFileMu sync.Mutex
+	// envFromFile - переменные окружения, значения которых взяты из .env файла
+	envFromFile = make(map[string]bool)
+)
+
+// loadEnvFile загружает переменные окружения из файла path. Переменные, заданные в окружении процесса
+// или флагами командной строки, имеют приоритет над файлом. При повторной загрузке значения, взятые
+// из файла, обновляются, а переменные, удаленные из файла, сбрасываются. Отсутствие файла не ошибка
+func loadEnvFile(path string) error {
+	values, err := godotenv.Read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		values = nil
+	} else if err != nil {
+		return fmt.Errorf("ошибка при чтении %s: %w", path, err)
+	}
+
+	envFileMu.Lock()
+	defer envFileMu.Unlock()
+
+	for key := range envFromFile {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+			delete(envFromFile, key)
+		}
+	}
+
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); set && !envFromFile[key] {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("ошибка при установке переменной %s из %s: %w", key, path, err)
+		}
+		envFromFile[key] = true
+	}
+
+	return nil
+}
+
+// maxDecimals - максимальное количество знаков после запятой при округлении значений
+const maxDecimals = 15
+
+// normalizeBaseURL проверяет базовый URL API и удаляет завершающий слеш,
+// чтобы при добавлении пути эндпоинта не получался двойной слеш
+func normalizeBaseURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("некорректный API_BASE_URL %q: %w", raw, err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("некорректный API_BASE_URL %q: должны быть указаны схема и хост, например https://api3.ttrackagro.ru", raw)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("некорректный API_BASE_URL %q: поддерживаются только схемы http и https", raw)
+	}
+
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// validateProxyURL проверяет адрес прокси API_PROXY: должны быть указаны схема http, https или socks5 и хост.
+// Без схемы url.Parse принимает, например, "proxy:8080" за схему proxy без хоста
+func validateProxyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("некорректный адрес прокси API_PROXY: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("некорректный адрес прокси API_PROXY %q: поддерживаются только схемы http, https и socks5, например http://proxy:8080", raw)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("некорректный адрес прокси API_PROXY %q: не указан хост", raw)
+	}
+
+	return nil
 }
 
 // getEnv получает значение из переменной окружения или возвращает значение по умолчанию
@@ -67,6 +866,29 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// getSecret получает секрет из переменной окружения key или из файла, путь к которому указан
+// в переменной key_FILE (например, секреты Docker и Kubernetes). Завершающие переводы строк удаляются.
+// Если заданы обе переменные, используется key. Значение вида secret://... запрашивается у источника секретов
+func getSecret(provider SecretProvider, key string) (string, error) {
+	value := os.Getenv(key)
+	path := os.Getenv(key + "_FILE")
+
+	if path == "" {
+		return resolveSecret(provider, key, value)
+	}
+	if value != "" {
+		logging.Warnf("Внимание: заданы обе переменные %s и %s_FILE, используется %s", key, key, key)
+		return resolveSecret(provider, key, value)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при чтении %s_FILE: %w", key, err)
+	}
+
+	return resolveSecret(provider, key, strings.TrimRight(string(data), "\r\n"))
+}
+
 // getEnvAsInt получает значение из переменной окружения как int или возвращает значение по умолчанию
 func getEnvAsInt(key string, defaultValue int) int {
 	value := os.Getenv(key)
@@ -82,3 +904,54 @@ func getEnvAsInt(key string, defaultValue int) int {
 
 	return intValue
 }
+
+// getEnvAsList получает из переменной окружения список значений, разделенных запятыми.
+// Пустые значения пропускаются
+func getEnvAsList(key string) []string {
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getEnvAsMap получает из переменной окружения набор пар вида "ключ:значение,ключ:значение".
+// Пары без двоеточия пропускаются
+func getEnvAsMap(key string) map[string]string {
+	result := make(map[string]string)
+
+	value := os.Getenv(key)
+	if value == "" {
+		return result
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(pair, ":")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if k != "" && v != "" {
+			result[k] = v
+		}
+	}
+
+	return result
+}
+
+// getEnvAsBool получает значение из переменной окружения как bool или возвращает значение по умолчанию
+func getEnvAsBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+
+	return boolValue
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// unsetEnv сбрасывает переменные окружения на время теста и забывает, что они были загружены из .env
+func unsetEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	t.Cleanup(func() {
+		envFileMu.Lock()
+		defer envFileMu.Unlock()
+		for _, key := range keys {
+			delete(envFromFile, key)
+		}
+	})
+}
+
+func TestLoadConfigReloadsEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	setRequiredEnv(t)
+	unsetEnv(t, "COLLECTION_INTERVAL", "DB_NAME", "LOG_LEVEL")
+
+	// DB_NAME задан флагом командной строки (applyEnvFlags) до первой загрузки конфигурации
+	os.Setenv("DB_NAME", "FromFlag")
+
+	writeEnv := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(content), 0o600); err != nil {
+			t.Fatalf("ошибка при записи .env: %v", err)
+		}
+	}
+
+	writeEnv("COLLECTION_INTERVAL=5\nDB_NAME=FromFile\nLOG_LEVEL=warn\n")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("ошибка при загрузке конфигурации: %v", err)
+	}
+	if cfg.CollectionInterval != 5 || cfg.DbName != "FromFlag" || cfg.LogLevel != "warn" {
+		t.Fatalf("CollectionInterval=%d DbName=%q LogLevel=%q, ожидалось 5, FromFlag, warn", cfg.CollectionInterval, cfg.DbName, cfg.LogLevel)
+	}
+
+	// Перечитывание по SIGHUP: измененное значение применяется, удаленное из файла сбрасывается
+	writeEnv("COLLECTION_INTERVAL=10\nDB_NAME=FromFile\n")
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("ошибка при перечитывании конфигурации: %v", err)
+	}
+	if cfg.CollectionInterval != 10 {
+		t.Errorf("CollectionInterval=%d после изменения .env, ожидалось 10", cfg.CollectionInterval)
+	}
+	if cfg.DbName != "FromFlag" {
+		t.Errorf("DbName=%q после изменения .env, значение флага должно сохраниться", cfg.DbName)
+	}
+	if cfg.LogLevel != "info" {
+		t.Errorf("LogLevel=%q после удаления из .env, ожидалось значение по умолчанию info", cfg.LogLevel)
+	}
+}
+
+func TestLoadConfigInvalidLogLevel(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setRequiredEnv(t)
+	t.Setenv("LOG_LEVEL", "verbose")
+
+	if _, err := LoadConfig(); err == nil {
+		t.Fatal("LOG_LEVEL=verbose принят, ожидалась ошибка")
+	}
+}
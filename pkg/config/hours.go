@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HourWindow - ежедневный интервал часов [Start, End). Если Start больше End, интервал
+// переходит через полночь (например, 22-06 - с 22:00 до 06:00)
+type HourWindow struct {
+	Start int
+	End   int
+}
+
+// ParseHourWindow разбирает интервал часов в формате ЧЧ-ЧЧ (например, 22-06)
+func ParseHourWindow(raw string) (*HourWindow, error) {
+	startRaw, endRaw, ok := strings.Cut(raw, "-")
+	if !ok {
+		return nil, fmt.Errorf("ожидается формат ЧЧ-ЧЧ, например 22-06")
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(startRaw))
+	if err != nil || start < 0 || start > 23 {
+		return nil, fmt.Errorf("некорректный час начала %q: ожидается число от 0 до 23", startRaw)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(endRaw))
+	if err != nil || end < 0 || end > 23 {
+		return nil, fmt.Errorf("некорректный час окончания %q: ожидается число от 0 до 23", endRaw)
+	}
+	if start == end {
+		return nil, fmt.Errorf("час начала и окончания совпадают")
+	}
+
+	return &HourWindow{Start: start, End: end}, nil
+}
+
+// Contains сообщает, входит ли час времени t (в часовом поясе t) в интервал
+func (w HourWindow) Contains(t time.Time) bool {
+	hour := t.Hour()
+	if w.Start < w.End {
+		return hour >= w.Start && hour < w.End
+	}
+	return hour >= w.Start || hour < w.End
+}
+
+// String возвращает интервал в формате ЧЧ-ЧЧ
+func (w HourWindow) String() string {
+	return fmt.Sprintf("%02d-%02d", w.Start, w.End)
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// Поддерживаемые источники секретов
+const (
+	SecretProviderEnv = "env"
+	SecretProviderAWS = "aws"
+)
+
+// secretRefPrefix - префикс значения, которое является ссылкой на секрет, а не самим секретом
+const secretRefPrefix = "secret://"
+
+// secretTimeout ограничивает время получения одного секрета
+const secretTimeout = 10 * time.Second
+
+// SecretProvider получает значение секрета по ссылке (часть значения после "secret://")
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// newSecretProvider создает источник секретов по значению SECRET_PROVIDER
+func newSecretProvider(name string) (SecretProvider, error) {
+	switch name {
+	case SecretProviderEnv:
+		return envSecretProvider{}, nil
+	case SecretProviderAWS:
+		return &awsSecretProvider{}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный источник секретов SECRET_PROVIDER %q, допустимо %q или %q",
+			name, SecretProviderEnv, SecretProviderAWS)
+	}
+}
+
+// resolveSecret возвращает значение секрета. Значения вида "secret://ссылка" запрашиваются у источника секретов,
+// остальные возвращаются без изменений
+func resolveSecret(provider SecretProvider, key, value string) (string, error) {
+	if !strings.HasPrefix(value, secretRefPrefix) {
+		return value, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+
+	secret, err := provider.Resolve(ctx, strings.TrimPrefix(value, secretRefPrefix))
+	if err != nil {
+		return "", fmt.Errorf("ошибка при получении секрета %s: %w", key, err)
+	}
+	return secret, nil
+}
+
+// envSecretProvider получает секрет из переменной окружения с указанным именем (secret://ИМЯ_ПЕРЕМЕННОЙ)
+type envSecretProvider struct{}
+
+// Resolve возвращает значение переменной окружения
+func (envSecretProvider) Resolve(_ context.Context, ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("переменная окружения %s не задана", ref)
+	}
+	return value, nil
+}
+
+// awsSecretProvider получает секрет из AWS Secrets Manager по ARN (secret://arn:aws:secretsmanager:...).
+// Если секрет хранится в формате JSON, нужное поле указывается после "#": secret://arn:...#password.
+// Учетные данные AWS берутся из стандартной цепочки (переменные окружения, профиль, роль экземпляра)
+type awsSecretProvider struct{}
+
+// Resolve запрашивает значение секрета у AWS Secrets Manager
+func (p *awsSecretProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	arn, field, _ := strings.Cut(ref, "#")
+
+	// Регион берется из ARN: arn:aws:secretsmanager:<регион>:<аккаунт>:secret:<имя>
+	parts := strings.SplitN(arn, ":", 5)
+	if len(parts) < 5 || parts[0] != "arn" || parts[2] != "secretsmanager" || parts[3] == "" {
+		return "", fmt.Errorf("некорректный ARN секрета %q", arn)
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(parts[3]))
+	if err != nil {
+		return "", fmt.Errorf("ошибка при загрузке настроек AWS: %w", err)
+	}
+
+	out, err := secretsmanager.NewFromConfig(awsCfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: &arn,
+	})
+	if err != nil {
+		return "", fmt.Errorf("ошибка при запросе секрета %s: %w", arn, err)
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("секрет %s не содержит строкового значения", arn)
+	}
+
+	if field == "" {
+		return *out.SecretString, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(*out.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("секрет %s не является JSON объектом: %w", arn, err)
+	}
+	value, ok := fields[field].(string)
+	if !ok {
+		return "", fmt.Errorf("в секрете %s нет строкового поля %q", arn, field)
+	}
+	return value, nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSecretFile записывает секрет во временный файл и возвращает путь к нему
+func writeSecretFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("ошибка при записи файла секрета: %v", err)
+	}
+	return path
+}
+
+func TestGetSecretFromFile(t *testing.T) {
+	t.Setenv("API_PASSWORD", "")
+	t.Setenv("API_PASSWORD_FILE", writeSecretFile(t, "from-file\r\n\n"))
+
+	got, err := getSecret(envSecretProvider{}, "API_PASSWORD")
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if got != "from-file" {
+		t.Errorf("секрет %q, ожидалось from-file без завершающих переводов строк", got)
+	}
+}
+
+func TestGetSecretMissingFile(t *testing.T) {
+	t.Setenv("API_PASSWORD", "")
+	t.Setenv("API_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	_, err := getSecret(envSecretProvider{}, "API_PASSWORD")
+	if err == nil || !strings.Contains(err.Error(), "API_PASSWORD_FILE") {
+		t.Fatalf("ошибка %v, ожидалась ошибка чтения API_PASSWORD_FILE", err)
+	}
+}
+
+func TestGetSecretEnvWinsOverFile(t *testing.T) {
+	t.Setenv("API_PASSWORD", "from-env")
+	t.Setenv("API_PASSWORD_FILE", writeSecretFile(t, "from-file"))
+
+	got, err := getSecret(envSecretProvider{}, "API_PASSWORD")
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if got != "from-env" {
+		t.Errorf("секрет %q, при обеих переменных ожидалось значение API_PASSWORD", got)
+	}
+}
+
+func TestLoadConfigSecretFiles(t *testing.T) {
+	setRequiredEnv(t)
+	for key, value := range map[string]string{"API_LOGIN": "file-login", "API_PASSWORD": "file-password", "DB_PASSWORD": "file-db"} {
+		t.Setenv(key, "")
+		t.Setenv(key+"_FILE", writeSecretFile(t, value+"\n"))
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if cfg.ApiLogin != "file-login" || cfg.ApiPassword != "file-password" || cfg.DbPassword != "file-db" {
+		t.Errorf("секреты из файлов: логин %q, пароль API %q, пароль БД %q", cfg.ApiLogin, cfg.ApiPassword, cfg.DbPassword)
+	}
+}
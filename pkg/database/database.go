@@ -5,32 +5,126 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"math"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"weatherInTheField/pkg/api"
 	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/units"
 
 	_ "github.com/denisenkom/go-mssqldb"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// TelemetrySink описывает хранилище, в которое сервис сохраняет собранные данные.
+// Реализуется базой данных MS SQL Server и альтернативными хранилищами (InfluxDB)
+type TelemetrySink interface {
+	StoreStations(devices []api.Device) error
+	StoreTelemetry(deviceID string, data map[string][]api.TelemetryPoint) error
+	GetLatestTimestamps(stationID string) (map[string]int64, error)
+}
+
+// TelemetryStore описывает методы хранилища, необходимые для сбора данных.
+// Позволяет подменять реальную базу данных в тестах
+type TelemetryStore interface {
+	TelemetrySink
+	GetStations() ([]string, error)
+}
+
+// ContextTelemetrySink описывает хранилище, поддерживающее сохранение телеметрии с контекстом
+// (отмена и трассировка). Проверяется приведением типа, как и другие необязательные возможности
+type ContextTelemetrySink interface {
+	StoreTelemetryContext(ctx context.Context, deviceID string, data map[string][]api.TelemetryPoint) error
+}
+
+// Проверка, что DBManager реализует интерфейсы TelemetryStore и ContextTelemetrySink
+var (
+	_ TelemetryStore       = (*DBManager)(nil)
+	_ ContextTelemetrySink = (*DBManager)(nil)
+)
+
+// tracer создает спаны трассировки для операций с базой данных
+var tracer = otel.Tracer("weatherInTheField/pkg/database")
+
+// windDirDegreesKey - ключ датчика направления ветра в градусах
+const windDirDegreesKey = "winddirang"
+
+// TelemetryRecord представляет собой сохраненную запись телеметрии
+type TelemetryRecord struct {
+	StationID string    `json:"station_id"`
+	SensorKey string    `json:"sensor_key"`
+	Timestamp int64     `json:"timestamp"`
+	DateValue time.Time `json:"date_value"`
+	Value     *float64  `json:"value"`               // Числовое значение (nil для строковых значений)
+	StrValue  string    `json:"str_value,omitempty"` // Строковое значение, если значение не является числом
+	Unit      string    `json:"unit,omitempty"`
+	Compass   string    `json:"compass,omitempty"` // Румб для направления ветра в градусах (winddirang)
+}
+
+// DateValueFromTimestamp преобразует timestamp в миллисекундах в значение столбца DateValue.
+// Время всегда хранится в UTC, независимо от часового пояса сервера
+func DateValueFromTimestamp(ts int64) time.Time {
+	return time.Unix(ts/1000, 0).UTC()
+}
+
 // DBManager представляет собой менеджер для работы с базой данных
 type DBManager struct {
 	Config *config.Config
 	DB     *sql.DB
+
+	// Настройки, задаваемые опциями
+	driverName       string
+	pingTimeout      time.Duration
+	maxOpenConns     int
+	maxConcurrentTx  int
+	statementTimeout time.Duration
+	logger           *log.Logger
+
+	// Ограничение количества одновременно открытых транзакций (nil - без ограничения)
+	txSlots chan struct{}
+
+	// Последние сохраненные данные станций для пропуска записи без изменений
+	stationCache stationCache
+
+	// Столбцы датчиков таблицы TelemetryWide (пусто - широкая таблица не используется)
+	wideColumns []string
 }
 
 // NewDBManager создает новый экземпляр менеджера БД
-func NewDBManager(cfg *config.Config) (*DBManager, error) {
-	connString := fmt.Sprintf("server=%s;user id=%s;password=%s;database=%s",
-		cfg.DbServer, cfg.DbLogin, cfg.DbPassword, cfg.DbName)
+func NewDBManager(cfg *config.Config, opts ...Option) (*DBManager, error) {
+	d := &DBManager{
+		Config:           cfg,
+		driverName:       defaultDriverName,
+		pingTimeout:      defaultPingTimeout,
+		maxOpenConns:     cfg.DbMaxOpenConns,
+		maxConcurrentTx:  cfg.DbMaxConcurrentTx,
+		statementTimeout: time.Duration(cfg.DbStatementTimeoutSeconds) * time.Second,
+		logger:           log.Default(),
+	}
 
-	db, err := sql.Open("sqlserver", connString)
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if d.maxConcurrentTx > 0 {
+		d.txSlots = make(chan struct{}, d.maxConcurrentTx)
+	}
+
+	db, err := sql.Open(d.driverName, connectionString(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("ошибка подключения к базе данных: %w", err)
 	}
 
 	// Проверка соединения
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), d.pingTimeout)
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
@@ -38,14 +132,50 @@ func NewDBManager(cfg *config.Config) (*DBManager, error) {
 	}
 
 	// Установка параметров пула соединений
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Minute * 5)
-
-	return &DBManager{
-		Config: cfg,
-		DB:     db,
-	}, nil
+	db.SetMaxOpenConns(d.maxOpenConns)
+	db.SetMaxIdleConns(cfg.DbMaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.DbConnMaxLifetimeSeconds) * time.Second)
+
+	d.DB = db
+	return d, nil
+}
+
+// connectionString формирует строку подключения к SQL Server в формате URL:
+// sqlserver://логин:пароль@сервер:порт/экземпляр?database=...&encrypt=...
+// Параметры, не заданные в конфигурации, не передаются, и драйвер использует значения по умолчанию.
+// Экземпляр также можно указать в DB_SERVER в виде сервер\экземпляр
+func connectionString(cfg *config.Config) string {
+	host, instance := cfg.DbServer, cfg.DbInstance
+	if i := strings.Index(host, `\`); i >= 0 {
+		if instance == "" {
+			instance = host[i+1:]
+		}
+		host = host[:i]
+	}
+	if cfg.DbPort > 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(cfg.DbPort))
+	}
+
+	query := url.Values{}
+	query.Set("database", cfg.DbName)
+	if cfg.DbEncrypt != "" {
+		query.Set("encrypt", cfg.DbEncrypt)
+	}
+	if cfg.DbTrustServerCertificate {
+		query.Set("TrustServerCertificate", "true")
+	}
+
+	u := &url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(cfg.DbLogin, cfg.DbPassword),
+		Host:     host,
+		RawQuery: query.Encode(),
+	}
+	if instance != "" {
+		u.Path = "/" + instance
+	}
+
+	return u.String()
 }
 
 // Close закрывает соединение с базой данных
@@ -64,6 +194,7 @@ func (d *DBManager) CreateTablesIfNotExists() error {
 		Label NVARCHAR(255),
 		Latitude FLOAT,
 		Longitude FLOAT,
+		TimeZone NVARCHAR(64),
 		LastUpdate DATETIME2
 	)
 	`)
@@ -71,6 +202,15 @@ func (d *DBManager) CreateTablesIfNotExists() error {
 		return fmt.Errorf("ошибка при создании таблицы Stations: %w", err)
 	}
 
+	// Добавляем столбец часового пояса в таблицы, созданные предыдущими версиями
+	_, err = d.DB.Exec(`
+	IF COL_LENGTH('Stations', 'TimeZone') IS NULL
+	ALTER TABLE Stations ADD TimeZone NVARCHAR(64)
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка при добавлении столбца TimeZone: %w", err)
+	}
+
 	// Создаем таблицу для телеметрии
 	_, err = d.DB.Exec(`
 	IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='Telemetry' AND xtype='U')
@@ -80,7 +220,9 @@ func (d *DBManager) CreateTablesIfNotExists() error {
 		SensorKey NVARCHAR(100) NOT NULL,
 		Timestamp BIGINT NOT NULL,
 		DateValue DATETIME2 NOT NULL,
-		Value FLOAT,
+		Value ` + d.valueColumnType() + `,
+		StrValue NVARCHAR(255),
+		Unit NVARCHAR(20),
 		CreatedAt DATETIME2 DEFAULT GETDATE(),
 		CONSTRAINT FK_Telemetry_Stations FOREIGN KEY (StationID) REFERENCES Stations(ID),
 		CONSTRAINT UQ_Telemetry_Station_Sensor_Date UNIQUE (StationID, SensorKey, Timestamp)
@@ -90,6 +232,27 @@ func (d *DBManager) CreateTablesIfNotExists() error {
 		return fmt.Errorf("ошибка при создании таблицы Telemetry: %w", err)
 	}
 
+	// Добавляем столбец единиц измерения в таблицы, созданные предыдущими версиями
+	_, err = d.DB.Exec(`
+	IF COL_LENGTH('Telemetry', 'Unit') IS NULL
+	ALTER TABLE Telemetry ADD Unit NVARCHAR(20)
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка при добавлении столбца Unit: %w", err)
+	}
+
+	// Добавляем столбец строковых значений в таблицы, созданные предыдущими версиями
+	_, err = d.DB.Exec(`
+	IF COL_LENGTH('Telemetry', 'StrValue') IS NULL
+	ALTER TABLE Telemetry ADD StrValue NVARCHAR(255)
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка при добавлении столбца StrValue: %w", err)
+	}
+
+	// Тип столбца Value существующей таблицы не изменяется - предупреждаем о расхождении с настройкой
+	d.checkValueColumnType()
+
 	// Создаем индексы для быстрого поиска
 	_, err = d.DB.Exec(`
 	IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_Telemetry_StationID_SensorKey_Timestamp' AND object_id = OBJECT_ID('Telemetry'))
@@ -107,64 +270,31 @@ func (d *DBManager) CreateTablesIfNotExists() error {
 		return fmt.Errorf("ошибка при создании индекса: %w", err)
 	}
 
-	return nil
-}
+	// Создаем таблицу журнала запусков сбора данных
+	if err := d.createRunsTable(); err != nil {
+		return err
+	}
 
-// StoreStations сохраняет информацию о метеостанциях в базу данных
-func (d *DBManager) StoreStations(devices []api.Device) error {
-	// Начинаем транзакцию
-	tx, err := d.DB.Begin()
-	if err != nil {
-		return fmt.Errorf("ошибка при начале транзакции: %w", err)
+	// Создаем таблицу синхронизированных интервалов датчиков
+	if err := d.createSyncStateTable(); err != nil {
+		return err
 	}
 
-	defer func() {
-		if p := recover(); p != nil {
-			tx.Rollback()
-			panic(p) // паника после отката
-		}
-	}()
+	// Создаем таблицу завершенных периодов запросов
+	if err := d.createSyncWindowsTable(); err != nil {
+		return err
+	}
 
-	// Подготавливаем запрос на вставку
-	stmt, err := tx.Prepare(`
-	MERGE INTO Stations AS target
-	USING (VALUES (@ID, @Name, @Label, @Latitude, @Longitude)) AS source (ID, Name, Label, Latitude, Longitude)
-	ON target.ID = source.ID
-	WHEN MATCHED THEN
-		UPDATE SET 
-			Name = source.Name,
-			Label = source.Label,
-			Latitude = source.Latitude,
-			Longitude = source.Longitude,
-			LastUpdate = GETDATE()
-	WHEN NOT MATCHED THEN
-		INSERT (ID, Name, Label, Latitude, Longitude, LastUpdate)
-		VALUES (source.ID, source.Name, source.Label, source.Latitude, source.Longitude, GETDATE());
-	`)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("ошибка при подготовке запроса: %w", err)
-	}
-	defer stmt.Close()
-
-	// Вставляем каждую метеостанцию
-	for _, device := range devices {
-		_, err := stmt.Exec(
-			sql.Named("ID", device.ID),
-			sql.Named("Name", device.Name),
-			sql.Named("Label", device.Label),
-			sql.Named("Latitude", device.Latitude),
-			sql.Named("Longitude", device.Longitude),
-		)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("ошибка при вставке метеостанции: %w", err)
-		}
+	// Создаем таблицу последних показаний датчиков
+	if err := d.createLatestReadingsTable(); err != nil {
+		return err
 	}
 
-	// Коммитим транзакцию
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("ошибка при коммите транзакции: %w", err)
+	// Создаем широкую таблицу телеметрии, если она включена
+	if len(d.wideColumns) > 0 {
+		if err := d.createWideTable(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -172,6 +302,24 @@ func (d *DBManager) StoreStations(devices []api.Device) error {
 
 // StoreTelemetry сохраняет телеметрию в базу данных
 func (d *DBManager) StoreTelemetry(deviceID string, data map[string][]api.TelemetryPoint) error {
+	return d.StoreTelemetryContext(context.Background(), deviceID, data)
+}
+
+// StoreTelemetryContext сохраняет телеметрию в базу данных с учетом контекста.
+// Отмена контекста прерывает сохранение, уже сохраненные пакеты остаются в базе
+func (d *DBManager) StoreTelemetryContext(ctx context.Context, deviceID string, data map[string][]api.TelemetryPoint) (err error) {
+	ctx, span := tracer.Start(ctx, "database.StoreTelemetry", trace.WithAttributes(
+		attribute.String("device.id", deviceID),
+		attribute.Int("sensor.count", len(data)),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	// Объединим все точки данных в один массив для обработки по пакетам
 	var allPoints []struct {
 		SensorKey      string
@@ -190,13 +338,15 @@ func (d *DBManager) StoreTelemetry(deviceID string, data map[string][]api.Teleme
 		}
 	}
 
+	span.SetAttributes(attribute.Int("record.count", len(allPoints)))
+
 	// Размер пакета (чанка) для обработки
 	const batchSize = 200
 	totalBatches := (len(allPoints) + batchSize - 1) / batchSize
 
 	// Если есть несколько пакетов, выводим информацию
 	if totalBatches > 1 {
-		log.Printf("Разбиваем %d записей на %d пакетов по %d записей", len(allPoints), totalBatches, batchSize)
+		d.logger.Printf("Разбиваем %d записей на %d пакетов по %d записей", len(allPoints), totalBatches, batchSize)
 	}
 
 	// Обрабатываем данные пакетами
@@ -212,13 +362,13 @@ func (d *DBManager) StoreTelemetry(deviceID string, data map[string][]api.Teleme
 
 		// Если много пакетов, выводим информацию о текущем пакете
 		if totalBatches > 1 && batchNum%5 == 0 {
-			log.Printf("Сохранение пакета %d из %d (%.1f%%)",
+			d.logger.Printf("Сохранение пакета %d из %d (%.1f%%)",
 				batchNum,
 				totalBatches,
 				float64(batchNum)/float64(totalBatches)*100)
 		}
 
-		if err := d.storeTelemetryBatch(deviceID, currentBatch); err != nil {
+		if err := d.storeTelemetryBatch(ctx, deviceID, currentBatch); err != nil {
 			return fmt.Errorf("ошибка при сохранении пакета данных телеметрии %d из %d (%d-%d): %w",
 				batchNum, totalBatches, i, end, err)
 		}
@@ -226,14 +376,14 @@ func (d *DBManager) StoreTelemetry(deviceID string, data map[string][]api.Teleme
 
 	// Если было несколько пакетов, выводим информацию о завершении
 	if totalBatches > 1 {
-		log.Printf("Все %d пакетов успешно сохранены в базу данных", totalBatches)
+		d.logger.Printf("Все %d пакетов успешно сохранены в базу данных", totalBatches)
 	}
 
 	return nil
 }
 
 // storeTelemetryBatch сохраняет пакет данных телеметрии в базу данных
-func (d *DBManager) storeTelemetryBatch(deviceID string, batch []struct {
+func (d *DBManager) storeTelemetryBatch(ctx context.Context, deviceID string, batch []struct {
 	SensorKey      string
 	TelemetryPoint api.TelemetryPoint
 }) error {
@@ -242,83 +392,88 @@ func (d *DBManager) storeTelemetryBatch(deviceID string, batch []struct {
 		return nil
 	}
 
-	// Начинаем транзакцию
-	tx, err := d.DB.Begin()
-	if err != nil {
-		return fmt.Errorf("ошибка при начале транзакции: %w", err)
-	}
-
-	defer func() {
-		if p := recover(); p != nil {
-			tx.Rollback()
-			panic(p) // паника после отката
+	return d.withRetryTx(ctx, func(tx *sql.Tx) error {
+		// Подготавливаем запрос на вставку или обновление по уникальному ключу (StationID, SensorKey, Timestamp).
+		// HOLDLOCK удерживает блокировку диапазона ключа до конца транзакции, поэтому параллельные транзакции
+		// не могут одновременно вставить одну и ту же запись и нарушить ограничение уникальности.
+		// При CONFLICT_POLICY=skip существующие записи не изменяются
+		onMatched := `
+		WHEN MATCHED THEN
+			UPDATE SET Value = @Value, StrValue = @StrValue, Unit = @Unit`
+		if d.Config.ConflictPolicy == config.ConflictSkip {
+			onMatched = ""
 		}
-	}()
-
-	// Подготавливаем запрос на вставку
-	stmt, err := tx.Prepare(`
-	IF NOT EXISTS (SELECT 1 FROM Telemetry WHERE StationID = @StationID AND SensorKey = @SensorKey AND Timestamp = @Timestamp)
-	BEGIN
-		INSERT INTO Telemetry (StationID, SensorKey, Timestamp, DateValue, Value, CreatedAt)
-		VALUES (@StationID, @SensorKey, @Timestamp, @DateValue, @Value, GETDATE())
-	END
-	ELSE
-	BEGIN
-		UPDATE Telemetry 
-		SET Value = @Value
-		WHERE StationID = @StationID AND SensorKey = @SensorKey AND Timestamp = @Timestamp
-	END
-	`)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("ошибка при подготовке запроса: %w", err)
-	}
-	defer stmt.Close()
-
-	// Вставляем каждую точку данных из пакета
-	for _, item := range batch {
-		sensorKey := item.SensorKey
-		point := item.TelemetryPoint
-
-		// Конвертируем timestamp в DateTime
-		dateValue := time.Unix(point.Ts/1000, 0)
-
-		// Преобразуем значение в float64
-		var floatValue float64
-		switch v := point.Value.(type) {
-		case float64:
-			floatValue = v
-		case float32:
-			floatValue = float64(v)
-		case int:
-			floatValue = float64(v)
-		case int64:
-			floatValue = float64(v)
-		default:
-			// Пропускаем значения, которые не могут быть преобразованы в float64
-			continue
-		}
-
-		// Выполняем запрос с именованными параметрами
-		_, err := stmt.Exec(
-			sql.Named("StationID", deviceID),
-			sql.Named("SensorKey", sensorKey),
-			sql.Named("Timestamp", point.Ts),
-			sql.Named("DateValue", dateValue),
-			sql.Named("Value", floatValue),
-		)
+		stmt, err := tx.PrepareContext(ctx, `
+		MERGE Telemetry WITH (HOLDLOCK) AS target
+		USING (SELECT @StationID AS StationID, @SensorKey AS SensorKey, @Timestamp AS Timestamp) AS source
+		ON target.StationID = source.StationID AND target.SensorKey = source.SensorKey AND target.Timestamp = source.Timestamp`+onMatched+`
+		WHEN NOT MATCHED THEN
+			INSERT (StationID, SensorKey, Timestamp, DateValue, Value, StrValue, Unit, CreatedAt)
+			VALUES (@StationID, @SensorKey, @Timestamp, @DateValue, @Value, @StrValue, @Unit, GETDATE());
+		`)
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("ошибка при вставке телеметрии: %w", err)
+			return fmt.Errorf("ошибка при подготовке запроса: %w", err)
+		}
+		defer stmt.Close()
+
+		// Количество значений NaN/Inf и значений вне диапазона DECIMAL, сохраненных как NULL
+		nonFinite, outOfRange := 0, 0
+
+		// Вставляем каждую точку данных из пакета
+		for _, item := range batch {
+			sensorKey := item.SensorKey
+			point := item.TelemetryPoint
+
+			// Конвертируем timestamp в DateTime (в UTC)
+			dateValue := DateValueFromTimestamp(point.Ts)
+
+			// Числовые значения сохраняются в столбец Value, непустые строковые - в StrValue.
+			// Остальные значения пропускаются
+			var value interface{} = sql.NullFloat64{}
+			var strValue sql.NullString
+			if floatValue, ok := point.Float(); ok {
+				// NaN и бесконечность не поддерживаются столбцом FLOAT - сохраняем NULL
+				if math.IsNaN(floatValue) || math.IsInf(floatValue, 0) {
+					nonFinite++
+				} else if v, ok := d.valueParam(floatValue); ok {
+					value = v
+				} else {
+					outOfRange++
+				}
+			} else if str, ok := point.Value.(string); ok && str != "" {
+				strValue = sql.NullString{String: str, Valid: true}
+			} else {
+				continue
+			}
+
+			// Выполняем запрос с именованными параметрами
+			err := d.withStatementTimeout(ctx, func(ctx context.Context) error {
+				_, err := stmt.ExecContext(ctx,
+					sql.Named("StationID", deviceID),
+					sql.Named("SensorKey", sensorKey),
+					sql.Named("Timestamp", point.Ts),
+					sql.Named("DateValue", dateValue),
+					sql.Named("Value", value),
+					sql.Named("StrValue", strValue),
+					sql.Named("Unit", sql.NullString{String: point.Unit, Valid: point.Unit != ""}),
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("ошибка при вставке телеметрии: %w", err)
+			}
 		}
-	}
 
-	// Коммитим транзакцию
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("ошибка при коммите транзакции: %w", err)
-	}
+		if nonFinite > 0 {
+			d.logger.Printf("Для устройства %s получено значений NaN/Inf: %d, сохранены как NULL", deviceID, nonFinite)
+		}
+		if outOfRange > 0 {
+			d.logger.Printf("Для устройства %s получено значений вне диапазона %s: %d, сохранены как NULL",
+				deviceID, decimalValueType, outOfRange)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // GetLatestTelemetryTimestamp получает последний timestamp для указанной станции и датчика
@@ -343,6 +498,164 @@ func (d *DBManager) GetLatestTelemetryTimestamp(stationID, sensorKey string) (in
 	return ts, nil
 }
 
+// GetLatestTimestamps получает последний timestamp по каждому датчику указанной станции.
+// Датчики без данных в результат не попадают
+func (d *DBManager) GetLatestTimestamps(stationID string) (map[string]int64, error) {
+	var result map[string]int64
+	ctx := context.Background()
+	err := d.withReconnect(ctx, func() error {
+		return d.withStatementTimeout(ctx, func(ctx context.Context) error {
+			var err error
+			result, err = d.queryLatestTimestamps(ctx, stationID)
+			return err
+		})
+	})
+	return result, err
+}
+
+// queryLatestTimestamps выполняет запрос последних timestamp по всем датчикам станции
+func (d *DBManager) queryLatestTimestamps(ctx context.Context, stationID string) (map[string]int64, error) {
+	rows, err := d.DB.QueryContext(ctx, `
+	SELECT SensorKey, MAX(Timestamp)
+	FROM Telemetry
+	WHERE StationID = @StationID
+	GROUP BY SensorKey
+	`, sql.Named("StationID", stationID))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении последних timestamp: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]int64)
+	for rows.Next() {
+		var sensorKey string
+		var ts int64
+		if err := rows.Scan(&sensorKey, &ts); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании последнего timestamp: %w", err)
+		}
+		result[sensorKey] = ts
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации результатов: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetTelemetryRange получает телеметрию станции за период [from, to), упорядоченную по времени.
+// Если sensorKey пустой, возвращаются данные всех датчиков. Количество записей ограничено limit
+func (d *DBManager) GetTelemetryRange(stationID, sensorKey string, from, to time.Time, limit int) ([]TelemetryRecord, error) {
+	var records []TelemetryRecord
+	err := d.withStatementTimeout(context.Background(), func(ctx context.Context) error {
+		var err error
+		records, err = d.queryTelemetryRange(ctx, stationID, sensorKey, from, to, limit)
+		return err
+	})
+	return records, err
+}
+
+// queryTelemetryRange выполняет запрос телеметрии станции за период
+func (d *DBManager) queryTelemetryRange(ctx context.Context, stationID, sensorKey string, from, to time.Time, limit int) ([]TelemetryRecord, error) {
+	rows, err := d.DB.QueryContext(ctx, `
+	SELECT TOP (@Limit) StationID, SensorKey, Timestamp, DateValue, Value, StrValue, Unit
+	FROM Telemetry
+	WHERE StationID = @StationID
+		AND (@SensorKey = '' OR SensorKey = @SensorKey)
+		AND Timestamp >= @TsFrom AND Timestamp < @TsTo
+	ORDER BY Timestamp, SensorKey
+	`,
+		sql.Named("Limit", limit),
+		sql.Named("StationID", stationID),
+		sql.Named("SensorKey", sensorKey),
+		sql.Named("TsFrom", from.UnixMilli()),
+		sql.Named("TsTo", to.UnixMilli()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при запросе телеметрии: %w", err)
+	}
+	defer rows.Close()
+
+	var records []TelemetryRecord
+	for rows.Next() {
+		var record TelemetryRecord
+		var value sql.NullFloat64
+		var strValue, unit sql.NullString
+		if err := rows.Scan(&record.StationID, &record.SensorKey, &record.Timestamp, &record.DateValue, &value, &strValue, &unit); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании записи телеметрии: %w", err)
+		}
+		if value.Valid {
+			record.Value = &value.Float64
+		}
+		record.StrValue = strValue.String
+		record.Unit = unit.String
+		record.Compass = d.compassLabel(record.SensorKey, value)
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации результатов: %w", err)
+	}
+
+	return records, nil
+}
+
+// compassLabel возвращает обозначение румба для значения направления ветра в градусах
+// (COMPASS_POINTS румбов). Для остальных датчиков и пустых значений возвращает пустую строку
+func (d *DBManager) compassLabel(sensorKey string, value sql.NullFloat64) string {
+	return compassFor(sensorKey, value, d.Config.CompassPoints)
+}
+
+// compassFor возвращает обозначение румба из points румбов для значения направления ветра в градусах
+func compassFor(sensorKey string, value sql.NullFloat64, points int) string {
+	if sensorKey != windDirDegreesKey || !value.Valid {
+		return ""
+	}
+	return units.DegreesToCompass(value.Float64, points)
+}
+
+// TelemetryRecords преобразует телеметрию станции в записи в том виде, в каком они сохраняются
+// в таблицу Telemetry и возвращаются GetTelemetryRange: числа - в Value (NaN и бесконечность - nil),
+// непустые строки - в StrValue, остальные значения пропускаются. Записи упорядочены по времени и датчику
+func TelemetryRecords(stationID string, data map[string][]api.TelemetryPoint, compassPoints int) []TelemetryRecord {
+	var records []TelemetryRecord
+	for sensorKey, points := range data {
+		for _, point := range points {
+			record := TelemetryRecord{
+				StationID: stationID,
+				SensorKey: sensorKey,
+				Timestamp: point.Ts,
+				DateValue: DateValueFromTimestamp(point.Ts),
+				Unit:      point.Unit,
+			}
+
+			var value sql.NullFloat64
+			if floatValue, ok := point.Float(); ok {
+				if !math.IsNaN(floatValue) && !math.IsInf(floatValue, 0) {
+					value = sql.NullFloat64{Float64: floatValue, Valid: true}
+					record.Value = &value.Float64
+				}
+			} else if str, ok := point.Value.(string); ok && str != "" {
+				record.StrValue = str
+			} else {
+				continue
+			}
+			record.Compass = compassFor(sensorKey, value, compassPoints)
+
+			records = append(records, record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Timestamp != records[j].Timestamp {
+			return records[i].Timestamp < records[j].Timestamp
+		}
+		return records[i].SensorKey < records[j].SensorKey
+	})
+
+	return records
+}
+
 // GetStations получает список всех станций из базы данных
 func (d *DBManager) GetStations() ([]string, error) {
 	rows, err := d.DB.Query("SELECT ID FROM Stations")
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/config"
+
+	"github.com/denisenkom/go-mssqldb/msdsn"
+)
+
+// withLocal временно заменяет часовой пояс time.Local
+func withLocal(t *testing.T, loc *time.Location) {
+	t.Helper()
+	previous := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = previous })
+}
+
+func TestDateValueFromTimestampIsUTC(t *testing.T) {
+	want := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+
+	for _, loc := range []*time.Location{time.UTC, time.FixedZone("MSK", 3*60*60), time.FixedZone("PST", -8*60*60)} {
+		t.Run(loc.String(), func(t *testing.T) {
+			withLocal(t, loc)
+
+			got := DateValueFromTimestamp(1700000000123)
+			if got.Location() != time.UTC || !got.Equal(want) {
+				t.Errorf("DateValueFromTimestamp = %s (%s), ожидалось %s", got, got.Location(), want)
+			}
+		})
+	}
+}
+
+// telemetryRows записывает параметры запросов сохранения телеметрии
+type telemetryRows struct {
+	mu   sync.Mutex
+	rows []map[string]interface{}
+}
+
+// exec - обработчик запросов тестовой базы данных, сохраняющий параметры запросов к Telemetry
+func (r *telemetryRows) exec(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !strings.Contains(query, "MERGE Telemetry") {
+		return driver.RowsAffected(0), nil
+	}
+
+	// Значения sql.Null* записываются так, как их получил бы драйвер
+	row := make(map[string]interface{}, len(args))
+	for _, arg := range args {
+		value := arg.Value
+		if valuer, ok := value.(driver.Valuer); ok {
+			var err error
+			if value, err = valuer.Value(); err != nil {
+				return nil, err
+			}
+		}
+		row[arg.Name] = value
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rows = append(r.rows, row)
+	return driver.RowsAffected(1), nil
+}
+
+func TestStoreTelemetryStoresUTCDateValue(t *testing.T) {
+	withLocal(t, time.FixedZone("MSK", 3*60*60))
+
+	var stored telemetryRows
+	d := newTestManager(t, &fakeDB{exec: stored.exec})
+
+	err := d.StoreTelemetry("st-1", map[string][]api.TelemetryPoint{
+		"airtemp": {{Ts: 1700000000000, Value: 12.5}},
+	})
+	if err != nil {
+		t.Fatalf("ошибка при сохранении телеметрии: %v", err)
+	}
+
+	if len(stored.rows) != 1 {
+		t.Fatalf("сохранено строк %d, ожидалась 1", len(stored.rows))
+	}
+	dateValue, ok := stored.rows[0]["DateValue"].(time.Time)
+	if !ok {
+		t.Fatalf("DateValue имеет тип %T, ожидался time.Time", stored.rows[0]["DateValue"])
+	}
+	if dateValue.Location() != time.UTC || dateValue.Hour() != 22 {
+		t.Errorf("DateValue = %s (%s), ожидалось 22:13:20 UTC", dateValue, dateValue.Location())
+	}
+}
+
+func TestStoreTelemetryValueColumns(t *testing.T) {
+	var stored telemetryRows
+	d := newTestManager(t, &fakeDB{exec: stored.exec})
+
+	// Значения в том виде, в котором их возвращает API клиент: "12.4" уже преобразовано в число
+	err := d.StoreTelemetry("st-1", map[string][]api.TelemetryPoint{
+		"airtemp":  {{Ts: 1000, Value: 12.4}},
+		"winddir":  {{Ts: 1000, Value: "NW"}},
+		"rainfall": {{Ts: 1000, Value: ""}},
+	})
+	if err != nil {
+		t.Fatalf("ошибка при сохранении телеметрии: %v", err)
+	}
+
+	bySensor := make(map[string]map[string]interface{})
+	for _, row := range stored.rows {
+		bySensor[row["SensorKey"].(string)] = row
+	}
+
+	if row := bySensor["airtemp"]; row == nil || row["Value"] != 12.4 || row["StrValue"] != nil {
+		t.Errorf("airtemp: %v, ожидалось Value=12.4 и StrValue=NULL", row)
+	}
+	if row := bySensor["winddir"]; row == nil || row["Value"] != nil || row["StrValue"] != "NW" {
+		t.Errorf("winddir: %v, ожидалось Value=NULL и StrValue=NW", row)
+	}
+	if row, ok := bySensor["rainfall"]; ok {
+		t.Errorf("пустое значение rainfall сохранено: %v", row)
+	}
+}
+
+func TestStoreTelemetryNonFiniteValues(t *testing.T) {
+	var stored telemetryRows
+	d := newTestManager(t, &fakeDB{exec: stored.exec})
+
+	err := d.StoreTelemetry("st-1", map[string][]api.TelemetryPoint{
+		"airtemp": {
+			{Ts: 1000, Value: math.NaN()},
+			{Ts: 2000, Value: math.Inf(1)},
+			{Ts: 3000, Value: math.Inf(-1)},
+			{Ts: 4000, Value: 1.5},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ошибка при сохранении телеметрии: %v", err)
+	}
+
+	if len(stored.rows) != 4 {
+		t.Fatalf("сохранено строк %d, ожидалось 4", len(stored.rows))
+	}
+	for _, row := range stored.rows {
+		want := interface{}(nil)
+		if row["Timestamp"] == int64(4000) {
+			want = 1.5
+		}
+		if row["Value"] != want || row["StrValue"] != nil {
+			t.Errorf("Timestamp %v: Value=%v StrValue=%v, ожидалось Value=%v и StrValue=NULL", row["Timestamp"], row["Value"], row["StrValue"], want)
+		}
+	}
+}
+
+func TestConnectionString(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want msdsn.Config
+	}{
+		{
+			name: "сервер и экземпляр",
+			cfg:  config.Config{DbServer: `sql01\SQLEXPRESS`, DbLogin: "sa", DbPassword: "secret", DbName: "WeatherData"},
+			want: msdsn.Config{Host: "sql01", Instance: "SQLEXPRESS", User: "sa", Password: "secret", Database: "WeatherData"},
+		},
+		{
+			name: "экземпляр из DB_INSTANCE",
+			cfg:  config.Config{DbServer: `sql01\OLD`, DbInstance: "NEW", DbLogin: "sa", DbPassword: "secret", DbName: "WeatherData"},
+			want: msdsn.Config{Host: "sql01", Instance: "NEW", User: "sa", Password: "secret", Database: "WeatherData"},
+		},
+		{
+			name: "порт",
+			cfg:  config.Config{DbServer: "sql01.example.local", DbPort: 1444, DbLogin: "sa", DbPassword: "secret", DbName: "WeatherData"},
+			want: msdsn.Config{Host: "sql01.example.local", Port: 1444, User: "sa", Password: "secret", Database: "WeatherData"},
+		},
+		{
+			name: "пароль со спецсимволами",
+			cfg:  config.Config{DbServer: "sql01", DbLogin: `DOMAIN\svc`, DbPassword: "p@ss;word=1/?#", DbName: "Weather Data"},
+			want: msdsn.Config{Host: "sql01", User: `DOMAIN\svc`, Password: "p@ss;word=1/?#", Database: "Weather Data"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Строка подключения разбирается так же, как ее разбирает драйвер
+			got, _, err := msdsn.Parse(connectionString(&tt.cfg))
+			if err != nil {
+				t.Fatalf("драйвер не разобрал строку подключения: %v", err)
+			}
+			if got.Host != tt.want.Host || got.Instance != tt.want.Instance || got.Port != tt.want.Port ||
+				got.User != tt.want.User || got.Password != tt.want.Password || got.Database != tt.want.Database {
+				t.Errorf("сервер %q, экземпляр %q, порт %d, логин %q, пароль %q, база %q; ожидалось %q, %q, %d, %q, %q, %q",
+					got.Host, got.Instance, got.Port, got.User, got.Password, got.Database,
+					tt.want.Host, tt.want.Instance, tt.want.Port, tt.want.User, tt.want.Password, tt.want.Database)
+			}
+		})
+	}
+}
+
+func TestStoreTelemetryConflictPolicy(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantUpdates bool
+	}{
+		{policy: config.ConflictUpdate, wantUpdates: true},
+		{policy: config.ConflictSkip, wantUpdates: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			var mergeQuery string
+			d := newTestManager(t, &fakeDB{exec: func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+				if strings.Contains(query, "MERGE Telemetry") {
+					mergeQuery = query
+				}
+				return driver.RowsAffected(1), nil
+			}})
+			d.Config.ConflictPolicy = tt.policy
+
+			err := d.StoreTelemetry("st-1", map[string][]api.TelemetryPoint{
+				"airtemp": {{Ts: 1000, Value: 1.5}},
+			})
+			if err != nil {
+				t.Fatalf("ошибка при сохранении телеметрии: %v", err)
+			}
+
+			if mergeQuery == "" {
+				t.Fatal("запрос MERGE Telemetry не выполнен")
+			}
+			if got := strings.Contains(mergeQuery, "WHEN MATCHED"); got != tt.wantUpdates {
+				t.Errorf("обновление существующих строк (WHEN MATCHED): %t, ожидалось %t", got, tt.wantUpdates)
+			}
+			if !strings.Contains(mergeQuery, "WHEN NOT MATCHED") {
+				t.Error("в запросе нет вставки новых строк (WHEN NOT MATCHED)")
+			}
+		})
+	}
+}
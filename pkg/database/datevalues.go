@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// DefaultDateValueBatchSize - количество строк, исправляемых в одной транзакции по умолчанию
+const DefaultDateValueBatchSize = 5000
+
+// dateValueExpr вычисляет DateValue по столбцу Timestamp (мс) в UTC с точностью до секунды,
+// как DateValueFromTimestamp. Вычисление разбито на дни и секунды, потому что DATEADD принимает INT
+const dateValueExpr = `DATEADD(SECOND, CAST(Timestamp % 86400000 / 1000 AS INT),
+	DATEADD(DAY, CAST(Timestamp / 86400000 AS INT), CAST('1970-01-01' AS DATETIME2)))`
+
+// RecomputeDateValues пересчитывает DateValue по Timestamp в UTC для всех строк, у которых значение
+// отличается от вычисленного (например, сохраненных с неверным часовым поясом). Исправляются таблицы
+// Telemetry, LatestReadings и, если она включена, TelemetryWide. Строки обновляются пакетами по batchSize
+// в отдельных транзакциях, поэтому исправление можно запускать во время сбора данных, а повторный
+// запуск ничего не меняет. Возвращает количество обновленных строк во всех таблицах
+func (d *DBManager) RecomputeDateValues(batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("размер пакета должен быть больше нуля")
+	}
+
+	tables := []string{"Telemetry", "LatestReadings"}
+	if len(d.wideColumns) > 0 {
+		tables = append(tables, "TelemetryWide")
+	}
+
+	ctx := context.Background()
+	var total int64
+	for _, table := range tables {
+		updated, err := d.recomputeTableDateValues(ctx, table, batchSize)
+		total += updated
+		if err != nil {
+			return total, err
+		}
+		d.logger.Printf("Исправлено значений DateValue в таблице %s: %d", table, updated)
+	}
+
+	return total, nil
+}
+
+// recomputeTableDateValues исправляет DateValue в таблице пакетами и возвращает количество обновленных строк
+func (d *DBManager) recomputeTableDateValues(ctx context.Context, table string, batchSize int) (int64, error) {
+	query := fmt.Sprintf(`UPDATE TOP (%d) %s SET DateValue = %s WHERE DateValue <> %s`,
+		batchSize, table, dateValueExpr, dateValueExpr)
+
+	var total int64
+	for {
+		var rows int64
+		err := d.withRetryTx(ctx, func(tx *sql.Tx) error {
+			result, err := tx.ExecContext(ctx, query)
+			if err != nil {
+				return err
+			}
+			rows, err = result.RowsAffected()
+			return err
+		})
+		if err != nil {
+			return total, fmt.Errorf("ошибка при исправлении DateValue в таблице %s: %w", table, err)
+		}
+
+		total += rows
+		if rows < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
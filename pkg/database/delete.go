@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// deleteBatchSize - количество строк телеметрии, удаляемых в одной транзакции
+const deleteBatchSize = 5000
+
+// DeleteStation удаляет станцию и все ее данные. Телеметрия удаляется пакетами по deleteBatchSize строк
+// в отдельных транзакциях, чтобы не удерживать долгих блокировок и не переполнять журнал транзакций,
+// затем в одной транзакции удаляются остальные дочерние записи и сама станция.
+// Возвращает количество удаленных строк таблицы Telemetry
+func (d *DBManager) DeleteStation(stationID string) (int64, error) {
+	ctx := context.Background()
+
+	var exists int
+	if err := d.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM Stations WHERE ID = @StationID`,
+		sql.Named("StationID", stationID)).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("ошибка при поиске станции: %w", err)
+	}
+	if exists == 0 {
+		return 0, fmt.Errorf("станция %s не найдена", stationID)
+	}
+
+	deleted, err := d.deleteStationRows(ctx, "Telemetry", stationID)
+	if err != nil {
+		return deleted, err
+	}
+
+	if len(d.wideColumns) > 0 {
+		if _, err := d.deleteStationRows(ctx, "TelemetryWide", stationID); err != nil {
+			return deleted, err
+		}
+	}
+
+	err = d.withRetryTx(ctx, func(tx *sql.Tx) error {
+		// Сначала удаляются дочерние записи, затем станция, на которую ссылаются внешние ключи.
+		// Строки, сохраненные сбором данных во время пакетного удаления, удаляются здесь же
+		queries := []string{
+			`DELETE FROM Telemetry WHERE StationID = @StationID`,
+			`IF OBJECT_ID('TelemetryWide', 'U') IS NOT NULL DELETE FROM TelemetryWide WHERE StationID = @StationID`,
+			`DELETE FROM SyncState WHERE StationID = @StationID`,
+			`DELETE FROM SyncWindows WHERE StationID = @StationID`,
+			`DELETE FROM LatestReadings WHERE StationID = @StationID`,
+			`DELETE FROM Stations WHERE ID = @StationID`,
+		}
+		for i, query := range queries {
+			result, err := tx.ExecContext(ctx, query, sql.Named("StationID", stationID))
+			if err != nil {
+				return fmt.Errorf("ошибка при удалении данных станции: %w", err)
+			}
+			if i == 0 {
+				rows, err := result.RowsAffected()
+				if err != nil {
+					return fmt.Errorf("ошибка при получении количества удаленных строк: %w", err)
+				}
+				deleted += rows
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return deleted, fmt.Errorf("ошибка при удалении станции %s: %w", stationID, err)
+	}
+
+	d.stationCache.forget(stationID)
+	d.logger.Printf("Станция %s удалена, удалено записей телеметрии: %d", stationID, deleted)
+
+	return deleted, nil
+}
+
+// deleteStationRows удаляет строки станции из таблицы пакетами и возвращает количество удаленных строк
+func (d *DBManager) deleteStationRows(ctx context.Context, table, stationID string) (int64, error) {
+	var total int64
+	for {
+		var rows int64
+		err := d.withRetryTx(ctx, func(tx *sql.Tx) error {
+			result, err := tx.ExecContext(ctx,
+				fmt.Sprintf(`DELETE TOP (%d) FROM %s WHERE StationID = @StationID`, deleteBatchSize, table),
+				sql.Named("StationID", stationID))
+			if err != nil {
+				return err
+			}
+			rows, err = result.RowsAffected()
+			return err
+		})
+		if err != nil {
+			return total, fmt.Errorf("ошибка при удалении строк таблицы %s: %w", table, err)
+		}
+
+		total += rows
+		if rows < deleteBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"log"
+	"sort"
+
+	"weatherInTheField/pkg/api"
+)
+
+// dryRunSampleSize - количество записей, выводимых в лог в качестве примера
+const dryRunSampleSize = 3
+
+// DryRunStore оборачивает хранилище и вместо записи выводит в лог, что было бы сохранено.
+// Методы чтения передаются исходному хранилищу без изменений
+type DryRunStore struct {
+	TelemetryStore
+}
+
+// Проверка, что DryRunStore реализует интерфейс TelemetryStore
+var _ TelemetryStore = (*DryRunStore)(nil)
+
+// NewDryRunStore создает хранилище в режиме пробного запуска
+func NewDryRunStore(store TelemetryStore) *DryRunStore {
+	return &DryRunStore{TelemetryStore: store}
+}
+
+// StoreStations выводит в лог метеостанции, которые были бы сохранены
+func (d *DryRunStore) StoreStations(devices []api.Device) error {
+	log.Printf("[DRY RUN] Было бы сохранено метеостанций: %d", len(devices))
+	for i, device := range devices {
+		if i >= dryRunSampleSize {
+			break
+		}
+		log.Printf("[DRY RUN]   %s (%s): %.5f, %.5f", device.Label, device.ID, device.Latitude, device.Longitude)
+	}
+	return nil
+}
+
+// StoreTelemetry выводит в лог количество записей телеметрии и пример данных по каждому датчику
+func (d *DryRunStore) StoreTelemetry(deviceID string, data map[string][]api.TelemetryPoint) error {
+	sensorKeys := make([]string, 0, len(data))
+	total := 0
+	for sensorKey, points := range data {
+		sensorKeys = append(sensorKeys, sensorKey)
+		total += len(points)
+	}
+	sort.Strings(sensorKeys)
+
+	log.Printf("[DRY RUN] Для устройства %s было бы сохранено записей телеметрии: %d", deviceID, total)
+	for _, sensorKey := range sensorKeys {
+		points := data[sensorKey]
+		sample := points
+		if len(sample) > dryRunSampleSize {
+			sample = sample[:dryRunSampleSize]
+		}
+		log.Printf("[DRY RUN]   %s: %d записей, пример: %v", sensorKey, len(points), sample)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Параметры выгрузки в Parquet
+const (
+	// Количество строк, передаваемых в writer за один вызов
+	parquetWriteChunk = 1000
+	// Максимальное количество строк в группе строк: ограничивает объем данных, хранимых в памяти
+	parquetRowGroupSize = 50000
+)
+
+// parquetRow описывает строку файла Parquet с телеметрией
+type parquetRow struct {
+	StationID string    `parquet:"station_id"`
+	SensorKey string    `parquet:"sensor_key"`
+	Timestamp int64     `parquet:"timestamp"`
+	DateValue time.Time `parquet:"date_value,timestamp(millisecond)"`
+	Value     *float64  `parquet:"value,optional"`
+	Compass   *string   `parquet:"compass,optional"`
+}
+
+// ExportParquet выгружает телеметрию станции за период [from, to) в формате Parquet.
+// Строки читаются из базы данных и записываются группами, поэтому весь результат
+// не загружается в память и выгрузка масштабируется на данные за год
+func (d *DBManager) ExportParquet(w io.Writer, stationID string, from, to time.Time) error {
+	rows, err := d.DB.Query(`
+	SELECT StationID, SensorKey, Timestamp, DateValue, Value
+	FROM Telemetry
+	WHERE StationID = @StationID AND Timestamp >= @TsFrom AND Timestamp < @TsTo
+	ORDER BY Timestamp, SensorKey
+	`,
+		sql.Named("StationID", stationID),
+		sql.Named("TsFrom", from.UnixMilli()),
+		sql.Named("TsTo", to.UnixMilli()),
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка при запросе телеметрии для выгрузки: %w", err)
+	}
+	defer rows.Close()
+
+	writer := parquet.NewGenericWriter[parquetRow](w, parquet.MaxRowsPerRowGroup(parquetRowGroupSize))
+
+	chunk := make([]parquetRow, 0, parquetWriteChunk)
+	total := 0
+	for rows.Next() {
+		var row parquetRow
+		var value sql.NullFloat64
+		if err := rows.Scan(&row.StationID, &row.SensorKey, &row.Timestamp, &row.DateValue, &value); err != nil {
+			return fmt.Errorf("ошибка при сканировании записи телеметрии: %w", err)
+		}
+		if value.Valid {
+			v := value.Float64
+			row.Value = &v
+		}
+		if compass := d.compassLabel(row.SensorKey, value); compass != "" {
+			row.Compass = &compass
+		}
+		row.DateValue = row.DateValue.UTC()
+
+		chunk = append(chunk, row)
+		if len(chunk) == parquetWriteChunk {
+			if _, err := writer.Write(chunk); err != nil {
+				return fmt.Errorf("ошибка при записи в Parquet: %w", err)
+			}
+			total += len(chunk)
+			chunk = chunk[:0]
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при итерации результатов: %w", err)
+	}
+
+	if len(chunk) > 0 {
+		if _, err := writer.Write(chunk); err != nil {
+			return fmt.Errorf("ошибка при записи в Parquet: %w", err)
+		}
+		total += len(chunk)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("ошибка при завершении файла Parquet: %w", err)
+	}
+
+	d.logger.Printf("Выгружено записей телеметрии станции %s в Parquet: %d", stationID, total)
+	return nil
+}
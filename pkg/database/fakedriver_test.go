@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"weatherInTheField/pkg/config"
+)
+
+// fakeDB - тестовая база данных для database/sql. Запросы выполняются функциями теста,
+// драйвер считает транзакции и проверки соединения
+type fakeDB struct {
+	mu sync.Mutex
+
+	// Обработчики запросов (nil - запрос выполняется без результата)
+	exec  func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error)
+	query func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error)
+
+	// Ошибка проверки соединения
+	pingErr error
+
+	pings     int
+	begins    int
+	commits   int
+	rollbacks int
+}
+
+// newTestManager создает менеджер БД, работающий с тестовой базой данных
+func newTestManager(t *testing.T, db *fakeDB) *DBManager {
+	t.Helper()
+
+	sqlDB := sql.OpenDB(db)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return &DBManager{
+		Config:      &config.Config{ConflictPolicy: config.ConflictUpdate},
+		DB:          sqlDB,
+		driverName:  "fake",
+		pingTimeout: time.Second,
+		logger:      log.New(io.Discard, "", 0),
+	}
+}
+
+// counts возвращает количество начатых, закоммиченных и откаченных транзакций
+func (db *fakeDB) counts() (begins, commits, rollbacks int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.begins, db.commits, db.rollbacks
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return fakeDriver{db: db} }
+
+type fakeDriver struct{ db *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{db: d.db}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.begins++
+	return fakeTx{db: c.db}, nil
+}
+
+func (c *fakeConn) Ping(context.Context) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.pings++
+	return c.db.pingErr
+}
+
+// CheckNamedValue принимает параметры любых типов без преобразования
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.db.exec == nil {
+		return driver.RowsAffected(0), nil
+	}
+	return c.db.exec(ctx, query, args)
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.db.query == nil {
+		return &fakeRows{}, nil
+	}
+	return c.db.query(ctx, query, args)
+}
+
+// fakeStmt - подготовленный запрос, выполняемый обработчиками тестовой базы данных
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("используйте ExecContext")
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("используйте QueryContext")
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// namedArg возвращает значение именованного параметра запроса
+func namedArg(args []driver.NamedValue, name string) interface{} {
+	for _, arg := range args {
+		if arg.Name == name {
+			return arg.Value
+		}
+	}
+	return nil
+}
+
+type fakeTx struct{ db *fakeDB }
+
+func (tx fakeTx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.commits++
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.rollbacks++
+	return nil
+}
+
+// fakeRows - результат запроса из заранее заданных строк
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// fakeSQLError - ошибка SQL Server с номером, как у ошибок драйвера
+type fakeSQLError int32
+
+func (e fakeSQLError) Error() string         { return fmt.Sprintf("mssql: ошибка %d", int32(e)) }
+func (e fakeSQLError) SQLErrorNumber() int32 { return int32(e) }
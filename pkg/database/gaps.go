@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Gap описывает пропуск в данных датчика: между соседними измерениями (или между последним
+// измерением и текущим временем) прошло больше ожидаемого интервала
+type Gap struct {
+	SensorKey       string `json:"sensor_key"`
+	From            int64  `json:"from"` // время последнего измерения перед пропуском (мс)
+	To              int64  `json:"to"`   // время первого измерения после пропуска или текущее время (мс)
+	DurationMinutes int64  `json:"duration_minutes"`
+}
+
+// GapReporter описывает хранилище, которое может найти пропуски в данных станции
+type GapReporter interface {
+	GapReport(stationID string, expectedIntervalMinutes int) ([]Gap, error)
+}
+
+// Проверка, что DBManager реализует интерфейс GapReporter
+var _ GapReporter = (*DBManager)(nil)
+
+// GapReport возвращает пропуски в данных датчиков станции, длиннее ожидаемого интервала между измерениями.
+// Последним пропуском датчика считается время от его последнего измерения до текущего момента, если оно
+// превышает интервал. Пропуски упорядочены по датчику и времени и могут служить периодами для повторного запроса
+func (d *DBManager) GapReport(stationID string, expectedIntervalMinutes int) ([]Gap, error) {
+	if expectedIntervalMinutes <= 0 {
+		return nil, fmt.Errorf("ожидаемый интервал между измерениями должен быть больше нуля")
+	}
+
+	ctx := context.Background()
+	rows, err := d.DB.QueryContext(ctx, `
+	SELECT SensorKey, Timestamp, NextTimestamp
+	FROM (
+		SELECT SensorKey, Timestamp,
+			COALESCE(LEAD(Timestamp) OVER (PARTITION BY SensorKey ORDER BY Timestamp), @Now) AS NextTimestamp
+		FROM Telemetry
+		WHERE StationID = @StationID
+	) AS t
+	WHERE NextTimestamp - Timestamp > @MaxInterval
+	ORDER BY SensorKey, Timestamp
+	`,
+		sql.Named("StationID", stationID),
+		sql.Named("Now", time.Now().UnixMilli()),
+		sql.Named("MaxInterval", int64(expectedIntervalMinutes)*60*1000),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при поиске пропусков в данных станции %s: %w", stationID, err)
+	}
+	defer rows.Close()
+
+	var gaps []Gap
+	for rows.Next() {
+		var gap Gap
+		if err := rows.Scan(&gap.SensorKey, &gap.From, &gap.To); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании пропуска: %w", err)
+		}
+		gap.DurationMinutes = (gap.To - gap.From) / (60 * 1000)
+		gaps = append(gaps, gap)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации результатов: %w", err)
+	}
+
+	return gaps, nil
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"weatherInTheField/pkg/api"
+)
+
+// LatestReadingsStore описывает хранилище последних показаний датчиков станций. Показания берутся
+// из списка устройств, поэтому текущие данные доступны до завершения первого запроса телеметрии
+type LatestReadingsStore interface {
+	StoreLatestReadings(ctx context.Context, stationID string, readings map[string][]api.TelemetryPoint) error
+}
+
+// Проверка, что DBManager реализует интерфейс LatestReadingsStore
+var _ LatestReadingsStore = (*DBManager)(nil)
+
+// createLatestReadingsTable создает таблицу последних показаний датчиков
+func (d *DBManager) createLatestReadingsTable() error {
+	_, err := d.DB.Exec(`
+	IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='LatestReadings' AND xtype='U')
+	CREATE TABLE LatestReadings (
+		StationID NVARCHAR(100) NOT NULL,
+		SensorKey NVARCHAR(100) NOT NULL,
+		Timestamp BIGINT NOT NULL,
+		DateValue DATETIME2 NOT NULL,
+		Value FLOAT,
+		StrValue NVARCHAR(255),
+		Unit NVARCHAR(20),
+		UpdatedAt DATETIME2 NOT NULL,
+		CONSTRAINT PK_LatestReadings PRIMARY KEY (StationID, SensorKey),
+		CONSTRAINT FK_LatestReadings_Stations FOREIGN KEY (StationID) REFERENCES Stations(ID)
+	)
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании таблицы LatestReadings: %w", err)
+	}
+
+	return nil
+}
+
+// StoreLatestReadings сохраняет последние показания датчиков станции. Показание заменяет сохраненное,
+// только если оно новее, поэтому устаревшие данные списка устройств не затирают более свежие.
+// Числовые значения сохраняются в Value (NaN и бесконечность - NULL), непустые строки - в StrValue,
+// остальные значения пропускаются
+func (d *DBManager) StoreLatestReadings(ctx context.Context, stationID string, readings map[string][]api.TelemetryPoint) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	return d.withRetryTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+		MERGE LatestReadings WITH (HOLDLOCK) AS target
+		USING (SELECT @StationID AS StationID, @SensorKey AS SensorKey) AS source
+		ON target.StationID = source.StationID AND target.SensorKey = source.SensorKey
+		WHEN MATCHED AND @Timestamp > target.Timestamp THEN
+			UPDATE SET Timestamp = @Timestamp, DateValue = @DateValue, Value = @Value, StrValue = @StrValue,
+				Unit = @Unit, UpdatedAt = GETDATE()
+		WHEN NOT MATCHED THEN
+			INSERT (StationID, SensorKey, Timestamp, DateValue, Value, StrValue, Unit, UpdatedAt)
+			VALUES (@StationID, @SensorKey, @Timestamp, @DateValue, @Value, @StrValue, @Unit, GETDATE());
+		`)
+		if err != nil {
+			return fmt.Errorf("ошибка при подготовке запроса: %w", err)
+		}
+		defer stmt.Close()
+
+		for sensorKey, points := range readings {
+			for _, point := range points {
+				var value sql.NullFloat64
+				var strValue sql.NullString
+				if floatValue, ok := point.Float(); ok {
+					if !math.IsNaN(floatValue) && !math.IsInf(floatValue, 0) {
+						value = sql.NullFloat64{Float64: floatValue, Valid: true}
+					}
+				} else if str, ok := point.Value.(string); ok && str != "" {
+					strValue = sql.NullString{String: str, Valid: true}
+				} else {
+					continue
+				}
+
+				err := d.withStatementTimeout(ctx, func(ctx context.Context) error {
+					_, err := stmt.ExecContext(ctx,
+						sql.Named("StationID", stationID),
+						sql.Named("SensorKey", sensorKey),
+						sql.Named("Timestamp", point.Ts),
+						sql.Named("DateValue", DateValueFromTimestamp(point.Ts)),
+						sql.Named("Value", value),
+						sql.Named("StrValue", strValue),
+						sql.Named("Unit", sql.NullString{String: point.Unit, Valid: point.Unit != ""}),
+					)
+					return err
+				})
+				if err != nil {
+					return fmt.Errorf("ошибка при сохранении последнего показания датчика %s: %w", sensorKey, err)
+				}
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"log"
+	"time"
+)
+
+// Значения по умолчанию для менеджера БД
+const (
+	defaultDriverName  = "sqlserver"
+	defaultPingTimeout = 5 * time.Second
+)
+
+// Option задает дополнительную настройку менеджера БД
+type Option func(*DBManager)
+
+// WithPingTimeout задает время ожидания проверки соединения при создании менеджера
+func WithPingTimeout(timeout time.Duration) Option {
+	return func(d *DBManager) {
+		d.pingTimeout = timeout
+	}
+}
+
+// WithMaxOpenConns задает максимальное количество открытых соединений,
+// переопределяя значение DB_MAX_OPEN_CONNS из конфигурации
+func WithMaxOpenConns(n int) Option {
+	return func(d *DBManager) {
+		d.maxOpenConns = n
+	}
+}
+
+// WithMaxConcurrentTx задает максимальное количество одновременно открытых транзакций,
+// переопределяя значение DB_MAX_CONCURRENT_TX из конфигурации
+func WithMaxConcurrentTx(n int) Option {
+	return func(d *DBManager) {
+		d.maxConcurrentTx = n
+	}
+}
+
+// WithDialect задает имя драйвера database/sql, используемого для подключения
+func WithDialect(driverName string) Option {
+	return func(d *DBManager) {
+		d.driverName = driverName
+	}
+}
+
+// WithLogger задает журнал для сообщений менеджера БД
+func WithLogger(logger *log.Logger) Option {
+	return func(d *DBManager) {
+		d.logger = logger
+	}
+}
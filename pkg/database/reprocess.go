@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// reprocessBatchPeriod - длительность периода сырых данных, пересчитываемого в одной транзакции
+const reprocessBatchPeriod = 24 * time.Hour
+
+// Reprocess заново заполняет производные таблицы по сырым данным таблицы Telemetry за период [from, to)
+// без запросов к API. Сейчас единственная производная таблица - TelemetryWide; если она не включена,
+// пересчитывать нечего и ничего не выполняется. Период обрабатывается частями по суткам, каждая
+// в отдельной транзакции, поэтому пересчет можно выполнять во время сбора данных. Значения производных
+// строк заменяются значениями из Telemetry, и повторный запуск дает тот же результат.
+// Возвращает количество обновленных строк
+func (d *DBManager) Reprocess(from, to time.Time) (int64, error) {
+	if !from.Before(to) {
+		return 0, fmt.Errorf("начало периода должно быть раньше конца")
+	}
+	if len(d.wideColumns) == 0 {
+		d.logger.Printf("Нет производных таблиц для пересчета: таблица TelemetryWide не включена (TELEMETRY_WIDE), пересчет не выполняется")
+		return 0, nil
+	}
+
+	query := d.reprocessWideQuery()
+
+	var total int64
+	for start := from; start.Before(to); start = start.Add(reprocessBatchPeriod) {
+		end := start.Add(reprocessBatchPeriod)
+		if end.After(to) {
+			end = to
+		}
+
+		ctx := context.Background()
+		var affected int64
+		err := d.withRetryTx(ctx, func(tx *sql.Tx) error {
+			args := []interface{}{
+				sql.Named("TsFrom", start.UnixMilli()),
+				sql.Named("TsTo", end.UnixMilli()),
+			}
+			for i, column := range d.wideColumns {
+				args = append(args, sql.Named(fmt.Sprintf("K%d", i), column))
+			}
+
+			result, err := tx.ExecContext(ctx, query, args...)
+			if err != nil {
+				return fmt.Errorf("ошибка при пересчете таблицы TelemetryWide: %w", err)
+			}
+			affected, err = result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("ошибка при получении количества обновленных строк: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return total, fmt.Errorf("ошибка при пересчете периода %s - %s: %w",
+				start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), err)
+		}
+
+		total += affected
+		d.logger.Printf("Пересчитан период %s - %s: обновлено строк TelemetryWide: %d",
+			start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), affected)
+	}
+
+	return total, nil
+}
+
+// reprocessWideQuery возвращает запрос, который разворачивает сырые значения датчиков за период
+// в строки TelemetryWide и сохраняет их, заменяя значения существующих строк
+func (d *DBManager) reprocessWideQuery() string {
+	pivot := make([]string, 0, len(d.wideColumns))
+	keys := make([]string, 0, len(d.wideColumns))
+	updates := make([]string, 0, len(d.wideColumns))
+	columns := make([]string, 0, len(d.wideColumns))
+	values := make([]string, 0, len(d.wideColumns))
+	for i, column := range d.wideColumns {
+		pivot = append(pivot, fmt.Sprintf("MAX(CASE WHEN SensorKey = @K%d THEN Value END) AS [%s]", i, column))
+		keys = append(keys, fmt.Sprintf("@K%d", i))
+		updates = append(updates, fmt.Sprintf("[%s] = source.[%s]", column, column))
+		columns = append(columns, "["+column+"]")
+		values = append(values, "source.["+column+"]")
+	}
+
+	return `
+	MERGE TelemetryWide WITH (HOLDLOCK) AS target
+	USING (
+		SELECT StationID, Timestamp, MIN(DateValue) AS DateValue, ` + strings.Join(pivot, ", ") + `
+		FROM Telemetry
+		WHERE Timestamp >= @TsFrom AND Timestamp < @TsTo AND SensorKey IN (` + strings.Join(keys, ", ") + `)
+		GROUP BY StationID, Timestamp
+	) AS source
+	ON target.StationID = source.StationID AND target.Timestamp = source.Timestamp
+	WHEN MATCHED THEN
+		UPDATE SET ` + strings.Join(updates, ", ") + `
+	WHEN NOT MATCHED THEN
+		INSERT (StationID, Timestamp, DateValue, ` + strings.Join(columns, ", ") + `)
+		VALUES (source.StationID, source.Timestamp, source.DateValue, ` + strings.Join(values, ", ") + `);
+	`
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+func TestReprocessWithoutWideTable(t *testing.T) {
+	var queries int
+	d := newTestManager(t, &fakeDB{exec: func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		queries++
+		return driver.RowsAffected(0), nil
+	}})
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows, err := d.Reprocess(from, from.Add(48*time.Hour))
+	if err != nil {
+		t.Fatalf("пересчет без TelemetryWide вернул ошибку: %v", err)
+	}
+	if rows != 0 || queries != 0 {
+		t.Errorf("обновлено строк %d, выполнено запросов %d, ожидалось 0 и 0", rows, queries)
+	}
+
+	if _, err := d.Reprocess(from, from); err == nil {
+		t.Error("пустой период принят, ожидалась ошибка")
+	}
+}
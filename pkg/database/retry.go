@@ -0,0 +1,209 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Параметры повтора транзакций при временных ошибках
+const (
+	maxTxAttempts  = 3
+	txRetryBackoff = 500 * time.Millisecond
+)
+
+// transientSQLErrors содержит номера ошибок SQL Server, после которых транзакцию можно повторить
+var transientSQLErrors = map[int32]bool{
+	1205:  true, // транзакция выбрана жертвой взаимоблокировки
+	1222:  true, // превышено время ожидания блокировки
+	40501: true, // сервис занят
+	40613: true, // база данных временно недоступна
+	49918: true, // недостаточно ресурсов для обработки запроса
+	49919: true, // слишком много операций создания/обновления
+	49920: true, // слишком много операций
+}
+
+// ErrStatementTimeout возвращается (в обертке), если запрос к базе данных не выполнен
+// за DB_STATEMENT_TIMEOUT_SECONDS. Такая ошибка считается временной, и транзакция повторяется
+var ErrStatementTimeout = errors.New("превышено время выполнения запроса к базе данных")
+
+// sqlErrorNumberer реализуется ошибками драйвера SQL Server
+type sqlErrorNumberer interface {
+	SQLErrorNumber() int32
+}
+
+// isTransientError определяет, является ли ошибка временной (взаимоблокировка, обрыв соединения),
+// после которой имеет смысл повторить транзакцию
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Запрос мог не успеть выполниться из-за нагрузки на сервер
+	if errors.Is(err, ErrStatementTimeout) {
+		return true
+	}
+
+	var sqlErr sqlErrorNumberer
+	if errors.As(err, &sqlErr) {
+		return transientSQLErrors[sqlErr.SQLErrorNumber()]
+	}
+
+	return isConnectionError(err)
+}
+
+// isConnectionError определяет, вызвана ли ошибка разорванным соединением с базой данных
+// (например, после перезапуска SQL Server соединения в пуле становятся недействительными)
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// Драйвер не всегда оборачивает ошибку закрытого соединения
+	return strings.Contains(strings.ToLower(err.Error()), "connection is closed")
+}
+
+// reconnect закрывает простаивающие соединения пула и проверяет соединение с базой данных,
+// чтобы следующая операция выполнялась на новом соединении
+func (d *DBManager) reconnect(ctx context.Context) error {
+	// Сброс лимита простаивающих соединений закрывает все простаивающие соединения пула
+	d.DB.SetMaxIdleConns(0)
+	d.DB.SetMaxIdleConns(d.Config.DbMaxIdleConns)
+
+	return d.Healthy(ctx)
+}
+
+// withReconnect выполняет fn и при ошибке соединения повторяет его один раз после переподключения к базе данных
+func (d *DBManager) withReconnect(ctx context.Context, fn func() error) error {
+	err := fn()
+	if !isConnectionError(err) || ctx.Err() != nil {
+		return err
+	}
+
+	d.logger.Printf("Соединение с базой данных разорвано, переподключаемся: %v", err)
+	if reconnectErr := d.reconnect(ctx); reconnectErr != nil {
+		return fmt.Errorf("%w (переподключение не удалось: %v)", err, reconnectErr)
+	}
+	return fn()
+}
+
+// withStatementTimeout выполняет запрос fn с ограничением времени DB_STATEMENT_TIMEOUT_SECONDS
+// (0 - без ограничения). Если время истекло, возвращается ошибка, обернутая в ErrStatementTimeout;
+// отмена исходного контекста так не оборачивается
+func (d *DBManager) withStatementTimeout(ctx context.Context, fn func(ctx context.Context) error) error {
+	if d.statementTimeout <= 0 {
+		return fn(ctx)
+	}
+
+	stmtCtx, cancel := context.WithTimeout(ctx, d.statementTimeout)
+	defer cancel()
+
+	err := fn(stmtCtx)
+	if err != nil && errors.Is(stmtCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return fmt.Errorf("%w (%s): %v", ErrStatementTimeout, d.statementTimeout, err)
+	}
+	return err
+}
+
+// Healthy проверяет доступность базы данных
+func (d *DBManager) Healthy(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, d.pingTimeout)
+	defer cancel()
+
+	if err := d.DB.PingContext(ctx); err != nil {
+		return fmt.Errorf("база данных недоступна: %w", err)
+	}
+	return nil
+}
+
+// withRetryTx выполняет fn в транзакции и коммитит ее. При временной ошибке транзакция
+// откатывается и повторяется с увеличивающейся задержкой, при остальных ошибках
+// ошибка возвращается сразу. Отмена контекста прерывает транзакцию и ожидание повтора
+func (d *DBManager) withRetryTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = d.runTx(ctx, fn)
+		if err == nil || !isTransientError(err) {
+			return err
+		}
+
+		if attempt < maxTxAttempts {
+			backoff := txRetryBackoff * time.Duration(1<<(attempt-1))
+			d.logger.Printf("Временная ошибка базы данных (попытка %d из %d), повтор через %s: %v",
+				attempt, maxTxAttempts, backoff, err)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return fmt.Errorf("транзакция прервана: %w", ctx.Err())
+			}
+
+			// После обрыва соединения повторяем транзакцию на новых соединениях
+			if isConnectionError(err) {
+				if reconnectErr := d.reconnect(ctx); reconnectErr != nil {
+					d.logger.Printf("Ошибка при переподключении к базе данных: %v", reconnectErr)
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("транзакция не выполнена после %d попыток: %w", maxTxAttempts, err)
+}
+
+// runTx выполняет fn в одной транзакции, откатывая ее при ошибке или панике.
+// При DB_MAX_CONCURRENT_TX транзакция начинается только после освобождения места
+func (d *DBManager) runTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if d.txSlots != nil {
+		select {
+		case d.txSlots <- struct{}{}:
+			defer func() { <-d.txSlots }()
+		case <-ctx.Done():
+			return fmt.Errorf("ожидание свободной транзакции прервано: %w", ctx.Err())
+		}
+	}
+
+	// Начинаем транзакцию
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка при начале транзакции: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p) // паника после отката
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Коммитим транзакцию
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка при коммите транзакции: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"нет ошибки", nil, false},
+		{"взаимоблокировка 1205", fakeSQLError(1205), true},
+		{"ожидание блокировки 1222", fmt.Errorf("обертка: %w", fakeSQLError(1222)), true},
+		{"нарушение уникальности 2627", fakeSQLError(2627), false},
+		{"ошибка входа 18456", fakeSQLError(18456), false},
+		{"недействительное соединение", driver.ErrBadConn, true},
+		{"соединение закрыто", sql.ErrConnDone, true},
+		{"обрыв ответа", io.ErrUnexpectedEOF, true},
+		{"сброс соединения", syscall.ECONNRESET, true},
+		{"сетевая ошибка", &net.OpError{Op: "read", Err: errors.New("timeout")}, true},
+		{"текст драйвера", errors.New("mssql: connection is closed"), true},
+		{"таймаут запроса", fmt.Errorf("%w (5s): ...", ErrStatementTimeout), true},
+		{"прочая ошибка", errors.New("invalid column name"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientError(tt.err); got != tt.want {
+				t.Errorf("isTransientError(%v) = %t, ожидалось %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRetryTxRetriesTransientError(t *testing.T) {
+	db := &fakeDB{}
+	d := newTestManager(t, db)
+
+	attempts := 0
+	err := d.withRetryTx(context.Background(), func(tx *sql.Tx) error {
+		attempts++
+		if attempts == 1 {
+			return fakeSQLError(1205)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("транзакция не выполнена после повтора: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("попыток %d, ожидалось 2", attempts)
+	}
+
+	begins, commits, rollbacks := db.counts()
+	if begins != 2 || commits != 1 || rollbacks != 1 {
+		t.Errorf("транзакций начато %d, закоммичено %d, откачено %d, ожидалось 2, 1, 1", begins, commits, rollbacks)
+	}
+}
+
+func TestWithRetryTxReturnsPermanentError(t *testing.T) {
+	db := &fakeDB{}
+	d := newTestManager(t, db)
+
+	attempts := 0
+	err := d.withRetryTx(context.Background(), func(tx *sql.Tx) error {
+		attempts++
+		return fakeSQLError(2627)
+	})
+
+	var sqlErr fakeSQLError
+	if !errors.As(err, &sqlErr) || sqlErr != 2627 {
+		t.Fatalf("ошибка %v, ожидалась ошибка 2627", err)
+	}
+	if attempts != 1 {
+		t.Errorf("попыток %d, постоянная ошибка не должна повторяться", attempts)
+	}
+}
+
+func TestWithRetryTxStopsOnCancel(t *testing.T) {
+	d := newTestManager(t, &fakeDB{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := d.withRetryTx(ctx, func(tx *sql.Tx) error {
+		attempts++
+		cancel()
+		return fakeSQLError(1205)
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ошибка %v, ожидалась отмена контекста", err)
+	}
+	if attempts != 1 {
+		t.Errorf("попыток %d, после отмены контекста повтор не выполняется", attempts)
+	}
+}
+
+func TestWithReconnectClosedConnection(t *testing.T) {
+	db := &fakeDB{}
+	queries := 0
+	db.query = func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		queries++
+		if queries == 1 {
+			// Драйвер сообщает о закрытом соединении без driver.ErrBadConn, поэтому database/sql не повторяет запрос
+			return nil, errors.New("mssql: connection is closed")
+		}
+		return &fakeRows{columns: []string{"SensorKey", "Timestamp"}, rows: [][]driver.Value{{"airtemp", int64(1000)}}}, nil
+	}
+	d := newTestManager(t, db)
+
+	latest, err := d.GetLatestTimestamps("st-1")
+	if err != nil {
+		t.Fatalf("запрос не повторен после переподключения: %v", err)
+	}
+	if latest["airtemp"] != 1000 {
+		t.Errorf("последние timestamp %v, ожидалось airtemp=1000", latest)
+	}
+	if queries != 2 {
+		t.Errorf("запросов %d, ожидалось 2", queries)
+	}
+	if db.pings != 1 {
+		t.Errorf("проверок соединения %d, ожидалась 1", db.pings)
+	}
+}
+
+func TestWithReconnectPingFails(t *testing.T) {
+	db := &fakeDB{pingErr: errors.New("сервер недоступен")}
+	queries := 0
+	db.query = func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		queries++
+		return nil, errors.New("mssql: connection is closed")
+	}
+	d := newTestManager(t, db)
+
+	_, err := d.GetLatestTimestamps("st-1")
+	if err == nil || !strings.Contains(err.Error(), "переподключение не удалось") {
+		t.Fatalf("ошибка %v, ожидалась ошибка переподключения", err)
+	}
+	if queries != 1 {
+		t.Errorf("запросов %d, ожидался 1: без соединения запрос не повторяется", queries)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	db := &fakeDB{}
+	queries := 0
+	db.query = func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		// Медленный запрос выполняется, пока его не отменят
+		queries++
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	d := newTestManager(t, db)
+	d.statementTimeout = 50 * time.Millisecond
+
+	started := time.Now()
+	_, err := d.GetLatestTimestamps("st-1")
+	if !errors.Is(err, ErrStatementTimeout) {
+		t.Fatalf("ошибка %v, ожидалась ErrStatementTimeout", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("запрос прерван через %s, ожидалось около %s", elapsed, d.statementTimeout)
+	}
+	if queries != 1 {
+		t.Errorf("запросов %d, ожидался 1: таймаут запроса не считается разрывом соединения", queries)
+	}
+}
+
+func TestStatementTimeoutParentCanceled(t *testing.T) {
+	d := newTestManager(t, &fakeDB{})
+	d.statementTimeout = time.Minute
+
+	// Отмена исходного контекста не считается превышением времени запроса
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := d.withStatementTimeout(ctx, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrStatementTimeout) {
+		t.Errorf("ошибка %v, ожидалась context.Canceled без ErrStatementTimeout", err)
+	}
+}
+
+func TestRunTxMaxConcurrent(t *testing.T) {
+	const maxConcurrent, workers = 2, 10
+
+	db := &fakeDB{}
+	d := newTestManager(t, db)
+	d.txSlots = make(chan struct{}, maxConcurrent)
+
+	var active, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := d.runTx(context.Background(), func(tx *sql.Tx) error {
+				n := active.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				active.Add(-1)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("ошибка транзакции: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > maxConcurrent {
+		t.Errorf("одновременно выполнялось транзакций %d, ожидалось не больше %d", got, maxConcurrent)
+	}
+	if _, commits, _ := db.counts(); commits != workers {
+		t.Errorf("закоммичено транзакций %d, ожидалось %d", commits, workers)
+	}
+}
+
+func TestRunTxWaitCanceled(t *testing.T) {
+	db := &fakeDB{}
+	d := newTestManager(t, db)
+	d.txSlots = make(chan struct{}, 1)
+	d.txSlots <- struct{}{} // единственное место занято
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	called := false
+	err := d.runTx(ctx, func(tx *sql.Tx) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ошибка %v, ожидалось прерывание ожидания по контексту", err)
+	}
+	if called {
+		t.Error("транзакция выполнена без свободного места")
+	}
+	if begins, _, _ := db.counts(); begins != 0 {
+		t.Errorf("начато транзакций %d, ожидалось 0", begins)
+	}
+}
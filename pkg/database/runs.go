@@ -0,0 +1,92 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxErrorSummaryLength ограничивает длину сохраняемой сводки ошибок
+const maxErrorSummaryLength = 4000
+
+// RunStats содержит итоги одного цикла сбора данных
+type RunStats struct {
+	DevicesProcessed int
+	TotalRecords     int
+	Errors           []string
+}
+
+// RunRecorder описывает хранилище, которое ведет журнал запусков сбора данных
+type RunRecorder interface {
+	StartRun() (int64, error)
+	FinishRun(id int64, stats RunStats) error
+}
+
+// Проверка, что DBManager реализует интерфейс RunRecorder
+var _ RunRecorder = (*DBManager)(nil)
+
+// createRunsTable создает таблицу журнала запусков сбора данных
+func (d *DBManager) createRunsTable() error {
+	_, err := d.DB.Exec(`
+	IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='CollectionRuns' AND xtype='U')
+	CREATE TABLE CollectionRuns (
+		ID BIGINT IDENTITY(1,1) PRIMARY KEY,
+		StartedAt DATETIME2 NOT NULL,
+		FinishedAt DATETIME2,
+		DevicesProcessed INT,
+		TotalRecords INT,
+		ErrorSummary NVARCHAR(MAX)
+	)
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании таблицы CollectionRuns: %w", err)
+	}
+
+	return nil
+}
+
+// StartRun регистрирует начало цикла сбора данных и возвращает ID запуска
+func (d *DBManager) StartRun() (int64, error) {
+	var id int64
+	err := d.DB.QueryRow(`
+	INSERT INTO CollectionRuns (StartedAt)
+	OUTPUT INSERTED.ID
+	VALUES (GETDATE())
+	`).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при регистрации запуска: %w", err)
+	}
+
+	return id, nil
+}
+
+// FinishRun сохраняет время завершения и итоги цикла сбора данных
+func (d *DBManager) FinishRun(id int64, stats RunStats) error {
+	var errorSummary sql.NullString
+	if len(stats.Errors) > 0 {
+		summary := strings.Join(stats.Errors, "; ")
+		if len([]rune(summary)) > maxErrorSummaryLength {
+			summary = string([]rune(summary)[:maxErrorSummaryLength]) + "..."
+		}
+		errorSummary = sql.NullString{String: summary, Valid: true}
+	}
+
+	_, err := d.DB.Exec(`
+	UPDATE CollectionRuns
+	SET FinishedAt = GETDATE(),
+		DevicesProcessed = @DevicesProcessed,
+		TotalRecords = @TotalRecords,
+		ErrorSummary = @ErrorSummary
+	WHERE ID = @ID
+	`,
+		sql.Named("ID", id),
+		sql.Named("DevicesProcessed", stats.DevicesProcessed),
+		sql.Named("TotalRecords", stats.TotalRecords),
+		sql.Named("ErrorSummary", errorSummary),
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении итогов запуска: %w", err)
+	}
+
+	return nil
+}
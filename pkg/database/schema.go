@@ -0,0 +1,141 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// expectedColumns содержит столбцы, которые должны присутствовать в таблицах сервиса
+var expectedColumns = map[string][]string{
+	"Stations":       {"ID", "Name", "Label", "Latitude", "Longitude", "TimeZone", "LastUpdate"},
+	"Telemetry":      {"ID", "StationID", "SensorKey", "Timestamp", "DateValue", "Value", "StrValue", "Unit", "CreatedAt"},
+	"CollectionRuns": {"ID", "StartedAt", "FinishedAt", "DevicesProcessed", "TotalRecords", "ErrorSummary"},
+	"SyncState":      {"StationID", "SensorKey", "SyncedFrom", "SyncedTo", "UpdatedAt"},
+	"SyncWindows":    {"WindowHash", "StationID", "SensorKey", "TsFrom", "TsTo", "CompletedAt"},
+	"LatestReadings": {"StationID", "SensorKey", "Timestamp", "DateValue", "Value", "StrValue", "Unit", "UpdatedAt"},
+}
+
+// expectedIndexes содержит индексы, которые должны присутствовать в таблицах сервиса
+var expectedIndexes = map[string][]string{
+	"Telemetry": {"IX_Telemetry_StationID_SensorKey_Timestamp", "IX_Telemetry_DateValue"},
+}
+
+// expectedSchemaColumns возвращает ожидаемые столбцы таблиц с учетом включенной широкой таблицы
+func (d *DBManager) expectedSchemaColumns() map[string][]string {
+	expected := make(map[string][]string, len(expectedColumns)+1)
+	for table, columns := range expectedColumns {
+		expected[table] = columns
+	}
+	if len(d.wideColumns) > 0 {
+		expected["TelemetryWide"] = append([]string{"StationID", "Timestamp", "DateValue"}, d.wideColumns...)
+	}
+	return expected
+}
+
+// VerifySchema проверяет, что в базе данных есть все таблицы, столбцы и индексы, необходимые сервису.
+// Возвращает ошибку со списком отсутствующих объектов, чтобы незавершенная миграция
+// обнаруживалась при запуске, а не при записи данных
+func (d *DBManager) VerifySchema() error {
+	expected := d.expectedSchemaColumns()
+	tables := sortedKeys(expected)
+
+	columns, err := d.existingColumns(tables)
+	if err != nil {
+		return err
+	}
+
+	indexes, err := d.existingIndexes(tables)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, table := range tables {
+		for _, column := range expected[table] {
+			if !columns[table+"."+column] {
+				missing = append(missing, fmt.Sprintf("столбец %s.%s", table, column))
+			}
+		}
+	}
+	for _, table := range sortedKeys(expectedIndexes) {
+		for _, index := range expectedIndexes[table] {
+			if !indexes[table+"."+index] {
+				missing = append(missing, fmt.Sprintf("индекс %s на таблице %s", index, table))
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("схема базы данных не соответствует ожидаемой, отсутствуют: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// tableList возвращает имена таблиц сервиса для условия IN запроса
+func tableList(tables []string) string {
+	quoted := make([]string, 0, len(tables))
+	for _, table := range tables {
+		quoted = append(quoted, "'"+table+"'")
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// existingColumns возвращает множество существующих столбцов указанных таблиц в виде "Таблица.Столбец"
+func (d *DBManager) existingColumns(tables []string) (map[string]bool, error) {
+	rows, err := d.DB.Query(`
+	SELECT TABLE_NAME, COLUMN_NAME
+	FROM INFORMATION_SCHEMA.COLUMNS
+	WHERE TABLE_NAME IN (` + tableList(tables) + `)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении столбцов таблиц: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении столбцов таблиц: %w", err)
+		}
+		result[table+"."+column] = true
+	}
+
+	return result, rows.Err()
+}
+
+// existingIndexes возвращает множество существующих индексов указанных таблиц в виде "Таблица.Индекс"
+func (d *DBManager) existingIndexes(tables []string) (map[string]bool, error) {
+	rows, err := d.DB.Query(`
+	SELECT OBJECT_NAME(object_id), name
+	FROM sys.indexes
+	WHERE name IS NOT NULL AND OBJECT_NAME(object_id) IN (` + tableList(tables) + `)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении индексов таблиц: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]bool)
+	for rows.Next() {
+		var table, index string
+		if err := rows.Scan(&table, &index); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении индексов таблиц: %w", err)
+		}
+		result[table+"."+index] = true
+	}
+
+	return result, rows.Err()
+}
+
+// sortedKeys возвращает отсортированные ключи карты для стабильного порядка в сообщениях об ошибках
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"weatherInTheField/pkg/api"
+)
+
+// stationsPerStatement ограничивает количество станций в одном запросе MERGE:
+// на станцию приходится 6 параметров, а SQL Server допускает не более 2100 параметров в запросе
+const stationsPerStatement = 300
+
+// defaultStationTimeZone - часовой пояс станций, для которых он не указан в STATION_TIMEZONES
+const defaultStationTimeZone = "UTC"
+
+// stationFields содержит сохраняемые в таблицу Stations поля станции
+type stationFields struct {
+	Name      string
+	Label     string
+	Latitude  float64
+	Longitude float64
+	TimeZone  string
+}
+
+// stationCache хранит поля станций, сохраненные в базу данных в предыдущих циклах
+type stationCache struct {
+	mu       sync.Mutex
+	stations map[string]stationFields
+}
+
+// changed возвращает станции, данные которых отличаются от сохраненных ранее
+func (c *stationCache) changed(devices []api.Device, fieldsOf func(api.Device) stationFields) []api.Device {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var result []api.Device
+	for _, device := range devices {
+		if cached, ok := c.stations[device.ID]; !ok || cached != fieldsOf(device) {
+			result = append(result, device)
+		}
+	}
+	return result
+}
+
+// forget удаляет станцию из кэша, чтобы при следующем сохранении она была записана заново
+func (c *stationCache) forget(stationID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.stations, stationID)
+}
+
+// update запоминает сохраненные данные станций
+func (c *stationCache) update(devices []api.Device, fieldsOf func(api.Device) stationFields) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stations == nil {
+		c.stations = make(map[string]stationFields, len(devices))
+	}
+	for _, device := range devices {
+		c.stations[device.ID] = fieldsOf(device)
+	}
+}
+
+// stationFields возвращает сохраняемые поля станции
+func (d *DBManager) stationFields(device api.Device) stationFields {
+	return stationFields{
+		Name:      device.Name,
+		Label:     device.Label,
+		Latitude:  device.Latitude,
+		Longitude: device.Longitude,
+		TimeZone:  d.stationTimeZone(device.ID),
+	}
+}
+
+// stationTimeZone возвращает часовой пояс станции из STATION_TIMEZONES или UTC, если он не указан
+func (d *DBManager) stationTimeZone(stationID string) string {
+	if name, ok := d.Config.StationTimeZones[stationID]; ok {
+		return name
+	}
+	return defaultStationTimeZone
+}
+
+// StoreStations сохраняет информацию о метеостанциях в базу данных.
+// Сохраняются только станции, данные которых изменились с предыдущего вызова; если изменений нет,
+// запись не выполняется. Станции сохраняются одним запросом MERGE на пакет станций
+func (d *DBManager) StoreStations(devices []api.Device) error {
+	changed := d.stationCache.changed(devices, d.stationFields)
+	if len(changed) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	err := d.withRetryTx(ctx, func(tx *sql.Tx) error {
+		for start := 0; start < len(changed); start += stationsPerStatement {
+			end := start + stationsPerStatement
+			if end > len(changed) {
+				end = len(changed)
+			}
+
+			if err := d.mergeStations(ctx, tx, changed[start:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	d.stationCache.update(changed, d.stationFields)
+	d.logger.Printf("Сохранены данные метеостанций: %d из %d (остальные не изменились)", len(changed), len(devices))
+	return nil
+}
+
+// mergeStations сохраняет пакет станций одним запросом MERGE
+func (d *DBManager) mergeStations(ctx context.Context, tx *sql.Tx, devices []api.Device) error {
+	values := make([]string, 0, len(devices))
+	args := make([]interface{}, 0, len(devices)*6)
+	for i, device := range devices {
+		values = append(values, fmt.Sprintf("(@ID%[1]d, @Name%[1]d, @Label%[1]d, @Latitude%[1]d, @Longitude%[1]d, @TimeZone%[1]d)", i))
+		args = append(args,
+			sql.Named(fmt.Sprintf("ID%d", i), device.ID),
+			sql.Named(fmt.Sprintf("Name%d", i), device.Name),
+			sql.Named(fmt.Sprintf("Label%d", i), device.Label),
+			sql.Named(fmt.Sprintf("Latitude%d", i), device.Latitude),
+			sql.Named(fmt.Sprintf("Longitude%d", i), device.Longitude),
+			sql.Named(fmt.Sprintf("TimeZone%d", i), d.stationTimeZone(device.ID)),
+		)
+	}
+
+	query := `
+	MERGE INTO Stations AS target
+	USING (VALUES ` + strings.Join(values, ", ") + `) AS source (ID, Name, Label, Latitude, Longitude, TimeZone)
+	ON target.ID = source.ID
+	WHEN MATCHED THEN
+		UPDATE SET
+			Name = source.Name,
+			Label = source.Label,
+			Latitude = source.Latitude,
+			Longitude = source.Longitude,
+			TimeZone = source.TimeZone,
+			LastUpdate = GETDATE()
+	WHEN NOT MATCHED THEN
+		INSERT (ID, Name, Label, Latitude, Longitude, TimeZone, LastUpdate)
+		VALUES (source.ID, source.Name, source.Label, source.Latitude, source.Longitude, source.TimeZone, GETDATE());
+	`
+
+	err := d.withStatementTimeout(ctx, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении метеостанций: %w", err)
+	}
+	return nil
+}
+
+// StationRecord представляет собой сохраненную запись о метеостанции
+type StationRecord struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Label      string    `json:"label"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	TimeZone   string    `json:"time_zone"`
+	LastUpdate time.Time `json:"last_update"`
+}
+
+// GetStationsDetailed получает все станции из базы данных со всеми сохраненными полями
+func (d *DBManager) GetStationsDetailed() ([]StationRecord, error) {
+	rows, err := d.DB.Query(`
+	SELECT ID, Name, Label, Latitude, Longitude, TimeZone, LastUpdate
+	FROM Stations
+	ORDER BY ID
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при запросе станций: %w", err)
+	}
+	defer rows.Close()
+
+	var stations []StationRecord
+	for rows.Next() {
+		var station StationRecord
+		var label sql.NullString
+		var latitude, longitude sql.NullFloat64
+		var timeZone sql.NullString
+		var lastUpdate sql.NullTime
+		if err := rows.Scan(&station.ID, &station.Name, &label, &latitude, &longitude, &timeZone, &lastUpdate); err != nil {
+			return nil, fmt.Errorf("ошибка при сканировании станции: %w", err)
+		}
+		station.Label = label.String
+		station.Latitude = latitude.Float64
+		station.Longitude = longitude.Float64
+		station.TimeZone = defaultStationTimeZone
+		if timeZone.Valid && timeZone.String != "" {
+			station.TimeZone = timeZone.String
+		}
+		station.LastUpdate = lastUpdate.Time
+		stations = append(stations, station)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации результатов: %w", err)
+	}
+
+	return stations, nil
+}
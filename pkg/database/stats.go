@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DBStats содержит сведения о размере хранилища телеметрии
+type DBStats struct {
+	TotalRows      int64            `json:"total_rows"`
+	StationRows    map[string]int64 `json:"station_rows"`
+	EarliestDate   *time.Time       `json:"earliest_date,omitempty"`
+	LatestDate     *time.Time       `json:"latest_date,omitempty"`
+	TableSizeBytes int64            `json:"table_size_bytes"`
+}
+
+// StatsReporter описывает хранилище, которое может сообщить статистику по сохраненным данным
+type StatsReporter interface {
+	Stats() (DBStats, error)
+}
+
+// Проверка, что DBManager реализует интерфейс StatsReporter
+var _ StatsReporter = (*DBManager)(nil)
+
+// Stats возвращает количество записей телеметрии (всего и по станциям), самую раннюю и самую позднюю
+// дату измерений и примерный размер таблицы Telemetry с индексами
+func (d *DBManager) Stats() (DBStats, error) {
+	var stats DBStats
+	err := d.withReconnect(context.Background(), func() error {
+		var err error
+		stats, err = d.queryStats()
+		return err
+	})
+	return stats, err
+}
+
+// queryStats выполняет агрегирующие запросы статистики по таблице Telemetry
+func (d *DBManager) queryStats() (DBStats, error) {
+	stats := DBStats{StationRows: make(map[string]int64)}
+
+	rows, err := d.DB.Query(`
+	SELECT StationID, COUNT_BIG(*), MIN(DateValue), MAX(DateValue)
+	FROM Telemetry
+	GROUP BY StationID
+	`)
+	if err != nil {
+		return DBStats{}, fmt.Errorf("ошибка при запросе количества записей телеметрии: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stationID string
+		var count int64
+		var earliest, latest time.Time
+		if err := rows.Scan(&stationID, &count, &earliest, &latest); err != nil {
+			return DBStats{}, fmt.Errorf("ошибка при сканировании статистики станции: %w", err)
+		}
+
+		stats.StationRows[stationID] = count
+		stats.TotalRows += count
+		if stats.EarliestDate == nil || earliest.Before(*stats.EarliestDate) {
+			stats.EarliestDate = &earliest
+		}
+		if stats.LatestDate == nil || latest.After(*stats.LatestDate) {
+			stats.LatestDate = &latest
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return DBStats{}, fmt.Errorf("ошибка при итерации результатов: %w", err)
+	}
+
+	// Размер таблицы с индексами по выделенным страницам (8 КБ каждая)
+	var size sql.NullInt64
+	err = d.DB.QueryRow(`
+	SELECT SUM(a.total_pages) * 8192
+	FROM sys.partitions p
+	JOIN sys.allocation_units a ON a.container_id = p.partition_id
+	WHERE p.object_id = OBJECT_ID('Telemetry')
+	`).Scan(&size)
+	if err != nil {
+		return DBStats{}, fmt.Errorf("ошибка при запросе размера таблицы Telemetry: %w", err)
+	}
+	stats.TableSizeBytes = size.Int64
+
+	return stats, nil
+}
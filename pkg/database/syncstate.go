@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SyncRange - непрерывный интервал времени (в миллисекундах), за который данные датчика полностью получены и сохранены
+type SyncRange struct {
+	From int64
+	To   int64
+}
+
+// Covers сообщает, входит ли интервал [from, to] в синхронизированный интервал
+func (r SyncRange) Covers(from, to int64) bool {
+	return r.From <= from && to <= r.To
+}
+
+// SyncStateStore описывает хранилище, которое запоминает синхронизированные интервалы датчиков,
+// чтобы после перезапуска не запрашивать повторно уже сохраненные периоды
+type SyncStateStore interface {
+	GetSyncState(ctx context.Context, stationID string) (map[string]SyncRange, error)
+	UpdateSyncState(ctx context.Context, stationID string, sensorKeys []string, from, to int64) error
+}
+
+// Проверка, что DBManager реализует интерфейс SyncStateStore
+var _ SyncStateStore = (*DBManager)(nil)
+
+// createSyncStateTable создает таблицу синхронизированных интервалов датчиков
+func (d *DBManager) createSyncStateTable() error {
+	_, err := d.DB.Exec(`
+	IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='SyncState' AND xtype='U')
+	CREATE TABLE SyncState (
+		StationID NVARCHAR(100) NOT NULL,
+		SensorKey NVARCHAR(100) NOT NULL,
+		SyncedFrom BIGINT NOT NULL,
+		SyncedTo BIGINT NOT NULL,
+		UpdatedAt DATETIME2 NOT NULL,
+		CONSTRAINT PK_SyncState PRIMARY KEY (StationID, SensorKey)
+	)
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании таблицы SyncState: %w", err)
+	}
+
+	return nil
+}
+
+// GetSyncState возвращает синхронизированные интервалы всех датчиков станции
+func (d *DBManager) GetSyncState(ctx context.Context, stationID string) (map[string]SyncRange, error) {
+	var state map[string]SyncRange
+	err := d.withStatementTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		state, err = d.querySyncState(ctx, stationID)
+		return err
+	})
+	return state, err
+}
+
+// querySyncState выполняет запрос синхронизированных интервалов датчиков станции
+func (d *DBManager) querySyncState(ctx context.Context, stationID string) (map[string]SyncRange, error) {
+	rows, err := d.DB.QueryContext(ctx, `
+	SELECT SensorKey, SyncedFrom, SyncedTo
+	FROM SyncState
+	WHERE StationID = @StationID
+	`, sql.Named("StationID", stationID))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении состояния синхронизации: %w", err)
+	}
+	defer rows.Close()
+
+	state := make(map[string]SyncRange)
+	for rows.Next() {
+		var sensorKey string
+		var r SyncRange
+		if err := rows.Scan(&sensorKey, &r.From, &r.To); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении состояния синхронизации: %w", err)
+		}
+		state[sensorKey] = r
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при чтении состояния синхронизации: %w", err)
+	}
+
+	return state, nil
+}
+
+// UpdateSyncState отмечает интервал [from, to] как синхронизированный для датчиков станции.
+// Интервал объединяется с сохраненным, только если они пересекаются или соприкасаются,
+// чтобы в синхронизированный интервал не попали периоды, которые не удалось получить
+func (d *DBManager) UpdateSyncState(ctx context.Context, stationID string, sensorKeys []string, from, to int64) error {
+	if len(sensorKeys) == 0 || from > to {
+		return nil
+	}
+
+	return d.withRetryTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+		MERGE SyncState AS target
+		USING (SELECT @StationID AS StationID, @SensorKey AS SensorKey) AS source
+		ON target.StationID = source.StationID AND target.SensorKey = source.SensorKey
+		WHEN MATCHED AND @From <= target.SyncedTo + 1 AND @To >= target.SyncedFrom - 1 THEN
+			UPDATE SET
+				SyncedFrom = CASE WHEN @From < target.SyncedFrom THEN @From ELSE target.SyncedFrom END,
+				SyncedTo = CASE WHEN @To > target.SyncedTo THEN @To ELSE target.SyncedTo END,
+				UpdatedAt = GETDATE()
+		WHEN NOT MATCHED THEN
+			INSERT (StationID, SensorKey, SyncedFrom, SyncedTo, UpdatedAt)
+			VALUES (@StationID, @SensorKey, @From, @To, GETDATE());
+		`)
+		if err != nil {
+			return fmt.Errorf("ошибка при подготовке запроса: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, sensorKey := range sensorKeys {
+			err := d.withStatementTimeout(ctx, func(ctx context.Context) error {
+				_, err := stmt.ExecContext(ctx,
+					sql.Named("StationID", stationID),
+					sql.Named("SensorKey", sensorKey),
+					sql.Named("From", from),
+					sql.Named("To", to),
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("ошибка при обновлении состояния синхронизации датчика %s: %w", sensorKey, err)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// windowsPerQuery ограничивает количество окон в одном запросе проверки (по параметру на окно)
+const windowsPerQuery = 500
+
+// SyncWindow - период запроса телеметрии датчика, данные за который полностью получены и сохранены
+type SyncWindow struct {
+	SensorKey string
+	From      int64
+	To        int64
+}
+
+// SyncWindowStore описывает хранилище, которое запоминает завершенные периоды запросов по хэшу
+// (станция, датчик, начало, конец), чтобы после перезапуска не запрашивать точно такой же период повторно,
+// даже если он не примыкает к непрерывному синхронизированному интервалу SyncState
+type SyncWindowStore interface {
+	CompletedWindows(ctx context.Context, stationID string, windows []SyncWindow) (map[SyncWindow]bool, error)
+	MarkWindowsCompleted(ctx context.Context, stationID string, windows []SyncWindow) error
+}
+
+// Проверка, что DBManager реализует интерфейс SyncWindowStore
+var _ SyncWindowStore = (*DBManager)(nil)
+
+// windowHash вычисляет хэш SHA-256 периода запроса датчика станции
+func windowHash(stationID string, window SyncWindow) []byte {
+	h := sha256.New()
+	h.Write([]byte(stationID))
+	h.Write([]byte{0})
+	h.Write([]byte(window.SensorKey))
+	h.Write([]byte{0})
+	binary.Write(h, binary.BigEndian, window.From)
+	binary.Write(h, binary.BigEndian, window.To)
+	return h.Sum(nil)
+}
+
+// createSyncWindowsTable создает таблицу завершенных периодов запросов
+func (d *DBManager) createSyncWindowsTable() error {
+	_, err := d.DB.Exec(`
+	IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='SyncWindows' AND xtype='U')
+	CREATE TABLE SyncWindows (
+		WindowHash BINARY(32) NOT NULL,
+		StationID NVARCHAR(100) NOT NULL,
+		SensorKey NVARCHAR(100) NOT NULL,
+		TsFrom BIGINT NOT NULL,
+		TsTo BIGINT NOT NULL,
+		CompletedAt DATETIME2 NOT NULL,
+		CONSTRAINT PK_SyncWindows PRIMARY KEY (WindowHash)
+	)
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании таблицы SyncWindows: %w", err)
+	}
+
+	return nil
+}
+
+// CompletedWindows возвращает периоды из windows, которые уже отмечены как завершенные
+func (d *DBManager) CompletedWindows(ctx context.Context, stationID string, windows []SyncWindow) (map[SyncWindow]bool, error) {
+	byHash := make(map[string]SyncWindow, len(windows))
+	for _, window := range windows {
+		byHash[string(windowHash(stationID, window))] = window
+	}
+
+	completed := make(map[SyncWindow]bool)
+	for start := 0; start < len(windows); start += windowsPerQuery {
+		end := start + windowsPerQuery
+		if end > len(windows) {
+			end = len(windows)
+		}
+
+		placeholders := make([]string, 0, end-start)
+		args := make([]interface{}, 0, end-start)
+		for i, window := range windows[start:end] {
+			placeholders = append(placeholders, fmt.Sprintf("@H%d", i))
+			args = append(args, sql.Named(fmt.Sprintf("H%d", i), windowHash(stationID, window)))
+		}
+
+		err := d.withStatementTimeout(ctx, func(ctx context.Context) error {
+			rows, err := d.DB.QueryContext(ctx, `
+			SELECT WindowHash FROM SyncWindows WHERE WindowHash IN (`+strings.Join(placeholders, ", ")+`)
+			`, args...)
+			if err != nil {
+				return fmt.Errorf("ошибка при проверке завершенных периодов: %w", err)
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var hash []byte
+				if err := rows.Scan(&hash); err != nil {
+					return fmt.Errorf("ошибка при чтении завершенных периодов: %w", err)
+				}
+				if window, ok := byHash[string(hash)]; ok {
+					completed[window] = true
+				}
+			}
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("ошибка при чтении завершенных периодов: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return completed, nil
+}
+
+// MarkWindowsCompleted отмечает периоды запросов датчиков станции как завершенные
+func (d *DBManager) MarkWindowsCompleted(ctx context.Context, stationID string, windows []SyncWindow) error {
+	if len(windows) == 0 {
+		return nil
+	}
+
+	return d.withRetryTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `
+		IF NOT EXISTS (SELECT 1 FROM SyncWindows WHERE WindowHash = @WindowHash)
+		INSERT INTO SyncWindows (WindowHash, StationID, SensorKey, TsFrom, TsTo, CompletedAt)
+		VALUES (@WindowHash, @StationID, @SensorKey, @TsFrom, @TsTo, GETDATE())
+		`)
+		if err != nil {
+			return fmt.Errorf("ошибка при подготовке запроса: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, window := range windows {
+			err := d.withStatementTimeout(ctx, func(ctx context.Context) error {
+				_, err := stmt.ExecContext(ctx,
+					sql.Named("WindowHash", windowHash(stationID, window)),
+					sql.Named("StationID", stationID),
+					sql.Named("SensorKey", window.SensorKey),
+					sql.Named("TsFrom", window.From),
+					sql.Named("TsTo", window.To),
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("ошибка при сохранении завершенного периода датчика %s: %w", window.SensorKey, err)
+			}
+		}
+
+		return nil
+	})
+}
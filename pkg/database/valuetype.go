@@ -0,0 +1,63 @@
+package database
+
+import (
+	"database/sql"
+	"math"
+	"strconv"
+	"strings"
+
+	"weatherInTheField/pkg/config"
+)
+
+// Типы столбца Value таблицы Telemetry
+const (
+	floatValueType   = "FLOAT"
+	decimalValueType = "DECIMAL(18,6)"
+
+	// decimalScale - количество знаков после запятой столбца DECIMAL
+	decimalScale = 6
+	// maxDecimalValue - граница модуля значений, помещающихся в DECIMAL(18,6)
+	maxDecimalValue = 1e12
+)
+
+// valueColumnType возвращает тип столбца Value, заданный параметром VALUE_TYPE
+func (d *DBManager) valueColumnType() string {
+	if d.Config.ValueType == config.ValueTypeDecimal {
+		return decimalValueType
+	}
+	return floatValueType
+}
+
+// valueParam возвращает параметр запроса для числового значения. Для столбца DECIMAL значение
+// передается строкой, округленной до decimalScale знаков, чтобы сервер не преобразовывал его
+// через двоичное представление FLOAT. Значения, которые не помещаются в DECIMAL, не сохраняются (false)
+func (d *DBManager) valueParam(value float64) (interface{}, bool) {
+	if d.Config.ValueType != config.ValueTypeDecimal {
+		return sql.NullFloat64{Float64: value, Valid: true}, true
+	}
+	if math.Abs(value) >= maxDecimalValue {
+		return nil, false
+	}
+	return sql.NullString{String: strconv.FormatFloat(value, 'f', decimalScale, 64), Valid: true}, true
+}
+
+// checkValueColumnType выводит предупреждение, если тип столбца Value существующей таблицы
+// не совпадает с VALUE_TYPE. Тип столбца автоматически не изменяется: для больших таблиц
+// это долгая блокирующая операция
+func (d *DBManager) checkValueColumnType() {
+	var dataType string
+	err := d.DB.QueryRow(`
+	SELECT DATA_TYPE FROM INFORMATION_SCHEMA.COLUMNS
+	WHERE TABLE_NAME = 'Telemetry' AND COLUMN_NAME = 'Value'
+	`).Scan(&dataType)
+	if err != nil {
+		d.logger.Printf("Не удалось проверить тип столбца Telemetry.Value: %v", err)
+		return
+	}
+
+	expected := strings.ToLower(d.Config.ValueType)
+	if dataType != expected {
+		d.logger.Printf("Предупреждение: столбец Telemetry.Value имеет тип %s, а VALUE_TYPE=%s; тип существующей таблицы не изменяется",
+			dataType, expected)
+	}
+}
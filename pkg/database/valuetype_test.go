@@ -0,0 +1,41 @@
+package database
+
+import (
+	"testing"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/config"
+)
+
+func TestStoreTelemetryDecimalValue(t *testing.T) {
+	var stored telemetryRows
+	d := newTestManager(t, &fakeDB{exec: stored.exec})
+	d.Config.ValueType = config.ValueTypeDecimal
+
+	// 0.1+0.2 не равно 0.3 в float64, но в DECIMAL(18,6) сохраняется точно
+	a, b := 0.1, 0.2
+	err := d.StoreTelemetry("st-1", map[string][]api.TelemetryPoint{
+		"rainfall": {{Ts: 1000, Value: a + b}},
+	})
+	if err != nil {
+		t.Fatalf("ошибка при сохранении телеметрии: %v", err)
+	}
+
+	if len(stored.rows) != 1 {
+		t.Fatalf("сохранено строк %d, ожидалась 1", len(stored.rows))
+	}
+	if value := stored.rows[0]["Value"]; value != "0.300000" {
+		t.Errorf("Value = %#v, ожидалось \"0.300000\"", value)
+	}
+}
+
+func TestValueParamDecimalOutOfRange(t *testing.T) {
+	d := &DBManager{Config: &config.Config{ValueType: config.ValueTypeDecimal}}
+
+	if _, ok := d.valueParam(1e12); ok {
+		t.Error("значение 1e12 принято, хотя не помещается в DECIMAL(18,6)")
+	}
+	if _, ok := d.valueParam(-123456.5); !ok {
+		t.Error("значение -123456.5 не принято")
+	}
+}
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/config"
+)
+
+// wideBatchSize - количество строк широкой таблицы, сохраняемых в одной транзакции
+const wideBatchSize = 200
+
+// wideColumnPattern - допустимые имена столбцов датчиков в таблице TelemetryWide
+var wideColumnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// wideReservedColumns - служебные столбцы TelemetryWide, которые не могут быть ключами датчиков
+var wideReservedColumns = map[string]bool{
+	"stationid": true,
+	"timestamp": true,
+	"datevalue": true,
+}
+
+// WideTelemetryStore описывает хранилище, которое дополнительно сохраняет телеметрию
+// в широкую таблицу (один столбец на датчик)
+type WideTelemetryStore interface {
+	StoreTelemetryWide(ctx context.Context, deviceID string, data map[string][]api.TelemetryPoint) error
+}
+
+// Проверка, что DBManager реализует интерфейс WideTelemetryStore
+var _ WideTelemetryStore = (*DBManager)(nil)
+
+// WithWideTable включает сохранение телеметрии в таблицу TelemetryWide со столбцами
+// для указанных ключей датчиков
+func WithWideTable(sensorKeys []string) Option {
+	return func(d *DBManager) {
+		d.wideColumns = append([]string(nil), sensorKeys...)
+	}
+}
+
+// validateWideColumns проверяет, что ключи датчиков можно использовать как имена столбцов
+func validateWideColumns(columns []string) error {
+	for _, column := range columns {
+		if !wideColumnPattern.MatchString(column) || wideReservedColumns[strings.ToLower(column)] {
+			return fmt.Errorf("ключ датчика %q нельзя использовать как столбец таблицы TelemetryWide", column)
+		}
+	}
+	return nil
+}
+
+// createWideTable создает таблицу TelemetryWide и добавляет отсутствующие столбцы датчиков
+func (d *DBManager) createWideTable() error {
+	if err := validateWideColumns(d.wideColumns); err != nil {
+		return err
+	}
+
+	_, err := d.DB.Exec(`
+	IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='TelemetryWide' AND xtype='U')
+	CREATE TABLE TelemetryWide (
+		StationID NVARCHAR(100) NOT NULL,
+		Timestamp BIGINT NOT NULL,
+		DateValue DATETIME2 NOT NULL,
+		CONSTRAINT PK_TelemetryWide PRIMARY KEY (StationID, Timestamp),
+		CONSTRAINT FK_TelemetryWide_Stations FOREIGN KEY (StationID) REFERENCES Stations(ID)
+	)
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании таблицы TelemetryWide: %w", err)
+	}
+
+	// Столбцы датчиков добавляются по текущему набору ключей, уже существующие не изменяются
+	for _, column := range d.wideColumns {
+		_, err := d.DB.Exec(`
+		IF COL_LENGTH('TelemetryWide', @Column) IS NULL
+		ALTER TABLE TelemetryWide ADD [`+column+`] FLOAT
+		`, sql.Named("Column", column))
+		if err != nil {
+			return fmt.Errorf("ошибка при добавлении столбца %s в таблицу TelemetryWide: %w", column, err)
+		}
+	}
+
+	return nil
+}
+
+// wideRow - значения датчиков станции на один момент времени
+type wideRow struct {
+	ts     int64
+	values map[string]float64
+}
+
+// StoreTelemetryWide сохраняет числовые значения телеметрии в таблицу TelemetryWide, по строке
+// на каждый момент времени. Значения датчиков, для которых нет столбца, и нечисловые значения пропускаются.
+// Существующие строки дополняются: значения, отсутствующие в данных, не затираются.
+// Если широкая таблица не включена, ничего не делает
+func (d *DBManager) StoreTelemetryWide(ctx context.Context, deviceID string, data map[string][]api.TelemetryPoint) error {
+	if len(d.wideColumns) == 0 {
+		return nil
+	}
+
+	rows, skipped := d.wideRows(data)
+	if skipped > 0 {
+		d.logger.Printf("Для устройства %s в таблицу TelemetryWide не сохранено нечисловых значений: %d", deviceID, skipped)
+	}
+
+	for start := 0; start < len(rows); start += wideBatchSize {
+		end := start + wideBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		if err := d.storeWideBatch(ctx, deviceID, rows[start:end]); err != nil {
+			return fmt.Errorf("ошибка при сохранении телеметрии в таблицу TelemetryWide: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// wideRows группирует числовые значения датчиков по времени измерения.
+// Возвращает строки, упорядоченные по времени, и количество пропущенных нечисловых значений
+func (d *DBManager) wideRows(data map[string][]api.TelemetryPoint) ([]wideRow, int) {
+	byTs := make(map[int64]map[string]float64)
+	skipped := 0
+	for _, column := range d.wideColumns {
+		for _, point := range data[column] {
+			value, ok := point.Float()
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				skipped++
+				continue
+			}
+
+			values, ok := byTs[point.Ts]
+			if !ok {
+				values = make(map[string]float64)
+				byTs[point.Ts] = values
+			}
+			values[column] = value
+		}
+	}
+
+	rows := make([]wideRow, 0, len(byTs))
+	for ts, values := range byTs {
+		rows = append(rows, wideRow{ts: ts, values: values})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ts < rows[j].ts })
+
+	return rows, skipped
+}
+
+// storeWideBatch сохраняет пакет строк широкой таблицы в одной транзакции
+func (d *DBManager) storeWideBatch(ctx context.Context, deviceID string, rows []wideRow) error {
+	updates := make([]string, 0, len(d.wideColumns))
+	columns := make([]string, 0, len(d.wideColumns))
+	params := make([]string, 0, len(d.wideColumns))
+	for i, column := range d.wideColumns {
+		// При CONFLICT_POLICY=skip заполняются только пустые столбцы, записанные значения сохраняются
+		if d.Config.ConflictPolicy == config.ConflictSkip {
+			updates = append(updates, fmt.Sprintf("[%s] = COALESCE(target.[%s], @V%d)", column, column, i))
+		} else {
+			updates = append(updates, fmt.Sprintf("[%s] = COALESCE(@V%d, target.[%s])", column, i, column))
+		}
+		columns = append(columns, "["+column+"]")
+		params = append(params, fmt.Sprintf("@V%d", i))
+	}
+
+	query := `
+	MERGE TelemetryWide WITH (HOLDLOCK) AS target
+	USING (SELECT @StationID AS StationID, @Timestamp AS Timestamp) AS source
+	ON target.StationID = source.StationID AND target.Timestamp = source.Timestamp
+	WHEN MATCHED THEN
+		UPDATE SET ` + strings.Join(updates, ", ") + `
+	WHEN NOT MATCHED THEN
+		INSERT (StationID, Timestamp, DateValue, ` + strings.Join(columns, ", ") + `)
+		VALUES (@StationID, @Timestamp, @DateValue, ` + strings.Join(params, ", ") + `);
+	`
+
+	return d.withRetryTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("ошибка при подготовке запроса: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, row := range rows {
+			args := []interface{}{
+				sql.Named("StationID", deviceID),
+				sql.Named("Timestamp", row.ts),
+				sql.Named("DateValue", DateValueFromTimestamp(row.ts)),
+			}
+			for i, column := range d.wideColumns {
+				value, ok := row.values[column]
+				args = append(args, sql.Named(fmt.Sprintf("V%d", i), sql.NullFloat64{Float64: value, Valid: ok}))
+			}
+
+			err := d.withStatementTimeout(ctx, func(ctx context.Context) error {
+				_, err := stmt.ExecContext(ctx, args...)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("ошибка при вставке строки: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
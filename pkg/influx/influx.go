@@ -0,0 +1,194 @@
+package influx
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/database"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	influxapi "github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// Имена измерений InfluxDB
+const (
+	telemetryMeasurement = "telemetry"
+	stationsMeasurement  = "stations"
+)
+
+// Проверка, что InfluxSink реализует интерфейсы TelemetryStore и ContextTelemetrySink
+var (
+	_ database.TelemetryStore       = (*InfluxSink)(nil)
+	_ database.ContextTelemetrySink = (*InfluxSink)(nil)
+)
+
+// InfluxSink сохраняет данные метеостанций в InfluxDB 2.x
+type InfluxSink struct {
+	Client influxdb2.Client
+	Bucket string
+	Org    string
+
+	writeAPI influxapi.WriteAPIBlocking
+	queryAPI influxapi.QueryAPI
+}
+
+// NewInfluxSink создает подключение к InfluxDB и проверяет его доступность
+func NewInfluxSink(cfg *config.Config) (*InfluxSink, error) {
+	client := influxdb2.NewClient(cfg.InfluxURL, cfg.InfluxToken)
+
+	// Проверка соединения
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ok, err := client.Ping(ctx)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ошибка при проверке соединения с InfluxDB: %w", err)
+	}
+	if !ok {
+		client.Close()
+		return nil, fmt.Errorf("InfluxDB недоступна по адресу %s", cfg.InfluxURL)
+	}
+
+	return &InfluxSink{
+		Client:   client,
+		Bucket:   cfg.InfluxBucket,
+		Org:      cfg.InfluxOrg,
+		writeAPI: client.WriteAPIBlocking(cfg.InfluxOrg, cfg.InfluxBucket),
+		queryAPI: client.QueryAPI(cfg.InfluxOrg),
+	}, nil
+}
+
+// Close закрывает соединение с InfluxDB
+func (s *InfluxSink) Close() error {
+	s.Client.Close()
+	return nil
+}
+
+// StoreStations сохраняет информацию о метеостанциях
+func (s *InfluxSink) StoreStations(devices []api.Device) error {
+	now := time.Now()
+
+	points := make([]*write.Point, 0, len(devices))
+	for _, device := range devices {
+		points = append(points, influxdb2.NewPoint(
+			stationsMeasurement,
+			map[string]string{"station": device.ID},
+			map[string]interface{}{
+				"name":      device.Name,
+				"label":     device.Label,
+				"latitude":  device.Latitude,
+				"longitude": device.Longitude,
+			},
+			now,
+		))
+	}
+
+	if err := s.writeAPI.WritePoint(context.Background(), points...); err != nil {
+		return fmt.Errorf("ошибка при записи метеостанций в InfluxDB: %w", err)
+	}
+
+	return nil
+}
+
+// StoreTelemetry сохраняет телеметрию с тегами station и sensor и полем value
+func (s *InfluxSink) StoreTelemetry(deviceID string, data map[string][]api.TelemetryPoint) error {
+	return s.StoreTelemetryContext(context.Background(), deviceID, data)
+}
+
+// StoreTelemetryContext сохраняет телеметрию с учетом контекста
+func (s *InfluxSink) StoreTelemetryContext(ctx context.Context, deviceID string, data map[string][]api.TelemetryPoint) error {
+	var points []*write.Point
+	for sensorKey, sensorPoints := range data {
+		for _, point := range sensorPoints {
+			// Пропускаем значения, которые не могут быть преобразованы в float64, а также NaN и бесконечность
+			value, ok := point.Float()
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+
+			tags := map[string]string{"station": deviceID, "sensor": sensorKey}
+			if point.Unit != "" {
+				tags["unit"] = point.Unit
+			}
+
+			points = append(points, influxdb2.NewPoint(
+				telemetryMeasurement,
+				tags,
+				map[string]interface{}{"value": value},
+				time.UnixMilli(point.Ts),
+			))
+		}
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+
+	if err := s.writeAPI.WritePoint(ctx, points...); err != nil {
+		return fmt.Errorf("ошибка при записи телеметрии в InfluxDB: %w", err)
+	}
+
+	return nil
+}
+
+// GetLatestTimestamps получает последний timestamp (в миллисекундах) по каждому датчику станции
+func (s *InfluxSink) GetLatestTimestamps(stationID string) (map[string]int64, error) {
+	query := fmt.Sprintf(`from(bucket: %s)
+	|> range(start: 0)
+	|> filter(fn: (r) => r._measurement == %s and r.station == %s and r._field == "value")
+	|> group(columns: ["sensor"])
+	|> last()`,
+		strconv.Quote(s.Bucket), strconv.Quote(telemetryMeasurement), strconv.Quote(stationID))
+
+	result, err := s.queryAPI.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении последних timestamp из InfluxDB: %w", err)
+	}
+	defer result.Close()
+
+	timestamps := make(map[string]int64)
+	for result.Next() {
+		record := result.Record()
+		sensorKey, _ := record.ValueByKey("sensor").(string)
+		timestamps[sensorKey] = record.Time().UnixMilli()
+	}
+
+	if result.Err() != nil {
+		return nil, fmt.Errorf("ошибка при чтении результатов InfluxDB: %w", result.Err())
+	}
+
+	return timestamps, nil
+}
+
+// GetStations получает список ID всех сохраненных станций
+func (s *InfluxSink) GetStations() ([]string, error) {
+	query := fmt.Sprintf(`import "influxdata/influxdb/schema"
+schema.tagValues(bucket: %s, tag: "station", predicate: (r) => r._measurement == %s, start: 0)`,
+		strconv.Quote(s.Bucket), strconv.Quote(stationsMeasurement))
+
+	result, err := s.queryAPI.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при запросе станций из InfluxDB: %w", err)
+	}
+	defer result.Close()
+
+	var stations []string
+	for result.Next() {
+		if id, ok := result.Record().Value().(string); ok {
+			stations = append(stations, id)
+		}
+	}
+
+	if result.Err() != nil {
+		return nil, fmt.Errorf("ошибка при чтении результатов InfluxDB: %w", result.Err())
+	}
+
+	return stations, nil
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Level - уровень подробности журнала. Сообщения стандартного журнала (log.Printf) относятся к уровню info
+type Level int32
+
+// Уровни журнала в порядке возрастания важности
+const (
+	LevelDebug Level = -1
+	LevelInfo  Level = 0
+	LevelWarn  Level = 1
+	LevelError Level = 2
+)
+
+// levelNames - названия уровней в конфигурации (LOG_LEVEL)
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// String возвращает название уровня
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", int32(l))
+}
+
+// ParseLevel разбирает название уровня: debug, info, warn (warning) или error. Пустая строка - info
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("неизвестный уровень журнала %q, допустимо debug, info, warn или error", s)
+	}
+}
+
+var (
+	// Текущий уровень журнала (по умолчанию info)
+	level atomic.Int32
+
+	// Журнал сообщений с явным уровнем. Пишет в исходный вывод стандартного журнала, минуя фильтр уровня info
+	leveledMu sync.Mutex
+	leveled   = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+// SetLevel задает уровень журнала. Может вызываться во время работы (перечитывание конфигурации по SIGHUP)
+func SetLevel(l Level) {
+	level.Store(int32(l))
+}
+
+// CurrentLevel возвращает текущий уровень журнала
+func CurrentLevel() Level {
+	return Level(level.Load())
+}
+
+// Enabled сообщает, выводятся ли сообщения уровня l
+func Enabled(l Level) bool {
+	return l >= CurrentLevel()
+}
+
+// Install направляет стандартный журнал в w через фильтр: при уровне warn и error сообщения log.Printf
+// не выводятся. Сообщения Warnf, Errorf и Fatalf выводятся в w в обход фильтра
+func Install(w io.Writer) {
+	leveledMu.Lock()
+	leveled = log.New(w, "", log.Flags())
+	leveledMu.Unlock()
+
+	log.SetOutput(infoFilter{w: w})
+}
+
+// infoFilter пропускает сообщения стандартного журнала, только если выводится уровень info
+type infoFilter struct {
+	w io.Writer
+}
+
+func (f infoFilter) Write(p []byte) (int, error) {
+	if !Enabled(LevelInfo) {
+		return len(p), nil
+	}
+	return f.w.Write(p)
+}
+
+// Debugf выводит отладочное сообщение (только при LOG_LEVEL=debug)
+func Debugf(format string, args ...interface{}) {
+	output(LevelDebug, format, args...)
+}
+
+// Warnf выводит предупреждение (скрывается только при LOG_LEVEL=error)
+func Warnf(format string, args ...interface{}) {
+	output(LevelWarn, format, args...)
+}
+
+// Errorf выводит сообщение об ошибке (выводится при любом уровне)
+func Errorf(format string, args ...interface{}) {
+	output(LevelError, format, args...)
+}
+
+// Fatalf выводит сообщение об ошибке при любом уровне журнала и завершает работу с кодом 1
+func Fatalf(format string, args ...interface{}) {
+	leveledMu.Lock()
+	leveled.Output(2, fmt.Sprintf(format, args...))
+	leveledMu.Unlock()
+	os.Exit(1)
+}
+
+func output(l Level, format string, args ...interface{}) {
+	if !Enabled(l) {
+		return
+	}
+	leveledMu.Lock()
+	defer leveledMu.Unlock()
+	leveled.Output(3, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureLog направляет журнал в буфер на время теста
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	prevLevel := CurrentLevel()
+	Install(&buf)
+	t.Cleanup(func() {
+		SetLevel(prevLevel)
+		Install(os.Stderr)
+		log.SetOutput(os.Stderr)
+	})
+	return &buf
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Level
+		wantErr bool
+	}{
+		{value: "debug", want: LevelDebug},
+		{value: "", want: LevelInfo},
+		{value: "INFO", want: LevelInfo},
+		{value: "warning", want: LevelWarn},
+		{value: " error ", want: LevelError},
+		{value: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q): ошибка %v", tt.value, err)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("ParseLevel(%q) = %s, ожидалось %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	tests := []struct {
+		level Level
+		want  []string
+	}{
+		{level: LevelDebug, want: []string{"debug", "info", "warn", "error"}},
+		{level: LevelInfo, want: []string{"info", "warn", "error"}},
+		{level: LevelWarn, want: []string{"warn", "error"}},
+		{level: LevelError, want: []string{"error"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			buf := captureLog(t)
+			SetLevel(tt.level)
+
+			Debugf("debug")
+			log.Printf("info")
+			Warnf("warn")
+			Errorf("error")
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				if line != "" {
+					fields := strings.Fields(line)
+					got = append(got, fields[len(fields)-1])
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("выведено %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallKeepsWriter(t *testing.T) {
+	captureLog(t)
+	Install(io.Discard)
+	SetLevel(LevelError)
+
+	// Запись в отфильтрованный журнал считается успешной
+	if n, err := log.Writer().Write([]byte("info\n")); n != 5 || err != nil {
+		t.Errorf("Write = %d, %v, ожидалось 5, nil", n, err)
+	}
+}
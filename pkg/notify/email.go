@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// smtpTimeout ограничивает время отправки одного письма
+const smtpTimeout = 30 * time.Second
+
+// EmailNotifier отправляет оповещения по электронной почте через SMTP сервер
+type EmailNotifier struct {
+	Addr     string // адрес SMTP сервера в формате хост:порт
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Notify отправляет письмо с текстом оповещения. Если указано имя пользователя,
+// используется аутентификация PLAIN (net/smtp допускает ее только через TLS или на localhost)
+func (e *EmailNotifier) Notify(ctx context.Context, event Event) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.Addr)
+		if err != nil {
+			return fmt.Errorf("некорректный адрес SMTP сервера %q: %w", e.Addr, err)
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+
+	text := event.Text()
+	msg := strings.Join([]string{
+		"From: " + e.From,
+		"To: " + strings.Join(e.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", "weatherservice "+text),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		text,
+		"",
+	}, "\r\n")
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	// smtp.SendMail не принимает контекст, поэтому отправка выполняется в горутине
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(e.Addr, auth, e.From, e.To, []byte(msg))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("ошибка при отправке письма: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("ошибка при отправке письма: %w", ctx.Err())
+	}
+}
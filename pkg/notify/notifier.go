@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"weatherInTheField/pkg/logging"
+)
+
+// EventType - тип события, о котором отправляется оповещение
+type EventType string
+
+// Типы событий оповещений
+const (
+	// Цикл сбора данных завершился ошибкой (например, не получен список устройств)
+	EventCycleFailed EventType = "cycle_failed"
+	// Телеметрия станции не получена в нескольких циклах подряд
+	EventDeviceFailed EventType = "device_failed"
+	// База данных недоступна
+	EventDBUnavailable EventType = "db_unavailable"
+	// Станция переведена на редкий опрос после ошибок в нескольких циклах подряд
+	EventStationDemoted EventType = "station_demoted"
+)
+
+// Event представляет собой оповещение о сбое или его устранении
+type Event struct {
+	Type     EventType `json:"type"`
+	Key      string    `json:"key,omitempty"` // объект сбоя, например ID станции
+	Message  string    `json:"message"`
+	Failures int       `json:"failures"` // количество сбоев подряд
+	Resolved bool      `json:"resolved"` // сбой устранен
+	Time     time.Time `json:"time"`
+}
+
+// Notifier отправляет оповещения о сбоях сбора данных
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// NopNotifier не отправляет оповещений, используется, если оповещения не настроены
+type NopNotifier struct{}
+
+// Notify ничего не делает
+func (NopNotifier) Notify(ctx context.Context, event Event) error {
+	return nil
+}
+
+// MultiNotifier отправляет оповещение всем указанным получателям
+type MultiNotifier []Notifier
+
+// Notify отправляет оповещение всем получателям и возвращает объединенные ошибки отправки
+func (m MultiNotifier) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Text возвращает текстовое описание события для сообщений чата и писем
+func (e Event) Text() string {
+	subject := string(e.Type)
+	if e.Key != "" {
+		subject += " " + e.Key
+	}
+	if e.Resolved {
+		return fmt.Sprintf("[восстановлено] %s: %s", subject, e.Message)
+	}
+	return fmt.Sprintf("[сбой] %s (подряд: %d): %s", subject, e.Failures, e.Message)
+}
+
+// incidentKey идентифицирует отслеживаемый сбой
+type incidentKey struct {
+	eventType EventType
+	key       string
+}
+
+// incident - состояние отслеживаемого сбоя
+type incident struct {
+	failures int
+	notified bool
+}
+
+// Alerter отслеживает повторяющиеся сбои и отправляет одно оповещение на каждый сбой:
+// при достижении порога сбоев подряд и после его устранения
+type Alerter struct {
+	notifier  Notifier
+	threshold int
+
+	mu        sync.Mutex
+	incidents map[incidentKey]*incident
+}
+
+// NewAlerter создает отслеживание сбоев. Оповещение отправляется после threshold сбоев подряд
+func NewAlerter(notifier Notifier, threshold int) *Alerter {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Alerter{
+		notifier:  notifier,
+		threshold: threshold,
+		incidents: make(map[incidentKey]*incident),
+	}
+}
+
+// Failure отмечает очередной сбой. Оповещение отправляется один раз, когда количество сбоев подряд
+// достигает порога. Ошибки отправки только логируются
+func (a *Alerter) Failure(ctx context.Context, eventType EventType, key, message string) {
+	a.mu.Lock()
+	id := incidentKey{eventType: eventType, key: key}
+	state, ok := a.incidents[id]
+	if !ok {
+		state = &incident{}
+		a.incidents[id] = state
+	}
+	state.failures++
+	notify := !state.notified && state.failures >= a.threshold
+	if notify {
+		state.notified = true
+	}
+	failures := state.failures
+	a.mu.Unlock()
+
+	if notify {
+		a.send(ctx, Event{
+			Type:     eventType,
+			Key:      key,
+			Message:  message,
+			Failures: failures,
+			Time:     time.Now().UTC(),
+		})
+	}
+}
+
+// Raise сразу отправляет оповещение о сбое, о котором сборщик уже принял решение (без учета порога).
+// Повторные вызовы до устранения сбоя оповещений не отправляют, об устранении сообщает Success
+func (a *Alerter) Raise(ctx context.Context, eventType EventType, key, message string, failures int) {
+	a.mu.Lock()
+	id := incidentKey{eventType: eventType, key: key}
+	state, ok := a.incidents[id]
+	if ok && state.notified {
+		a.mu.Unlock()
+		return
+	}
+	a.incidents[id] = &incident{failures: failures, notified: true}
+	a.mu.Unlock()
+
+	a.send(ctx, Event{
+		Type:     eventType,
+		Key:      key,
+		Message:  message,
+		Failures: failures,
+		Time:     time.Now().UTC(),
+	})
+}
+
+// Success отмечает успешное выполнение. Если о сбое было отправлено оповещение, сообщает о его устранении
+func (a *Alerter) Success(ctx context.Context, eventType EventType, key string) {
+	a.mu.Lock()
+	id := incidentKey{eventType: eventType, key: key}
+	state, ok := a.incidents[id]
+	delete(a.incidents, id)
+	a.mu.Unlock()
+
+	if ok && state.notified {
+		a.send(ctx, Event{
+			Type:     eventType,
+			Key:      key,
+			Message:  fmt.Sprintf("работа восстановлена после %d сбоев подряд", state.failures),
+			Failures: state.failures,
+			Resolved: true,
+			Time:     time.Now().UTC(),
+		})
+	}
+}
+
+// send отправляет оповещение и логирует ошибку отправки.
+// Оповещение отправляется и при прерванном цикле сбора, поэтому отмена контекста не учитывается
+func (a *Alerter) send(ctx context.Context, event Event) {
+	if err := a.notifier.Notify(context.WithoutCancel(ctx), event); err != nil {
+		logging.Errorf("Ошибка при отправке оповещения %s: %v", event.Type, err)
+	}
+}
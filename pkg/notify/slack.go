@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SlackNotifier отправляет оповещения в Slack через входящий webhook
+type SlackNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewSlackNotifier создает отправителя оповещений в Slack на указанный URL webhook
+func NewSlackNotifier(url string) *SlackNotifier {
+	return &SlackNotifier{
+		URL: url,
+		Client: &http.Client{
+			Timeout: webhookTimeout,
+		},
+	}
+}
+
+// Notify отправляет текст оповещения в Slack
+func (s *SlackNotifier) Notify(ctx context.Context, event Event) error {
+	jsonData, err := json.Marshal(map[string]string{"text": event.Text()})
+	if err != nil {
+		return fmt.Errorf("ошибка при сериализации оповещения: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("ошибка при создании запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка при отправке оповещения в Slack: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Slack вернул статус %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"weatherInTheField/pkg/api"
+)
+
+// webhookTimeout ограничивает время отправки одного уведомления
+const webhookTimeout = 5 * time.Second
+
+// StationWebhook отправляет уведомления о появлении новых метеостанций
+// и о датчиках, переставших передавать данные
+type StationWebhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewStationEvent представляет собой уведомление о новой метеостанции
+type NewStationEvent struct {
+	Event     string    `json:"event"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Label     string    `json:"label"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Time      time.Time `json:"time"`
+}
+
+// SensorStaleEvent представляет собой уведомление о датчике, переставшем передавать данные
+type SensorStaleEvent struct {
+	Event     string    `json:"event"`
+	StationID string    `json:"station_id"`
+	SensorKey string    `json:"sensor_key"`
+	LastData  time.Time `json:"last_data"`
+	Time      time.Time `json:"time"`
+}
+
+// NewStationWebhook создает отправителя уведомлений на указанный URL
+func NewStationWebhook(url string) *StationWebhook {
+	return &StationWebhook{
+		URL: url,
+		Client: &http.Client{
+			Timeout: webhookTimeout,
+		},
+	}
+}
+
+// NotifyNewStation отправляет POST запрос с информацией о новой метеостанции
+func (s *StationWebhook) NotifyNewStation(ctx context.Context, device api.Device) error {
+	event := NewStationEvent{
+		Event:     "new_station",
+		ID:        device.ID,
+		Name:      device.Name,
+		Label:     device.Label,
+		Latitude:  device.Latitude,
+		Longitude: device.Longitude,
+		Time:      time.Now().UTC(),
+	}
+
+	return s.send(ctx, event)
+}
+
+// NotifySensorStale отправляет POST запрос с информацией о датчике, переставшем передавать данные
+func (s *StationWebhook) NotifySensorStale(ctx context.Context, stationID, sensorKey string, lastData time.Time) error {
+	event := SensorStaleEvent{
+		Event:     "sensor_stale",
+		StationID: stationID,
+		SensorKey: sensorKey,
+		LastData:  lastData.UTC(),
+		Time:      time.Now().UTC(),
+	}
+
+	return s.send(ctx, event)
+}
+
+// send отправляет уведомление в формате JSON
+func (s *StationWebhook) send(ctx context.Context, event interface{}) error {
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("ошибка при сериализации уведомления: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("ошибка при создании запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка при отправке уведомления: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook вернул статус %d", resp.StatusCode)
+	}
+
+	return nil
+}
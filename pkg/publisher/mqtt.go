@@ -0,0 +1,98 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/config"
+	"weatherInTheField/pkg/logging"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Publisher публикует свежие данные телеметрии во внешнюю систему
+type Publisher interface {
+	Publish(stationID string, data map[string][]api.TelemetryPoint)
+}
+
+// Проверка, что MQTTPublisher реализует интерфейс Publisher
+var _ Publisher = (*MQTTPublisher)(nil)
+
+// MQTTPublisher публикует телеметрию в MQTT брокер
+type MQTTPublisher struct {
+	Client      mqtt.Client
+	TopicPrefix string
+}
+
+// pointMessage представляет собой сообщение с одной точкой телеметрии
+type pointMessage struct {
+	Ts    int64       `json:"ts"`
+	Value interface{} `json:"value"`
+}
+
+// NewMQTTPublisher создает публикатор и запускает подключение к брокеру.
+// Подключение и переподключение выполняются в фоне, поэтому недоступность
+// брокера при запуске не останавливает сервис
+func NewMQTTPublisher(cfg *config.Config) (*MQTTPublisher, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.MqttBroker).
+		SetClientID(cfg.MqttClientID).
+		SetUsername(cfg.MqttUsername).
+		SetPassword(cfg.MqttPassword).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(10 * time.Second).
+		SetMaxReconnectInterval(time.Minute).
+		SetOrderMatters(false).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("Потеряно соединение с MQTT брокером: %v", err)
+		}).
+		SetOnConnectHandler(func(_ mqtt.Client) {
+			log.Printf("Установлено соединение с MQTT брокером %s", cfg.MqttBroker)
+		})
+
+	client := mqtt.NewClient(opts)
+
+	// При SetConnectRetry(true) токен завершится только после успешного подключения,
+	// поэтому ожидаем его ограниченное время и продолжаем работу в любом случае
+	token := client.Connect()
+	if token.WaitTimeout(5*time.Second) && token.Error() != nil {
+		return nil, fmt.Errorf("ошибка при подключении к MQTT брокеру: %w", token.Error())
+	}
+
+	return &MQTTPublisher{
+		Client:      client,
+		TopicPrefix: cfg.MqttTopicPrefix,
+	}, nil
+}
+
+// Publish публикует каждую точку телеметрии в топик <prefix>/<stationID>/<sensorKey>.
+// Не ожидает подтверждения доставки, чтобы не блокировать сбор данных
+func (p *MQTTPublisher) Publish(stationID string, data map[string][]api.TelemetryPoint) {
+	if !p.Client.IsConnectionOpen() {
+		log.Printf("MQTT брокер недоступен, данные устройства %s не опубликованы", stationID)
+		return
+	}
+
+	for sensorKey, points := range data {
+		topic := fmt.Sprintf("%s/%s/%s", p.TopicPrefix, stationID, sensorKey)
+
+		for _, point := range points {
+			payload, err := json.Marshal(pointMessage{Ts: point.Ts, Value: point.Value})
+			if err != nil {
+				logging.Errorf("Ошибка при сериализации сообщения MQTT для %s: %v", topic, err)
+				continue
+			}
+
+			p.Client.Publish(topic, 0, false, payload)
+		}
+	}
+}
+
+// Close отключается от MQTT брокера
+func (p *MQTTPublisher) Close() {
+	p.Client.Disconnect(250)
+}
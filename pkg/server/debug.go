@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"weatherInTheField/pkg/database"
+	"weatherInTheField/pkg/logging"
+)
+
+// DefaultGapIntervalMinutes - ожидаемый интервал между измерениями для отчета о пропусках,
+// если параметр interval не указан
+const DefaultGapIntervalMinutes = 15
+
+// DebugStateFunc возвращает описание текущего состояния сервиса для отладочного эндпоинта
+type DebugStateFunc func(ctx context.Context) (interface{}, error)
+
+// DebugServer предоставляет отладочный HTTP эндпоинт /debug с состоянием последнего цикла сбора.
+// Доступ защищен токеном, передаваемым в заголовке Authorization: Bearer <токен>
+type DebugServer struct {
+	Token      string
+	State      DebugStateFunc
+	HTTPServer *http.Server
+
+	// Отчет о пропусках в данных станций (nil - эндпоинт /debug/gaps недоступен)
+	gaps database.GapReporter
+}
+
+// DebugOption задает дополнительную настройку отладочного сервера
+type DebugOption func(*DebugServer)
+
+// WithGapReporter включает эндпоинт /debug/gaps с отчетом о пропусках в данных станции
+func WithGapReporter(reporter database.GapReporter) DebugOption {
+	return func(s *DebugServer) {
+		s.gaps = reporter
+	}
+}
+
+// NewDebugServer создает отладочный HTTP сервер на указанном адресе
+func NewDebugServer(addr, token string, state DebugStateFunc, opts ...DebugOption) *DebugServer {
+	s := &DebugServer{Token: token, State: state}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /debug", s.handleDebug)
+	if s.gaps != nil {
+		mux.HandleFunc("GET /debug/gaps", s.handleGaps)
+	}
+
+	s.HTTPServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+// Start запускает отладочный HTTP сервер в отдельной горутине
+func (s *DebugServer) Start() {
+	go func() {
+		log.Printf("Отладочный эндпоинт доступен по адресу %s/debug", s.HTTPServer.Addr)
+		if err := s.HTTPServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Errorf("Ошибка отладочного HTTP сервера: %v", err)
+		}
+	}()
+}
+
+// Shutdown останавливает отладочный HTTP сервер
+func (s *DebugServer) Shutdown(ctx context.Context) error {
+	return s.HTTPServer.Shutdown(ctx)
+}
+
+// handleDebug возвращает состояние сервиса в формате JSON
+func (s *DebugServer) handleDebug(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "требуется токен доступа")
+		return
+	}
+
+	state, err := s.State(r.Context())
+	if err != nil {
+		logging.Errorf("Ошибка при получении отладочного состояния: %v", err)
+		writeError(w, http.StatusInternalServerError, "ошибка при получении состояния")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, state)
+}
+
+// handleGaps возвращает пропуски в данных датчиков станции: GET /debug/gaps?station=ID&interval=15,
+// где interval - ожидаемый интервал между измерениями в минутах
+func (s *DebugServer) handleGaps(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "требуется токен доступа")
+		return
+	}
+
+	stationID := r.URL.Query().Get("station")
+	if stationID == "" {
+		writeError(w, http.StatusBadRequest, "не указан параметр station")
+		return
+	}
+
+	interval := DefaultGapIntervalMinutes
+	if raw := r.URL.Query().Get("interval"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			writeError(w, http.StatusBadRequest, "параметр interval должен быть положительным целым числом минут")
+			return
+		}
+		interval = value
+	}
+
+	gaps, err := s.gaps.GapReport(stationID, interval)
+	if err != nil {
+		logging.Errorf("Ошибка при построении отчета о пропусках станции %s: %v", stationID, err)
+		writeError(w, http.StatusInternalServerError, "ошибка при построении отчета о пропусках")
+		return
+	}
+	if gaps == nil {
+		gaps = []database.Gap{}
+	}
+
+	writeJSON(w, http.StatusOK, gaps)
+}
+
+// authorized проверяет токен доступа из заголовка Authorization
+func (s *DebugServer) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || s.Token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"weatherInTheField/pkg/database"
+	"weatherInTheField/pkg/logging"
+)
+
+// Ограничения на запросы телеметрии
+const (
+	// MaxTelemetryRange - максимальная длительность запрашиваемого периода
+	MaxTelemetryRange = 31 * 24 * time.Hour
+
+	// DefaultTelemetryRange - период по умолчанию, если параметр from не указан
+	DefaultTelemetryRange = 24 * time.Hour
+
+	// MaxTelemetryRows - максимальное количество записей в одном ответе
+	MaxTelemetryRows = 100000
+)
+
+// Store описывает методы хранилища, необходимые для API чтения данных
+type Store interface {
+	GetStations() ([]string, error)
+	GetTelemetryRange(stationID, sensorKey string, from, to time.Time, limit int) ([]database.TelemetryRecord, error)
+}
+
+// HealthChecker описывает хранилище, доступность которого можно проверить. Если хранилище
+// не реализует интерфейс, проверка готовности /readyz всегда успешна
+type HealthChecker interface {
+	Healthy(ctx context.Context) error
+}
+
+// Проверка, что DBManager реализует интерфейсы Store и HealthChecker
+var (
+	_ Store         = (*database.DBManager)(nil)
+	_ HealthChecker = (*database.DBManager)(nil)
+)
+
+// Server предоставляет HTTP API для чтения сохраненных данных
+type Server struct {
+	Store      Store
+	HTTPServer *http.Server
+
+	// Рассылка новых записей для потока телеметрии (nil - поток не настроен)
+	broker *Broker
+
+	// Закрывается при остановке сервера и завершает открытые потоки
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Option задает дополнительную настройку сервера
+type Option func(*Server)
+
+// WithBroker включает поток телеметрии GET /stations/{id}/stream с новыми записями из broker
+func WithBroker(broker *Broker) Option {
+	return func(s *Server) {
+		s.broker = broker
+	}
+}
+
+// errorResponse представляет собой ответ с ошибкой
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewServer создает HTTP сервер API чтения данных на указанном адресе
+func NewServer(addr string, store Store, opts ...Option) *Server {
+	s := &Server{Store: store, done: make(chan struct{})}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stations", s.handleStations)
+	mux.HandleFunc("GET /stations/{id}/telemetry", s.handleTelemetry)
+	mux.HandleFunc("GET /stations/{id}/stream", s.handleStream)
+	mux.HandleFunc("GET /readyz", s.handleReady)
+
+	s.HTTPServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+// Start запускает HTTP сервер в отдельной горутине
+func (s *Server) Start() {
+	go func() {
+		log.Printf("API чтения данных доступно по адресу %s", s.HTTPServer.Addr)
+		if err := s.HTTPServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Errorf("Ошибка HTTP сервера: %v", err)
+		}
+	}()
+}
+
+// Shutdown останавливает HTTP сервер, дожидаясь завершения активных запросов.
+// Открытые потоки телеметрии завершаются сразу
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.done) })
+	return s.HTTPServer.Shutdown(ctx)
+}
+
+// handleReady сообщает о готовности сервиса: 200, если хранилище доступно, иначе 503
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if checker, ok := s.Store.(HealthChecker); ok {
+		if err := checker.Healthy(r.Context()); err != nil {
+			log.Printf("Проверка готовности не пройдена: %v", err)
+			writeError(w, http.StatusServiceUnavailable, "хранилище недоступно")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleStations возвращает список ID станций
+func (s *Server) handleStations(w http.ResponseWriter, r *http.Request) {
+	stations, err := s.Store.GetStations()
+	if err != nil {
+		logging.Errorf("Ошибка при получении списка станций: %v", err)
+		writeError(w, http.StatusInternalServerError, "ошибка при получении списка станций")
+		return
+	}
+
+	if stations == nil {
+		stations = []string{}
+	}
+
+	writeJSON(w, http.StatusOK, stations)
+}
+
+// handleTelemetry возвращает телеметрию станции за период.
+// Параметры: sensor (необязательный), from и to (RFC3339 или timestamp в миллисекундах)
+func (s *Server) handleTelemetry(w http.ResponseWriter, r *http.Request) {
+	stationID := r.PathValue("id")
+	query := r.URL.Query()
+	sensorKey := query.Get("sensor")
+
+	to := time.Now()
+	if raw := query.Get("to"); raw != "" {
+		t, err := parseTime(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "некорректный параметр to: "+err.Error())
+			return
+		}
+		to = t
+	}
+
+	from := to.Add(-DefaultTelemetryRange)
+	if raw := query.Get("from"); raw != "" {
+		t, err := parseTime(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "некорректный параметр from: "+err.Error())
+			return
+		}
+		from = t
+	}
+
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, "параметр from должен быть раньше to")
+		return
+	}
+
+	if to.Sub(from) > MaxTelemetryRange {
+		writeError(w, http.StatusBadRequest, "запрошенный период превышает "+MaxTelemetryRange.String())
+		return
+	}
+
+	records, err := s.Store.GetTelemetryRange(stationID, sensorKey, from, to, MaxTelemetryRows)
+	if err != nil {
+		logging.Errorf("Ошибка при получении телеметрии станции %s: %v", stationID, err)
+		writeError(w, http.StatusInternalServerError, "ошибка при получении телеметрии")
+		return
+	}
+
+	if records == nil {
+		records = []database.TelemetryRecord{}
+	}
+
+	writeJSON(w, http.StatusOK, records)
+}
+
+// parseTime разбирает время в формате RFC3339 или timestamp в миллисекундах
+func parseTime(raw string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, errors.New("ожидается RFC3339 или timestamp в миллисекундах")
+	}
+	return t, nil
+}
+
+// writeJSON записывает ответ в формате JSON
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Errorf("Ошибка при записи ответа: %v", err)
+	}
+}
+
+// writeError записывает ответ с ошибкой в формате JSON
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
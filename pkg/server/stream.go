@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"weatherInTheField/pkg/api"
+	"weatherInTheField/pkg/database"
+	"weatherInTheField/pkg/logging"
+)
+
+// subscriberBuffer - количество записей, которые накапливаются для медленного подписчика.
+// При переполнении новые записи для него пропускаются, чтобы не задерживать сбор данных
+const subscriberBuffer = 1024
+
+// Broker рассылает подписчикам потока телеметрии записи, сохраненные сбором данных
+type Broker struct {
+	compassPoints int
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]bool
+}
+
+// subscriber - подписка на записи станции (и датчика, если он указан)
+type subscriber struct {
+	stationID string
+	sensorKey string
+	records   chan database.TelemetryRecord
+	dropped   int
+}
+
+// NewBroker создает рассылку телеметрии. compassPoints - количество румбов для направления ветра
+func NewBroker(compassPoints int) *Broker {
+	return &Broker{
+		compassPoints: compassPoints,
+		subscribers:   make(map[*subscriber]bool),
+	}
+}
+
+// Publish рассылает сохраненную телеметрию станции подписчикам. Не блокируется:
+// если подписчик не успевает читать записи, лишние записи для него пропускаются.
+// Для nil ничего не делает
+func (b *Broker) Publish(stationID string, data map[string][]api.TelemetryPoint) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.subscribers) == 0 {
+		return
+	}
+
+	records := database.TelemetryRecords(stationID, data, b.compassPoints)
+	for sub := range b.subscribers {
+		if sub.stationID != stationID {
+			continue
+		}
+		for _, record := range records {
+			if sub.sensorKey != "" && record.SensorKey != sub.sensorKey {
+				continue
+			}
+			select {
+			case sub.records <- record:
+			default:
+				sub.dropped++
+			}
+		}
+	}
+}
+
+// subscribe создает подписку на записи станции (sensorKey - пусто для всех датчиков)
+func (b *Broker) subscribe(stationID, sensorKey string) *subscriber {
+	sub := &subscriber{
+		stationID: stationID,
+		sensorKey: sensorKey,
+		records:   make(chan database.TelemetryRecord, subscriberBuffer),
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = true
+	b.mu.Unlock()
+
+	return sub
+}
+
+// unsubscribe удаляет подписку и возвращает количество пропущенных для нее записей
+func (b *Broker) unsubscribe(sub *subscriber) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, sub)
+	return sub.dropped
+}
+
+// handleStream отдает телеметрию станции в формате JSON Lines: сначала записи за период
+// с from (параметры как у /telemetry, без to), затем, не закрывая соединение, новые записи
+// по мере их сохранения сбором данных. Поток завершается при отключении клиента или остановке сервера
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if s.broker == nil {
+		writeError(w, http.StatusNotFound, "поток телеметрии не настроен")
+		return
+	}
+
+	stationID := r.PathValue("id")
+	query := r.URL.Query()
+	sensorKey := query.Get("sensor")
+
+	now := time.Now()
+	from := now.Add(-DefaultTelemetryRange)
+	if raw := query.Get("from"); raw != "" {
+		t, err := parseTime(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "некорректный параметр from: "+err.Error())
+			return
+		}
+		from = t
+	}
+
+	if !from.Before(now) {
+		writeError(w, http.StatusBadRequest, "параметр from должен быть в прошлом")
+		return
+	}
+
+	if now.Sub(from) > MaxTelemetryRange {
+		writeError(w, http.StatusBadRequest, "запрошенный период превышает "+MaxTelemetryRange.String())
+		return
+	}
+
+	// Подписываемся до чтения истории, чтобы не потерять записи, сохраненные во время запроса
+	sub := s.broker.subscribe(stationID, sensorKey)
+	defer func() {
+		if dropped := s.broker.unsubscribe(sub); dropped > 0 {
+			log.Printf("Клиент потока телеметрии станции %s не успевал получать данные, пропущено записей: %d", stationID, dropped)
+		}
+	}()
+
+	records, err := s.Store.GetTelemetryRange(stationID, sensorKey, from, now, MaxTelemetryRows)
+	if err != nil {
+		logging.Errorf("Ошибка при получении телеметрии станции %s: %v", stationID, err)
+		writeError(w, http.StatusInternalServerError, "ошибка при получении телеметрии")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	controller := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+
+	// Время последней отданной записи каждого датчика: записи, уже вошедшие в историю, не повторяются
+	sent := make(map[string]int64)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return
+		}
+		sent[record.SensorKey] = record.Timestamp
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case record := <-sub.records:
+			if record.Timestamp <= sent[record.SensorKey] {
+				continue
+			}
+			if err := encoder.Encode(record); err != nil {
+				return
+			}
+			if err := controller.Flush(); err != nil {
+				return
+			}
+			sent[record.SensorKey] = record.Timestamp
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
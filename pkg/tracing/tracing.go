@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"weatherInTheField/pkg/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName - имя сервиса в трассировках
+const ServiceName = "weatherservice"
+
+// Setup настраивает экспорт трассировок OpenTelemetry по протоколу OTLP/HTTP.
+// Если OTEL_EXPORTER_OTLP_ENDPOINT не указан, трассировка отключена: спаны создаются
+// глобальным no-op провайдером и никуда не отправляются.
+// Возвращает функцию, которая отправляет накопленные спаны и останавливает экспорт
+func Setup(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	if cfg.OtelEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// Экспортер сам читает стандартные переменные OTEL_EXPORTER_OTLP_* (адрес, заголовки, TLS)
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании экспортера трассировок: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при описании ресурса трассировок: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
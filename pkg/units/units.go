@@ -0,0 +1,111 @@
+package units
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Conversion описывает преобразование значения датчика в другие единицы измерения
+type Conversion struct {
+	Name    string                // Имя преобразования в конфигурации
+	Unit    string                // Единица измерения результата
+	Convert func(float64) float64 // Функция преобразования
+}
+
+// builtinConversions содержит встроенные преобразования единиц измерения
+var builtinConversions = map[string]Conversion{
+	"ms_to_kmh": {Name: "ms_to_kmh", Unit: "km/h", Convert: func(v float64) float64 { return v * 3.6 }},
+	"kmh_to_ms": {Name: "kmh_to_ms", Unit: "m/s", Convert: func(v float64) float64 { return v / 3.6 }},
+	"c_to_f":    {Name: "c_to_f", Unit: "°F", Convert: func(v float64) float64 { return v*9/5 + 32 }},
+	"f_to_c":    {Name: "f_to_c", Unit: "°C", Convert: func(v float64) float64 { return (v - 32) * 5 / 9 }},
+}
+
+// Lookup возвращает встроенное преобразование по имени
+func Lookup(name string) (Conversion, bool) {
+	conv, ok := builtinConversions[name]
+	return conv, ok
+}
+
+// Names возвращает отсортированный список имен встроенных преобразований
+func Names() []string {
+	names := make([]string, 0, len(builtinConversions))
+	for name := range builtinConversions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve сопоставляет ключам датчиков преобразования по их именам из конфигурации.
+// Неизвестное имя преобразования считается ошибкой конфигурации
+func Resolve(sensorConversions map[string]string) (map[string]Conversion, error) {
+	result := make(map[string]Conversion, len(sensorConversions))
+	for sensorKey, name := range sensorConversions {
+		conv, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("неизвестное преобразование %q для датчика %s, доступные: %s",
+				name, sensorKey, strings.Join(Names(), ", "))
+		}
+		result[sensorKey] = conv
+	}
+	return result, nil
+}
+
+// Round округляет значение до указанного количества знаков после запятой
+func Round(value float64, decimals int) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return value
+	}
+	pow := math.Pow10(decimals)
+	return math.Round(value*pow) / pow
+}
+
+// compassDegrees сопоставляет обозначениям румбов направление в градусах.
+// Поддерживаются латинские и русские обозначения 16 румбов
+var compassDegrees = map[string]float64{
+	"N": 0, "NNE": 22.5, "NE": 45, "ENE": 67.5,
+	"E": 90, "ESE": 112.5, "SE": 135, "SSE": 157.5,
+	"S": 180, "SSW": 202.5, "SW": 225, "WSW": 247.5,
+	"W": 270, "WNW": 292.5, "NW": 315, "NNW": 337.5,
+
+	"С": 0, "ССВ": 22.5, "СВ": 45, "ВСВ": 67.5,
+	"В": 90, "ВЮВ": 112.5, "ЮВ": 135, "ЮЮВ": 157.5,
+	"Ю": 180, "ЮЮЗ": 202.5, "ЮЗ": 225, "ЗЮЗ": 247.5,
+	"З": 270, "ЗСЗ": 292.5, "СЗ": 315, "ССЗ": 337.5,
+}
+
+// CompassToDegrees преобразует обозначение направления ветра (например, "NE" или "СВ") в градусы.
+// Второе значение равно false, если обозначение неизвестно
+func CompassToDegrees(label string) (float64, bool) {
+	degrees, ok := compassDegrees[strings.ToUpper(strings.TrimSpace(label))]
+	return degrees, ok
+}
+
+// compassLabels - обозначения 16 румбов по часовой стрелке от севера
+var compassLabels = []string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// DegreesToCompass преобразует направление ветра в градусах в обозначение румба: 8 румбов (N, NE, E...)
+// при points = 8, иначе 16 румбов (N, NNE, NE...). Градусы вне диапазона 0-360 приводятся к нему
+// (например, 370 - то же, что 10, а -10 - то же, что 350). Для NaN и бесконечности возвращает пустую строку
+func DegreesToCompass(deg float64, points int) string {
+	if math.IsNaN(deg) || math.IsInf(deg, 0) {
+		return ""
+	}
+	if points != 8 {
+		points = 16
+	}
+
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+
+	// Направление относится к ближайшему румбу, значения около 360 - к северу
+	sector := int(math.Round(deg/(360/float64(points)))) % points
+	return compassLabels[sector*(len(compassLabels)/points)]
+}